@@ -2,11 +2,6 @@
 package main
 
 import (
-	"context"
-	"fmt"
-	"strconv"
-	"strings"
-
 	"github.com/spf13/cobra"
 
 	"github.com/ideamans/static-webshot/pkg/adapters/chromebrowser"
@@ -18,13 +13,10 @@ import (
 
 func newCaptureCmd() *cobra.Command {
 	cfg := record.DefaultConfig()
+	cfg.Version = version
 
-	var viewport string
-	var resize string
-	var masks []string
-	var waitSelectors []string
+	var flags *captureFlags
 	var verbose bool
-	var headful bool
 
 	cmd := &cobra.Command{
 		Use:   "capture <url>",
@@ -33,6 +25,16 @@ func newCaptureCmd() *cobra.Command {
 
 The capture command navigates to the specified URL and captures a screenshot
 with deterministic behavior (disabled animations, fixed time, etc.).
+The settings it was captured with are written next to it as <name>.meta.json
+(URL and final URL, preset, viewport, DPR, User-Agent, mock time, masks,
+deterministic scripts, Chrome version, timestamp and static-webshot version);
+--embed-metadata also writes them into the PNG as tEXt chunks. Console
+messages, uncaught exceptions, failed requests, mixed content and the HTTP
+status of the page go to <name>.diagnostics.json. --layout-snapshot records
+the visible elements (path, box, key computed styles and text) in
+<name>.layout.json, which compare uses to explain differences by element.
+--a11y-snapshot records the accessibility tree (roles, names and states) in
+<name>.a11y.json for compare-a11y.
 
 Examples:
   static-webshot capture https://example.com
@@ -41,52 +43,24 @@ Examples:
   static-webshot capture https://example.com --viewport 1280x720
   static-webshot capture https://example.com --resize 800x600
   static-webshot capture https://example.com --resize 800
+  static-webshot capture https://example.com -o preview.jpg --quality 80
   static-webshot capture https://example.com --mask ".ad-banner" --mask ".cookie-notice"
+  static-webshot capture https://example.com --mask ".ad=blackout" --mask ".date=box:#ff00ff"
+  static-webshot capture https://example.com --layout-snapshot
+  static-webshot capture https://example.com --a11y-snapshot
+  static-webshot capture https://example.com --disable-determinism scroll,intersection
+  static-webshot capture http://localhost:4173 --serve-cmd "npm run preview" --serve-url http://localhost:4173
 `,
 		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg.URL = args[0]
 
-			// Parse viewport if specified (WIDTHxHEIGHT or just WIDTH)
-			if viewport != "" {
-				parts := strings.Split(viewport, "x")
-				width, err := strconv.Atoi(parts[0])
-				if err != nil {
-					return fmt.Errorf("invalid viewport width: %s", parts[0])
-				}
-				cfg.ViewportWidth = width
-				if len(parts) >= 2 {
-					height, err := strconv.Atoi(parts[1])
-					if err != nil {
-						return fmt.Errorf("invalid viewport height: %s", parts[1])
-					}
-					cfg.ViewportHeight = height
-				}
-			}
-
-			// Parse resize if specified (WIDTHxHEIGHT or just WIDTH)
-			if resize != "" {
-				parts := strings.Split(resize, "x")
-				width, err := strconv.Atoi(parts[0])
-				if err != nil {
-					return fmt.Errorf("invalid resize width: %s", parts[0])
-				}
-				cfg.ResizeWidth = width
-				if len(parts) >= 2 {
-					height, err := strconv.Atoi(parts[1])
-					if err != nil {
-						return fmt.Errorf("invalid resize height: %s", parts[1])
-					}
-					cfg.ResizeHeight = height
-				}
+			if err := flags.apply(&cfg); err != nil {
+				return err
 			}
 
-			cfg.Masks = masks
-			cfg.WaitSelectors = waitSelectors
-
-			// Handle headful flag
-			if headful {
-				cfg.Headless = false
+			if err := checkServeFlags(&cfg); err != nil {
+				return err
 			}
 
 			// Set up logger
@@ -101,7 +75,7 @@ Examples:
 
 			// Execute
 			executor := record.NewExecutor(browser, fs, log)
-			if err := executor.Execute(context.Background(), cfg); err != nil {
+			if err := executor.Execute(cmd.Context(), cfg); err != nil {
 				return err
 			}
 
@@ -111,21 +85,12 @@ Examples:
 
 	// Flags
 	cmd.Flags().StringVarP(&cfg.OutputPath, "output", "o", cfg.OutputPath, "Output file path")
-	cmd.Flags().StringVar(&cfg.Preset, "preset", cfg.Preset, "Device preset (desktop, mobile)")
-	cmd.Flags().StringVar(&viewport, "viewport", "", "Viewport size (WIDTH or WIDTHxHEIGHT)")
-	cmd.Flags().StringVar(&resize, "resize", "", "Output image size (WIDTH or WIDTHxHEIGHT)")
-	cmd.Flags().IntVar(&cfg.WaitAfter, "wait-after", cfg.WaitAfter, "Wait time after page load in milliseconds")
-	cmd.Flags().BoolVar(&cfg.Headless, "headless", cfg.Headless, "Run in headless mode")
-	cmd.Flags().BoolVar(&headful, "headful", false, "Run in headful mode (opposite of headless)")
-	cmd.Flags().StringVar(&cfg.ProxyServer, "proxy", "", "HTTP proxy URL")
-	cmd.Flags().BoolVar(&cfg.IgnoreHTTPSErrors, "ignore-tls-errors", cfg.IgnoreHTTPSErrors, "Ignore TLS certificate errors")
-	cmd.Flags().StringArrayVar(&masks, "mask", nil, "CSS selector for elements to hide (can be repeated)")
-	cmd.Flags().StringArrayVar(&waitSelectors, "wait-selector", nil, "CSS selector to wait for (can be repeated)")
-	cmd.Flags().StringVar(&cfg.InjectCSS, "inject-css", "", "Custom CSS to inject")
-	cmd.Flags().StringVar(&cfg.MockTime, "mock-time", "", "Fixed time for Date API (ISO 8601 format)")
-	cmd.Flags().StringVar(&cfg.ChromePath, "chrome-path", "", "Path to Chrome executable")
-	cmd.Flags().IntVar(&cfg.Timeout, "timeout", cfg.Timeout, "Navigation timeout in seconds")
-	cmd.Flags().StringVar(&cfg.UserAgent, "user-agent", "", "Custom User-Agent string (overrides preset)")
+	cmd.Flags().BoolVar(&cfg.EmbedMetadata, "embed-metadata", false, "Also write the capture metadata into the PNG as tEXt chunks")
+	cmd.Flags().BoolVar(&cfg.LayoutSnapshot, "layout-snapshot", false, "Also record the visible elements of the page to <name>.layout.json")
+	cmd.Flags().BoolVar(&cfg.AccessibilitySnapshot, "a11y-snapshot", false, "Also record the accessibility tree of the page to <name>.a11y.json")
+	addFormatFlags(cmd, &cfg.Encoding)
+	flags = addCaptureFlags(cmd, &cfg)
+	addServeFlags(cmd, &cfg)
 	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
 
 	return cmd
@@ -2,8 +2,6 @@
 package main
 
 import (
-	"context"
-
 	"github.com/spf13/cobra"
 
 	"github.com/ideamans/static-webshot/pkg/adapters/logger"
@@ -24,18 +22,41 @@ func newCompareCmd() *cobra.Command {
 
 The compare command loads two images, compares them, and outputs a composite
 image showing: baseline | diff | current (left to right).
+--diff-layout vertical stacks the panels instead, diff-only keeps the diff
+panel alone, pair shows baseline | current and raw-pixelmatch writes
+pixelmatch's own diff mask.
+--crop-to-changes renders only the changed regions, with some context,
+stacked as a gallery.
 
 The diff panel shows the baseline image at 50% brightness with red overlay
-on pixels that differ between the two images.
+on pixels that differ between the two images. Images of different sizes are
+padded to the larger size with magenta by default; --size-mismatch crop
+compares the common area, scale stretches current, its masked areas and its
+layout snapshot to the baseline size, and fail stops with an error. Both
+sizes are reported either way.
 
 Comparison results including diff percent are output to stdout.
-Use --digest-txt or --digest-json to save results to a file.
+Use --digest-txt or --digest-json to save results to a file. When both
+images were captured with --layout-snapshot, the regions of differing pixels
+are attributed to the elements that were added, removed, moved, resized,
+restyled or changed text. --position-tolerance and --size-tolerance set how
+far, in CSS pixels, an element may move or resize before it counts, as in
+compare-layout.
+
+Identical images are detected before the pixel comparison; with
+--skip-diff-on-equal no diff image is written for them, and a stale one at
+the output path is removed. The digest carries a perceptual hash (dHash) of
+each image to group near-duplicates.
 
 Examples:
   static-webshot compare baseline.png current.png
   static-webshot compare baseline.png current.png -o diff.png
+  static-webshot compare baseline.png current.png -o diff.webp
   static-webshot compare baseline.png current.png --digest-txt result.txt
   static-webshot compare baseline.png current.png --digest-json result.json
+  static-webshot compare baseline.png current.png --skip-diff-on-equal
+  static-webshot compare baseline.png current.png --size-mismatch crop
+  static-webshot compare baseline.png current.png --diff-layout vertical --crop-to-changes
 `,
 		Args: cobra.ExactArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -54,7 +75,7 @@ Examples:
 
 			// Execute
 			executor := compare.NewExecutor(processor, fs, log)
-			_, err := executor.Execute(context.Background(), cfg)
+			_, err := executor.Execute(cmd.Context(), cfg)
 			if err != nil {
 				return err
 			}
@@ -67,13 +88,10 @@ Examples:
 	cmd.Flags().StringVarP(&cfg.OutputPath, "output", "o", cfg.OutputPath, "Diff image output path")
 	cmd.Flags().StringVar(&cfg.DigestTxtPath, "digest-txt", "", "Path to save comparison digest as text (optional)")
 	cmd.Flags().StringVar(&cfg.DigestJSONPath, "digest-json", "", "Path to save comparison digest as JSON (optional)")
-	cmd.Flags().IntVar(&cfg.ColorThreshold, "color-threshold", cfg.ColorThreshold, "Per-pixel color difference threshold (0-255)")
-	cmd.Flags().BoolVar(&cfg.IgnoreAntialiasing, "ignore-antialiasing", cfg.IgnoreAntialiasing, "Ignore antialiased pixels")
-	cmd.Flags().StringVar(&cfg.LabelFontPath, "label-font", "", "Path to TrueType font file for labels (optional)")
-	cmd.Flags().Float64Var(&cfg.LabelFontSize, "label-font-size", 14, "Font size for labels in points")
-	cmd.Flags().StringVar(&cfg.BaselineLabel, "baseline-label", cfg.BaselineLabel, "Label text for the baseline panel")
-	cmd.Flags().StringVar(&cfg.DiffLabel, "diff-label", cfg.DiffLabel, "Label text for the diff panel")
-	cmd.Flags().StringVar(&cfg.CurrentLabel, "current-label", cfg.CurrentLabel, "Label text for the current panel")
+	cmd.Flags().BoolVar(&cfg.IgnoreMasked, "ignore-masked", cfg.IgnoreMasked, "Exclude the masked areas recorded in the images' .meta.json files")
+	addFormatFlags(cmd, &cfg.Encoding)
+	addCompareFlags(cmd, &cfg)
+	addToleranceFlags(cmd, &cfg.LayoutTolerance)
 	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
 
 	return cmd
@@ -0,0 +1,61 @@
+// Package main provides the compare-a11y subcommand.
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/ideamans/static-webshot/pkg/adapters/logger"
+	"github.com/ideamans/static-webshot/pkg/adapters/osfilesystem"
+	"github.com/ideamans/static-webshot/pkg/comparea11y"
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
+func newCompareA11yCmd() *cobra.Command {
+	cfg := comparea11y.DefaultConfig()
+	var verbose bool
+
+	cmd := &cobra.Command{
+		Use:   "compare-a11y <baseline> <current>",
+		Short: "Compare the accessibility trees of two captures",
+		Long: `Compare the accessibility trees of two captures.
+
+The compare-a11y command reads the accessibility snapshots recorded by
+capture --a11y-snapshot and reports the nodes that were added, removed,
+renamed or changed state (heading level, checked, expanded, disabled, ...).
+Regressions like an icon-only button losing its label are invisible in a
+screenshot. Nodes added or removed with their parent are left out.
+
+Arguments are the screenshots (their <name>.a11y.json is read) or the
+accessibility snapshots themselves. Nodes are matched by their path of roles
+in the tree.
+
+Examples:
+  static-webshot compare-a11y baseline.png current.png
+  static-webshot compare-a11y baseline.a11y.json current.a11y.json
+  static-webshot compare-a11y baseline.png current.png --digest-json a11y.json
+`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg.BaselinePath = args[0]
+			cfg.CurrentPath = args[1]
+
+			// Set up logger
+			log := logger.New()
+			if verbose {
+				log.SetLevel(ports.LogLevelDebug)
+			}
+
+			// Execute
+			executor := comparea11y.NewExecutor(osfilesystem.New(), log)
+			_, err := executor.Execute(cmd.Context(), cfg)
+			return err
+		},
+	}
+
+	// Flags
+	cmd.Flags().StringVar(&cfg.DigestTxtPath, "digest-txt", "", "Path to save the accessibility digest as text (optional)")
+	cmd.Flags().StringVar(&cfg.DigestJSONPath, "digest-json", "", "Path to save the accessibility digest as JSON (optional)")
+	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
+
+	return cmd
+}
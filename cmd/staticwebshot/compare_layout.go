@@ -0,0 +1,64 @@
+// Package main provides the compare-layout subcommand.
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/ideamans/static-webshot/pkg/adapters/logger"
+	"github.com/ideamans/static-webshot/pkg/adapters/osfilesystem"
+	"github.com/ideamans/static-webshot/pkg/comparelayout"
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
+func newCompareLayoutCmd() *cobra.Command {
+	cfg := comparelayout.DefaultConfig()
+	var verbose bool
+
+	cmd := &cobra.Command{
+		Use:   "compare-layout <baseline> <current>",
+		Short: "Compare the layout snapshots of two captures element by element",
+		Long: `Compare the layout snapshots of two captures element by element.
+
+The compare-layout command reads the layout snapshots recorded by
+capture --layout-snapshot and reports the elements that were added, removed,
+moved, resized, restyled or changed text, without looking at pixels. It
+catches shifts too small to show up in a pixel diff, and explains the ones
+that do. Children that were added or removed with their parent, or only moved
+along with it, are left out.
+
+Arguments are the screenshots (their <name>.layout.json is read) or the
+layout snapshots themselves. Elements are matched by their path in the page,
+positions and sizes are compared in CSS pixels.
+
+Examples:
+  static-webshot compare-layout baseline.png current.png
+  static-webshot compare-layout baseline.layout.json current.layout.json
+  static-webshot compare-layout baseline.png current.png --position-tolerance 2 --size-tolerance 2
+  static-webshot compare-layout baseline.png current.png --digest-json layout.json
+`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg.BaselinePath = args[0]
+			cfg.CurrentPath = args[1]
+
+			// Set up logger
+			log := logger.New()
+			if verbose {
+				log.SetLevel(ports.LogLevelDebug)
+			}
+
+			// Execute
+			executor := comparelayout.NewExecutor(osfilesystem.New(), log)
+			_, err := executor.Execute(cmd.Context(), cfg)
+			return err
+		},
+	}
+
+	// Flags
+	addToleranceFlags(cmd, &cfg.Tolerance)
+	cmd.Flags().StringVar(&cfg.DigestTxtPath, "digest-txt", "", "Path to save the layout digest as text (optional)")
+	cmd.Flags().StringVar(&cfg.DigestJSONPath, "digest-json", "", "Path to save the layout digest as JSON (optional)")
+	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
+
+	return cmd
+}
@@ -0,0 +1,92 @@
+// Package main provides the compare-revs subcommand.
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/ideamans/static-webshot/pkg/adapters/chromebrowser"
+	"github.com/ideamans/static-webshot/pkg/adapters/gitcli"
+	"github.com/ideamans/static-webshot/pkg/adapters/logger"
+	"github.com/ideamans/static-webshot/pkg/adapters/osfilesystem"
+	"github.com/ideamans/static-webshot/pkg/adapters/pixelmatch"
+	"github.com/ideamans/static-webshot/pkg/adapters/staticserver"
+	"github.com/ideamans/static-webshot/pkg/comparerevs"
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
+func newCompareRevsCmd() *cobra.Command {
+	cfg := comparerevs.DefaultConfig()
+
+	var flags *captureFlags
+	var verbose bool
+
+	cmd := &cobra.Command{
+		Use:   "compare-revs <rev-a> <rev-b>",
+		Short: "Build, capture and compare two git revisions of a static site",
+		Long: `Build, capture and compare two git revisions of a static site.
+
+For each revision, compare-revs checks the tree out into a temporary git
+worktree, runs the build command there, serves the build output with an
+embedded static server and captures every page. It then compares the pages
+of both revisions.
+
+Images are written to OUTPUT-DIR/baseline, OUTPUT-DIR/current and
+OUTPUT-DIR/diff, one file per page ("/" is saved as index.png and
+"/docs/intro/" as docs_intro.png; an underscore in a path is written as %5F
+so distinct pages never share a file), with a JSON digest beside each diff
+image. Captures are always lossless PNG so that they compare exactly;
+--format and --quality apply to the diff images only.
+
+Examples:
+  static-webshot compare-revs main HEAD --build "npm ci && npm run build" --site-dir dist
+  static-webshot compare-revs v1.2.0 v1.3.0 --site-dir public --page / --page /about/
+  static-webshot compare-revs main feature --build "hugo" --site-dir public -o ./vrt --mock-time 2026-01-01T00:00:00Z
+`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg.BaselineRev = args[0]
+			cfg.CurrentRev = args[1]
+
+			if err := flags.apply(&cfg.Record); err != nil {
+				return err
+			}
+			// Captures stay lossless PNG; --format and --quality only
+			// apply to the diff images
+			cfg.Record.Encoding.Compression = cfg.Compare.Encoding.Compression
+
+			// Set up logger
+			log := logger.New()
+			if verbose {
+				log.SetLevel(ports.LogLevelDebug)
+			}
+
+			// Set up dependencies
+			vcs := gitcli.New()
+			server := staticserver.New()
+			browser := chromebrowser.New()
+			processor := pixelmatch.New()
+			fs := osfilesystem.New()
+
+			// Execute
+			executor := comparerevs.NewExecutor(vcs, server, browser, processor, fs, log)
+			if _, err := executor.Execute(cmd.Context(), cfg); err != nil {
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	// Flags
+	cmd.Flags().StringVarP(&cfg.OutputDir, "output-dir", "o", cfg.OutputDir, "Directory for baseline, current and diff images")
+	cmd.Flags().StringVar(&cfg.RepoDir, "repo", cfg.RepoDir, "Path to the git repository")
+	cmd.Flags().StringVar(&cfg.BuildCommand, "build", "", "Shell command that builds the site in each checkout")
+	cmd.Flags().StringVar(&cfg.SiteDir, "site-dir", cfg.SiteDir, "Build output directory to serve, relative to the repository root")
+	cmd.Flags().StringArrayVar(&cfg.Pages, "page", cfg.Pages, "URL path to capture (can be repeated)")
+	flags = addCaptureFlags(cmd, &cfg.Record)
+	addFormatFlags(cmd, &cfg.Compare.Encoding)
+	addCompareFlags(cmd, &cfg.Compare)
+	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
+
+	return cmd
+}
@@ -0,0 +1,83 @@
+// Package main provides the diff-urls subcommand.
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/ideamans/static-webshot/pkg/adapters/chromebrowser"
+	"github.com/ideamans/static-webshot/pkg/adapters/logger"
+	"github.com/ideamans/static-webshot/pkg/adapters/osfilesystem"
+	"github.com/ideamans/static-webshot/pkg/adapters/pixelmatch"
+	"github.com/ideamans/static-webshot/pkg/diffurls"
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
+func newDiffURLsCmd() *cobra.Command {
+	cfg := diffurls.DefaultConfig()
+
+	var flags *captureFlags
+	var verbose bool
+
+	cmd := &cobra.Command{
+		Use:   "diff-urls <baselineURL> <currentURL>",
+		Short: "Capture two URLs and compare them in one step",
+		Long: `Capture two URLs and compare them in one step.
+
+The diff-urls command captures both URLs with identical settings in a single
+browser session and compares the screenshots in memory. Only the composite
+diff image and the digests are written; no intermediate screenshots are left
+behind. With --serve-cmd, a local server is started before the captures and
+stopped afterwards, as capture does.
+
+Examples:
+  static-webshot diff-urls https://example.com https://staging.example.com
+  static-webshot diff-urls https://example.com https://staging.example.com -o diff.png --digest-json result.json
+  static-webshot diff-urls https://example.com/pricing https://staging.example.com/pricing --preset mobile --mock-time 2026-01-01T00:00:00Z
+  static-webshot diff-urls https://example.com/ http://localhost:4173/ --serve-cmd "npm run preview" --serve-url http://localhost:4173/
+`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg.BaselineURL = args[0]
+			cfg.CurrentURL = args[1]
+
+			if err := flags.apply(&cfg.Record); err != nil {
+				return err
+			}
+			if err := checkServeFlags(&cfg.Record); err != nil {
+				return err
+			}
+
+			// Set up logger
+			log := logger.New()
+			if verbose {
+				log.SetLevel(ports.LogLevelDebug)
+			}
+
+			// Set up dependencies
+			browser := chromebrowser.New()
+			processor := pixelmatch.New()
+			fs := osfilesystem.New()
+
+			// Execute
+			executor := diffurls.NewExecutor(browser, processor, fs, log)
+			if _, err := executor.Execute(cmd.Context(), cfg); err != nil {
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	// Flags
+	cmd.Flags().StringVarP(&cfg.Compare.OutputPath, "output", "o", cfg.Compare.OutputPath, "Diff image output path")
+	cmd.Flags().StringVar(&cfg.Compare.DigestTxtPath, "digest-txt", "", "Path to save comparison digest as text (optional)")
+	cmd.Flags().StringVar(&cfg.Compare.DigestJSONPath, "digest-json", "", "Path to save comparison digest as JSON (optional)")
+	flags = addCaptureFlags(cmd, &cfg.Record)
+	addServeFlags(cmd, &cfg.Record)
+	addFormatFlags(cmd, &cfg.Compare.Encoding)
+	addCompareFlags(cmd, &cfg.Compare)
+	cmd.Flags().BoolVar(&cfg.Compare.IgnoreMasked, "ignore-masked", cfg.Compare.IgnoreMasked, "Exclude the areas covered by --mask")
+	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose output")
+
+	return cmd
+}
@@ -0,0 +1,284 @@
+// Package main provides flag sets shared between subcommands.
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ideamans/static-webshot/pkg/compare"
+	"github.com/ideamans/static-webshot/pkg/imageformat"
+	"github.com/ideamans/static-webshot/pkg/layout"
+	"github.com/ideamans/static-webshot/pkg/ports"
+	"github.com/ideamans/static-webshot/pkg/record"
+)
+
+// captureFlags holds capture flag values that need parsing before they can be
+// applied to a record.Config.
+type captureFlags struct {
+	viewport      string
+	resize        string
+	masks         []string
+	waitSelectors []string
+	headful       bool
+	geolocation   string
+	carouselHooks []string
+	randomSeed    int64
+
+	// cmd tells flags left at their default from flags set to it.
+	cmd *cobra.Command
+}
+
+// addCaptureFlags registers the page capture settings shared by every
+// subcommand that drives the browser. Output paths are left to the caller.
+func addCaptureFlags(cmd *cobra.Command, cfg *record.Config) *captureFlags {
+	f := &captureFlags{cmd: cmd}
+
+	cmd.Flags().StringVar(&cfg.Preset, "preset", cfg.Preset, "Device preset (see 'presets list')")
+	cmd.Flags().StringVar(&cfg.PresetsFile, "presets-file", "", "JSON or YAML file with custom device presets")
+	cmd.Flags().StringVar(&f.viewport, "viewport", "", "Viewport size (WIDTH or WIDTHxHEIGHT)")
+	cmd.Flags().Float64Var(&cfg.DeviceScaleFactor, "dpr", 0, "Device pixel ratio (0 = preset value)")
+	cmd.Flags().StringVar(&f.resize, "resize", "", "Output image size in pixels after --dpr scaling (WIDTH or WIDTHxHEIGHT)")
+	cmd.Flags().IntVar(&cfg.WaitAfter, "wait-after", cfg.WaitAfter, "Wait time after page load in milliseconds")
+	cmd.Flags().BoolVar(&cfg.Headless, "headless", cfg.Headless, "Run in headless mode")
+	cmd.Flags().BoolVar(&f.headful, "headful", false, "Run in headful mode (opposite of headless)")
+	cmd.Flags().StringVar(&cfg.ProxyServer, "proxy", "", "HTTP proxy URL")
+	cmd.Flags().BoolVar(&cfg.IgnoreHTTPSErrors, "ignore-tls-errors", cfg.IgnoreHTTPSErrors, "Ignore TLS certificate errors")
+	cmd.Flags().BoolVar(&cfg.FullPage, "full-page", false, "Capture the whole scrollable page instead of the viewport")
+	cmd.Flags().StringVar(&cfg.FixedElements, "fixed-elements", cfg.FixedElements, "Fixed and sticky elements: keep, static (show once where they are in the first viewport) or hide-all (hide everywhere, the first viewport included)")
+	cmd.Flags().BoolVar(&cfg.PreloadScroll, "preload-scroll", false, "Scroll through the page before capturing to load scroll-triggered content")
+	cmd.Flags().StringArrayVar(&f.masks, "mask", nil, "Elements to mask as SELECTOR or SELECTOR=STYLE, STYLE being hide, blackout, blur or box:COLOR (can be repeated)")
+	cmd.Flags().StringArrayVar(&f.waitSelectors, "wait-selector", nil, "CSS selector to wait for (can be repeated)")
+	cmd.Flags().StringVar(&cfg.InjectCSS, "inject-css", "", "Custom CSS to inject")
+	cmd.Flags().StringVar(&cfg.MockTime, "mock-time", "", "Start time of the virtual clock for Date, performance.now and requestAnimationFrame (ISO 8601 format)")
+	cmd.Flags().Float64Var(&cfg.ClockStep, "clock-step", cfg.ClockStep, "Milliseconds the virtual clock advances on every read (0 = frozen between frames)")
+	cmd.Flags().Int64Var(&f.randomSeed, "random-seed", 0, "Seed for Math.random and crypto random values (implied as 0 with --mock-time)")
+	cmd.Flags().StringArrayVar(&f.carouselHooks, "carousel-hook", nil, "Freeze hook for a custom carousel as SELECTOR=FILE.js; the script gets the element as 'element' (can be repeated)")
+	cmd.Flags().StringVar(&cfg.VideoFrame, "video-frame", "", "What videos show: poster, or a time in seconds (default: first frame)")
+	cmd.Flags().StringVar(&cfg.DeterminismProfile, "determinism-profile", cfg.DeterminismProfile, "Determinism profile (default, strict: also pin GPU, canvas and text rendering)")
+	cmd.Flags().StringSliceVar(&cfg.DisableDeterminism, "disable-determinism", nil, "Deterministic scripts to leave out (clock, random, autoplay, intersection, scroll, web-animations, carousel, animated-images, lottie, canvas, all)")
+	cmd.Flags().StringSliceVar(&cfg.EnableDeterminism, "enable-determinism", nil, "Deterministic scripts to keep even if disabled (e.g. with --disable-determinism all)")
+	cmd.Flags().StringArrayVar(&cfg.DeterminismScripts, "determinism-script", nil, "JavaScript file to inject with the deterministic scripts (can be repeated)")
+	cmd.Flags().BoolVar(&cfg.FailOnConsoleError, "fail-on-console-error", false, "Fail when the page logs a console error or throws an uncaught exception")
+	cmd.Flags().BoolVar(&cfg.FailOnHTTPError, "fail-on-http-error", false, "Fail when the page responds with an HTTP error status (4xx, 5xx)")
+	cmd.Flags().StringVar(&cfg.ChromePath, "chrome-path", "", "Path to Chrome executable")
+	cmd.Flags().IntVar(&cfg.Timeout, "timeout", cfg.Timeout, "Navigation timeout in seconds")
+	cmd.Flags().StringVar(&cfg.UserAgent, "user-agent", "", "Custom User-Agent string (overrides preset)")
+	cmd.Flags().StringVar(&cfg.ColorScheme, "color-scheme", "", "Emulated prefers-color-scheme (light, dark)")
+	cmd.Flags().BoolVar(&cfg.ReducedMotion, "reduced-motion", false, "Emulate prefers-reduced-motion: reduce")
+	cmd.Flags().BoolVar(&cfg.ForcedColors, "forced-colors", false, "Emulate forced-colors: active")
+	cmd.Flags().StringVar(&cfg.MediaType, "media", "", "Emulated CSS media type (print, screen)")
+	cmd.Flags().StringVar(&cfg.Timezone, "timezone", cfg.Timezone, "IANA timezone for the page (empty = host timezone)")
+	cmd.Flags().StringVar(&cfg.Locale, "locale", cfg.Locale, "Locale for the page and Accept-Language (empty = host locale)")
+	cmd.Flags().StringVar(&cfg.AcceptLanguage, "accept-language", "", "Accept-Language header (overrides the one derived from --locale)")
+	cmd.Flags().StringVar(&f.geolocation, "geolocation", "", "Emulated position (LAT,LNG or LAT,LNG,ACCURACY)")
+
+	return f
+}
+
+// apply parses the raw flag values into cfg.
+func (f *captureFlags) apply(cfg *record.Config) error {
+	// --random-seed 0 is a seed too, so it is applied whenever given
+	if f.cmd.Flags().Changed("random-seed") {
+		cfg.RandomSeed = &f.randomSeed
+	}
+
+	// Parse viewport if specified (WIDTHxHEIGHT or just WIDTH)
+	if f.viewport != "" {
+		width, height, err := parseSize(f.viewport)
+		if err != nil {
+			return fmt.Errorf("invalid viewport %w", err)
+		}
+		cfg.ViewportWidth = width
+		if height > 0 {
+			cfg.ViewportHeight = height
+		}
+	}
+
+	// Parse resize if specified (WIDTHxHEIGHT or just WIDTH)
+	if f.resize != "" {
+		width, height, err := parseSize(f.resize)
+		if err != nil {
+			return fmt.Errorf("invalid resize %w", err)
+		}
+		cfg.ResizeWidth = width
+		if height > 0 {
+			cfg.ResizeHeight = height
+		}
+	}
+
+	if f.geolocation != "" {
+		geolocation, err := parseGeolocation(f.geolocation)
+		if err != nil {
+			return err
+		}
+		cfg.Geolocation = geolocation
+	}
+
+	for _, value := range f.carouselHooks {
+		hook, err := parseCarouselHook(value)
+		if err != nil {
+			return err
+		}
+		cfg.CarouselHooks = append(cfg.CarouselHooks, hook)
+	}
+
+	for _, value := range f.masks {
+		mask, err := parseMask(value)
+		if err != nil {
+			return err
+		}
+		cfg.Masks = append(cfg.Masks, mask)
+	}
+	cfg.WaitSelectors = f.waitSelectors
+
+	// Handle headful flag
+	if f.headful {
+		cfg.Headless = false
+	}
+
+	return nil
+}
+
+// parseSize parses WIDTH or WIDTHxHEIGHT. A missing height is returned as 0.
+func parseSize(value string) (int, int, error) {
+	parts := strings.Split(value, "x")
+	width, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("width: %s", parts[0])
+	}
+	if len(parts) < 2 {
+		return width, 0, nil
+	}
+	height, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("height: %s", parts[1])
+	}
+	return width, height, nil
+}
+
+// parseGeolocation parses LAT,LNG or LAT,LNG,ACCURACY.
+func parseGeolocation(value string) (*ports.Geolocation, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, fmt.Errorf("invalid geolocation %q (want LAT,LNG or LAT,LNG,ACCURACY)", value)
+	}
+
+	var numbers []float64
+	for _, part := range parts {
+		n, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid geolocation %q: %s is not a number", value, part)
+		}
+		numbers = append(numbers, n)
+	}
+
+	geolocation := &ports.Geolocation{Latitude: numbers[0], Longitude: numbers[1], Accuracy: 10}
+	if len(numbers) == 3 {
+		geolocation.Accuracy = numbers[2]
+	}
+	if geolocation.Latitude < -90 || geolocation.Latitude > 90 || geolocation.Longitude < -180 || geolocation.Longitude > 180 {
+		return nil, fmt.Errorf("invalid geolocation %q: out of range", value)
+	}
+
+	return geolocation, nil
+}
+
+// parseCarouselHook parses SELECTOR=FILE. The selector may contain "=" in
+// attribute selectors, so the file name starts after the last one.
+func parseCarouselHook(value string) (record.CarouselHook, error) {
+	i := strings.LastIndex(value, "=")
+	if i <= 0 || i == len(value)-1 {
+		return record.CarouselHook{}, fmt.Errorf("invalid carousel hook %q (want SELECTOR=FILE)", value)
+	}
+	return record.CarouselHook{
+		Selector:   strings.TrimSpace(value[:i]),
+		ScriptPath: strings.TrimSpace(value[i+1:]),
+	}, nil
+}
+
+// parseMask parses SELECTOR or SELECTOR=STYLE, where STYLE is hide, blackout,
+// blur or box:COLOR. A value whose text after the last "=" is not a style is
+// taken as a selector, so that attribute selectors need no style.
+func parseMask(value string) (ports.Mask, error) {
+	selector, style := value, ""
+	if i := strings.LastIndex(value, "="); i >= 0 {
+		candidate := strings.TrimSpace(value[i+1:])
+		name, _, _ := strings.Cut(candidate, ":")
+		switch name {
+		case ports.MaskHide, ports.MaskBlackout, ports.MaskBlur, ports.MaskBox:
+			selector, style = value[:i], candidate
+		}
+	}
+	selector = strings.TrimSpace(selector)
+	if selector == "" {
+		return ports.Mask{}, fmt.Errorf("invalid mask %q: empty selector", value)
+	}
+
+	mask := ports.Mask{Selector: selector, Style: ports.MaskHide}
+	if style == "" {
+		return mask, nil
+	}
+	name, color, hasColor := strings.Cut(style, ":")
+	mask.Style = name
+	if name == ports.MaskBox {
+		if !hasColor || color == "" || strings.ContainsAny(color, ";{}") {
+			return ports.Mask{}, fmt.Errorf("invalid mask %q (want box:COLOR, e.g. box:#ff00ff)", value)
+		}
+		mask.Color = color
+	} else if hasColor {
+		return ports.Mask{}, fmt.Errorf("invalid mask %q: only box takes a color", value)
+	}
+	return mask, nil
+}
+
+// addCompareFlags registers the image comparison settings shared by every
+// subcommand that produces a diff. Output and digest paths are left to the
+// caller.
+func addCompareFlags(cmd *cobra.Command, cfg *compare.Config) {
+	cmd.Flags().IntVar(&cfg.ColorThreshold, "color-threshold", cfg.ColorThreshold, "Per-pixel color difference threshold (0-255)")
+	cmd.Flags().BoolVar(&cfg.IgnoreAntialiasing, "ignore-antialiasing", cfg.IgnoreAntialiasing, "Ignore antialiased pixels")
+	cmd.Flags().StringVar(&cfg.DiffLayout, "diff-layout", cfg.DiffLayout, "Diff image layout: horizontal, vertical, diff-only, pair or raw-pixelmatch")
+	cmd.Flags().BoolVar(&cfg.CropToChanges, "crop-to-changes", cfg.CropToChanges, "Render only the changed regions, with some context, stacked as a gallery")
+	cmd.Flags().StringVar(&cfg.SizeMismatch, "size-mismatch", cfg.SizeMismatch, "How to compare images of different sizes: pad (with magenta), crop (to the common area), fail or scale (current to baseline)")
+	cmd.Flags().BoolVar(&cfg.SkipDiffOnEqual, "skip-diff-on-equal", cfg.SkipDiffOnEqual, "Write no diff image when the images have the same pixels")
+	cmd.Flags().StringVar(&cfg.LabelFontPath, "label-font", "", "Path to TrueType font file for labels (optional)")
+	cmd.Flags().Float64Var(&cfg.LabelFontSize, "label-font-size", 14, "Font size for labels in points")
+	cmd.Flags().StringVar(&cfg.BaselineLabel, "baseline-label", cfg.BaselineLabel, "Label text for the baseline panel")
+	cmd.Flags().StringVar(&cfg.DiffLabel, "diff-label", cfg.DiffLabel, "Label text for the diff panel")
+	cmd.Flags().StringVar(&cfg.CurrentLabel, "current-label", cfg.CurrentLabel, "Label text for the current panel")
+}
+
+// addServeFlags registers the managed dev server settings of the commands
+// that capture URLs.
+func addServeFlags(cmd *cobra.Command, cfg *record.Config) {
+	cmd.Flags().StringVar(&cfg.ServeCommand, "serve-cmd", "", "Shell command that starts a local server for the capture")
+	cmd.Flags().StringVar(&cfg.ServeURL, "serve-url", "", "URL polled until the --serve-cmd server answers")
+	cmd.Flags().IntVar(&cfg.ServeTimeout, "serve-timeout", cfg.ServeTimeout, "Seconds to wait for --serve-url to answer")
+}
+
+// checkServeFlags rejects a --serve-cmd without the --serve-url that tells
+// when the server is ready.
+func checkServeFlags(cfg *record.Config) error {
+	if cfg.ServeCommand != "" && cfg.ServeURL == "" {
+		return fmt.Errorf("--serve-url is required with --serve-cmd")
+	}
+	return nil
+}
+
+// addToleranceFlags registers how far elements may move or resize before
+// the layout comparison reports them.
+func addToleranceFlags(cmd *cobra.Command, tol *layout.Tolerance) {
+	cmd.Flags().Float64Var(&tol.Position, "position-tolerance", tol.Position, "CSS pixels an element may move before it counts as moved")
+	cmd.Flags().Float64Var(&tol.Size, "size-tolerance", tol.Size, "CSS pixels an element may grow or shrink before it counts as resized")
+}
+
+// addFormatFlags registers the image encoding settings of the written
+// images.
+func addFormatFlags(cmd *cobra.Command, opts *ports.EncodeOptions) {
+	cmd.Flags().StringVar(&opts.Format, "format", "", "Image format: png, jpeg or webp (lossless) (default: from the output extension)")
+	cmd.Flags().IntVar(&opts.Quality, "quality", imageformat.DefaultQuality, "JPEG quality (1-100)")
+	cmd.Flags().StringVar(&opts.Compression, "png-compression", ports.CompressionDefault, "PNG compression level: default, speed, best or none")
+}
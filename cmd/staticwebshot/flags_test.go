@@ -0,0 +1,164 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ideamans/static-webshot/pkg/record"
+)
+
+func TestParseGeolocation(t *testing.T) {
+	tests := []struct {
+		value        string
+		wantLat      float64
+		wantLng      float64
+		wantAccuracy float64
+		wantErr      bool
+	}{
+		{value: "35.6812,139.7671", wantLat: 35.6812, wantLng: 139.7671, wantAccuracy: 10},
+		{value: "51.5, -0.12, 50", wantLat: 51.5, wantLng: -0.12, wantAccuracy: 50},
+		{value: "35.6812", wantErr: true},
+		{value: "north,east", wantErr: true},
+		{value: "91,0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseGeolocation(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseGeolocation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Latitude != tt.wantLat || got.Longitude != tt.wantLng || got.Accuracy != tt.wantAccuracy {
+				t.Errorf("parseGeolocation() = %+v, want %v,%v,%v", *got, tt.wantLat, tt.wantLng, tt.wantAccuracy)
+			}
+		})
+	}
+}
+
+func TestParseCarouselHook(t *testing.T) {
+	tests := []struct {
+		value        string
+		wantSelector string
+		wantPath     string
+		wantErr      bool
+	}{
+		{value: ".hero=hero.js", wantSelector: ".hero", wantPath: "hero.js"},
+		{value: `[data-carousel="main"]=hooks/main.js`, wantSelector: `[data-carousel="main"]`, wantPath: "hooks/main.js"},
+		{value: ".hero", wantErr: true},
+		{value: "=hero.js", wantErr: true},
+		{value: ".hero=", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseCarouselHook(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCarouselHook() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Selector != tt.wantSelector || got.ScriptPath != tt.wantPath {
+				t.Errorf("parseCarouselHook() = %+v, want %s=%s", got, tt.wantSelector, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestParseMask(t *testing.T) {
+	tests := []struct {
+		value        string
+		wantSelector string
+		wantStyle    string
+		wantColor    string
+		wantErr      bool
+	}{
+		{value: ".ad", wantSelector: ".ad", wantStyle: "hide"},
+		{value: ".ad=blackout", wantSelector: ".ad", wantStyle: "blackout"},
+		{value: ".avatar = blur", wantSelector: ".avatar", wantStyle: "blur"},
+		{value: ".date=box:#ff00ff", wantSelector: ".date", wantStyle: "box", wantColor: "#ff00ff"},
+		{value: `[data-role="ad"]`, wantSelector: `[data-role="ad"]`, wantStyle: "hide"},
+		{value: `[data-role=ad]=hide`, wantSelector: `[data-role=ad]`, wantStyle: "hide"},
+		{value: ".date=box", wantErr: true},
+		{value: ".date=box:red;color:blue", wantErr: true},
+		{value: ".ad=blur:#000", wantErr: true},
+		{value: "=blur", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseMask(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseMask() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Selector != tt.wantSelector || got.Style != tt.wantStyle || got.Color != tt.wantColor {
+				t.Errorf("parseMask() = %+v, want %s=%s %s", got, tt.wantSelector, tt.wantStyle, tt.wantColor)
+			}
+		})
+	}
+}
+
+func TestCaptureFlags_RandomSeed(t *testing.T) {
+	tests := []struct {
+		args []string
+		want *int64
+	}{
+		{args: nil, want: nil},
+		{args: []string{"--random-seed", "0"}, want: new(int64)},
+		{args: []string{"--random-seed", "42"}, want: func() *int64 { seed := int64(42); return &seed }()},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			cmd := &cobra.Command{}
+			cfg := record.DefaultConfig()
+			flags := addCaptureFlags(cmd, &cfg)
+			if err := cmd.ParseFlags(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if err := flags.apply(&cfg); err != nil {
+				t.Fatalf("apply() error = %v", err)
+			}
+
+			switch {
+			case tt.want == nil && cfg.RandomSeed != nil:
+				t.Errorf("RandomSeed = %d, want unset", *cfg.RandomSeed)
+			case tt.want != nil && (cfg.RandomSeed == nil || *cfg.RandomSeed != *tt.want):
+				t.Errorf("RandomSeed = %v, want %d", cfg.RandomSeed, *tt.want)
+			}
+		})
+	}
+}
+
+func TestServeFlags(t *testing.T) {
+	tests := []struct {
+		args    []string
+		wantErr bool
+	}{
+		{args: nil},
+		{args: []string{"--serve-cmd", "npm run preview", "--serve-url", "http://localhost:4173/"}},
+		{args: []string{"--serve-cmd", "npm run preview"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			cmd := &cobra.Command{}
+			cfg := record.DefaultConfig()
+			addServeFlags(cmd, &cfg)
+			if err := cmd.ParseFlags(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if err := checkServeFlags(&cfg); (err != nil) != tt.wantErr {
+				t.Errorf("checkServeFlags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
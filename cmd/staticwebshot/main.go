@@ -2,8 +2,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/ideamans/go-llm-cli-kit/llmcmd"
 	"github.com/spf13/cobra"
@@ -45,6 +48,11 @@ func newRootCmd() *cobra.Command {
 	// Add subcommands
 	rootCmd.AddCommand(newCaptureCmd())
 	rootCmd.AddCommand(newCompareCmd())
+	rootCmd.AddCommand(newCompareLayoutCmd())
+	rootCmd.AddCommand(newCompareA11yCmd())
+	rootCmd.AddCommand(newCompareRevsCmd())
+	rootCmd.AddCommand(newDiffURLsCmd())
+	rootCmd.AddCommand(newPresetsCmd())
 
 	// `static-webshot llm` prints the embedded reference for AI agents.
 	llmcmd.AddTo(rootCmd, llmConfig())
@@ -64,8 +72,23 @@ func main() {
 		return
 	}
 
-	if err := newRootCmd().Execute(); err != nil {
+	if err := run(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
+
+// run executes the command tree with a context cancelled on SIGINT or
+// SIGTERM, so a running command unwinds and its cleanup (stopping a dev
+// server, removing worktrees, closing the browser) still runs. A second
+// signal falls back to the default behaviour and terminates at once.
+func run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	return newRootCmd().ExecuteContext(ctx)
+}
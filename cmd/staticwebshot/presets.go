@@ -0,0 +1,97 @@
+// Package main provides the presets subcommand.
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ideamans/static-webshot/pkg/adapters/osfilesystem"
+	"github.com/ideamans/static-webshot/pkg/record"
+)
+
+func newPresetsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "presets",
+		Short: "Inspect device presets",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(newPresetsListCmd())
+
+	return cmd
+}
+
+func newPresetsListCmd() *cobra.Command {
+	var presetsFile string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the available device presets",
+		Long: `List the available device presets.
+
+Shows the built-in presets, merged with the custom presets from
+--presets-file when given. A presets file maps preset names to settings,
+in JSON (.json) or YAML (anything else):
+
+  kiosk:
+    description: Portrait kiosk display
+    viewportWidth: 1080
+    viewportHeight: 1920
+    deviceScaleFactor: 1
+    isMobile: false
+    hasTouch: true
+    userAgent: "Mozilla/5.0 ..."
+    colorScheme: dark
+
+Examples:
+  static-webshot presets list
+  static-webshot presets list --presets-file presets.yaml
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			presets := record.Presets
+			if presetsFile != "" {
+				var err error
+				presets, err = record.LoadPresets(osfilesystem.New(), presetsFile)
+				if err != nil {
+					return err
+				}
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tVIEWPORT\tDPR\tMOBILE\tTOUCH\tCOLOR SCHEME\tDESCRIPTION")
+			for _, name := range record.PresetNames(presets) {
+				preset := presets[name]
+				dpr := preset.DeviceScaleFactor
+				if dpr == 0 {
+					dpr = 1
+				}
+				colorScheme := preset.ColorScheme
+				if colorScheme == "" {
+					colorScheme = "-"
+				}
+				fmt.Fprintf(w, "%s\t%dx%d\t%s\t%t\t%t\t%s\t%s\n",
+					name,
+					preset.ViewportWidth,
+					preset.ViewportHeight,
+					strconv.FormatFloat(dpr, 'g', -1, 64),
+					preset.IsMobile,
+					preset.HasTouch,
+					colorScheme,
+					preset.Description,
+				)
+			}
+			return w.Flush()
+		},
+	}
+
+	cmd.Flags().StringVar(&presetsFile, "presets-file", "", "JSON or YAML file with custom device presets")
+
+	return cmd
+}
@@ -5,12 +5,14 @@ go 1.24.0
 toolchain go1.24.7
 
 require (
+	github.com/HugoSmits86/nativewebp v0.9.3
 	github.com/chromedp/cdproto v0.0.0-20241022234722-4d5d5faf59fb
 	github.com/chromedp/chromedp v0.11.2
 	github.com/orisano/pixelmatch v0.0.0-20230914042517-fa304d1dc785
 	github.com/playwright-community/playwright-go v0.5200.1
 	github.com/spf13/cobra v1.10.2
 	golang.org/x/image v0.35.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,92 @@
+// Package portstest provides fakes of the ports for tests.
+package portstest
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
+// Browser is a fake ports.Browser that renders every URL as a solid 20x10
+// image of the color mapped to it, red by default.
+type Browser struct {
+	// Colors maps URLs to the color of their screenshot.
+	Colors map[string]color.Color
+
+	// PageDiagnostics maps URLs to the diagnostics of their page.
+	PageDiagnostics map[string]ports.PageDiagnostics
+
+	// Masked is returned by ApplyMasks.
+	Masked []ports.MaskedRect
+
+	// Launches counts the calls to Launch.
+	Launches int
+
+	current string
+}
+
+func (b *Browser) Launch(ctx context.Context, opts ports.BrowserOptions) error {
+	b.Launches++
+	return nil
+}
+
+func (b *Browser) Version(ctx context.Context) (string, error) {
+	return "HeadlessChrome/120.0.0.0", nil
+}
+
+func (b *Browser) Navigate(ctx context.Context, url string) error {
+	b.current = url
+	return nil
+}
+
+func (b *Browser) InjectScript(ctx context.Context, script string) error      { return nil }
+func (b *Browser) InjectCSS(ctx context.Context, css string) error            { return nil }
+func (b *Browser) WaitForSelector(ctx context.Context, selector string) error { return nil }
+func (b *Browser) WaitForFonts(ctx context.Context) error                     { return nil }
+func (b *Browser) WaitForImages(ctx context.Context) error                    { return nil }
+func (b *Browser) Close() error                                               { return nil }
+
+func (b *Browser) Diagnostics() ports.PageDiagnostics {
+	return b.PageDiagnostics[b.current]
+}
+
+func (b *Browser) Evaluate(ctx context.Context, expression string, result any) error {
+	return nil
+}
+
+func (b *Browser) AccessibilityTree(ctx context.Context) ([]ports.AXNode, error) {
+	return nil, nil
+}
+
+func (b *Browser) ApplyMasks(ctx context.Context, masks []ports.Mask) ([]ports.MaskedRect, error) {
+	return b.Masked, nil
+}
+
+func (b *Browser) FullPageScreenshot(ctx context.Context) ([]byte, error) {
+	return b.Screenshot(ctx)
+}
+
+func (b *Browser) Screenshot(ctx context.Context) ([]byte, error) {
+	c, ok := b.Colors[b.current]
+	if !ok {
+		c = color.RGBA{R: 255, A: 255}
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 20; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Ensure Browser implements ports.Browser
+var _ ports.Browser = (*Browser)(nil)
@@ -0,0 +1,189 @@
+// Package a11y records the accessibility tree of a captured page as a JSON
+// sidecar and compares it between captures.
+package a11y
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
+// Snapshot is the accessibility tree of a captured page, flattened in
+// document order.
+type Snapshot struct {
+	// URL is the URL of the captured page.
+	URL string `json:"url"`
+
+	Nodes []Node `json:"nodes"`
+}
+
+// Node is a node of the accessibility tree exposed to assistive technology.
+type Node struct {
+	// Path locates the node from the root by role and position among
+	// siblings of the same role ("main > list > listitem[2] > link"). It
+	// identifies the node across captures.
+	Path string `json:"path"`
+
+	Role string `json:"role"`
+	Name string `json:"name,omitempty"`
+
+	// States are the recorded states of the node (see States).
+	States map[string]string `json:"states,omitempty"`
+}
+
+// States are the node properties recorded in a snapshot.
+var States = []string{
+	"level",
+	"checked",
+	"pressed",
+	"expanded",
+	"selected",
+	"disabled",
+	"required",
+	"invalid",
+	"readonly",
+	"haspopup",
+	"modal",
+}
+
+// transparentRoles are roles whose nodes are left out of a snapshot, with
+// their children taking their place.
+var transparentRoles = map[string]bool{
+	"generic":      true,
+	"none":         true,
+	"presentation": true,
+	"LineBreak":    true,
+}
+
+// textRoles are roles whose nodes, with their children, are left out of a
+// snapshot; their text is part of the name of their ancestors.
+var textRoles = map[string]bool{
+	"StaticText":    true,
+	"InlineTextBox": true,
+}
+
+// Build flattens the accessibility tree returned by the browser into a
+// snapshot. Ignored nodes, text nodes and nodes without semantics are left
+// out.
+func Build(url string, nodes []ports.AXNode) *Snapshot {
+	s := &Snapshot{URL: url, Nodes: []Node{}}
+	if len(nodes) == 0 {
+		return s
+	}
+
+	byID := make(map[string]*ports.AXNode, len(nodes))
+	for i := range nodes {
+		byID[nodes[i].ID] = &nodes[i]
+	}
+
+	// exposed returns the nodes that stand in for the children of n
+	var exposed func(n *ports.AXNode) []*ports.AXNode
+	exposed = func(n *ports.AXNode) []*ports.AXNode {
+		var children []*ports.AXNode
+		for _, id := range n.ChildIDs {
+			child := byID[id]
+			switch {
+			case child == nil, textRoles[child.Role]:
+			case child.Ignored, transparentRoles[child.Role]:
+				children = append(children, exposed(child)...)
+			default:
+				children = append(children, child)
+			}
+		}
+		return children
+	}
+
+	var walk func(n *ports.AXNode, path string)
+	walk = func(n *ports.AXNode, path string) {
+		children := exposed(n)
+		for i, child := range children {
+			segment := child.Role
+			if count, index := sameRole(children, i); count > 1 {
+				segment += fmt.Sprintf("[%d]", index)
+			}
+			childPath := segment
+			if path != "" {
+				childPath = path + " > " + segment
+			}
+			s.Nodes = append(s.Nodes, Node{
+				Path:   childPath,
+				Role:   child.Role,
+				Name:   child.Name,
+				States: states(child.Properties),
+			})
+			walk(child, childPath)
+		}
+	}
+
+	// The root is the document itself; paths start below it
+	walk(&nodes[0], "")
+	return s
+}
+
+// sameRole returns how many of the siblings share the role of siblings[i],
+// and the 1-based position of siblings[i] among them.
+func sameRole(siblings []*ports.AXNode, i int) (count, index int) {
+	for j, sibling := range siblings {
+		if sibling.Role == siblings[i].Role {
+			count++
+			if j <= i {
+				index++
+			}
+		}
+	}
+	return count, index
+}
+
+// states picks the recorded states from the node properties.
+func states(properties map[string]string) map[string]string {
+	var picked map[string]string
+	for _, name := range States {
+		if value, ok := properties[name]; ok {
+			if picked == nil {
+				picked = make(map[string]string)
+			}
+			picked[name] = value
+		}
+	}
+	return picked
+}
+
+// Index maps the node paths of the snapshot to their nodes.
+func (s *Snapshot) Index() map[string]*Node {
+	index := make(map[string]*Node, len(s.Nodes))
+	for i := range s.Nodes {
+		index[s.Nodes[i].Path] = &s.Nodes[i]
+	}
+	return index
+}
+
+// SidecarPath returns the accessibility snapshot path for an image path
+// ("shots/home.png" -> "shots/home.a11y.json").
+func SidecarPath(imagePath string) string {
+	return strings.TrimSuffix(imagePath, filepath.Ext(imagePath)) + ".a11y.json"
+}
+
+// Write saves the snapshot as JSON to path.
+func Write(fs ports.FileSystem, path string, s *Snapshot) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal accessibility snapshot: %w", err)
+	}
+	return fs.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Read loads a snapshot written by Write.
+func Read(fs ports.FileSystem, path string) (*Snapshot, error) {
+	data, err := fs.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parse accessibility snapshot %s: %w", path, err)
+	}
+	return &s, nil
+}
@@ -0,0 +1,93 @@
+package a11y
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ideamans/static-webshot/pkg/adapters/osfilesystem"
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
+func TestBuild(t *testing.T) {
+	nodes := []ports.AXNode{
+		{ID: "1", Role: "RootWebArea", Name: "Home", ChildIDs: []string{"2", "3"}},
+		{ID: "2", Role: "generic", ChildIDs: []string{"4", "5"}},
+		{ID: "3", Role: "main", ChildIDs: []string{"6", "7", "8"}},
+		{ID: "4", Role: "heading", Name: "Welcome", Properties: map[string]string{"level": "1", "focusable": "false"}, ChildIDs: []string{"9"}},
+		{ID: "5", Ignored: true, ChildIDs: []string{"10"}},
+		{ID: "6", Role: "button", Name: "Search"},
+		{ID: "7", Role: "button", Name: "Menu", Properties: map[string]string{"expanded": "false"}},
+		{ID: "8", Role: "StaticText", Name: "Hello"},
+		{ID: "9", Role: "StaticText", Name: "Welcome"},
+		{ID: "10", Role: "link", Name: "Skip"},
+	}
+
+	got := Build("https://example.com/", nodes)
+	want := &Snapshot{URL: "https://example.com/", Nodes: []Node{
+		{Path: "heading", Role: "heading", Name: "Welcome", States: map[string]string{"level": "1"}},
+		{Path: "link", Role: "link", Name: "Skip"},
+		{Path: "main", Role: "main"},
+		{Path: "main > button[1]", Role: "button", Name: "Search"},
+		{Path: "main > button[2]", Role: "button", Name: "Menu", States: map[string]string{"expanded": "false"}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Build() = %+v, want %+v", got, want)
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	fs := osfilesystem.New()
+	path := SidecarPath(filepath.Join(t.TempDir(), "home.png"))
+	if filepath.Base(path) != "home.a11y.json" {
+		t.Fatalf("SidecarPath() = %q", path)
+	}
+
+	want := &Snapshot{URL: "https://example.com/", Nodes: []Node{{Path: "main", Role: "main"}}}
+	if err := Write(fs, path, want); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	got, err := Read(fs, path)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Read() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	baseline := &Snapshot{Nodes: []Node{
+		{Path: "banner", Role: "banner"},
+		{Path: "banner > button", Role: "button", Name: "Search"},
+		{Path: "main", Role: "main"},
+		{Path: "main > heading", Role: "heading", Name: "Pricing", States: map[string]string{"level": "2"}},
+		{Path: "main > list", Role: "list"},
+		{Path: "main > list > listitem", Role: "listitem", Name: "Free"},
+	}}
+	current := &Snapshot{Nodes: []Node{
+		{Path: "banner", Role: "banner"},
+		{Path: "banner > button", Role: "button"},
+		{Path: "main", Role: "main"},
+		{Path: "main > heading", Role: "heading", Name: "Pricing", States: map[string]string{"level": "3"}},
+		{Path: "main > checkbox", Role: "checkbox", Name: "Yearly", States: map[string]string{"checked": "false"}},
+	}}
+
+	var got []string
+	for _, change := range Diff(baseline, current) {
+		got = append(got, change.Description)
+	}
+	want := []string{
+		`button "Search" lost its name`,
+		`heading "Pricing" changed level from 2 to 3`,
+		`list was removed`,
+		`checkbox "Yearly" was added`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() = %q, want %q", got, want)
+	}
+
+	if changes := Diff(baseline, baseline); len(changes) != 0 {
+		t.Errorf("Diff() of identical snapshots = %+v, want none", changes)
+	}
+}
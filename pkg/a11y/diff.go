@@ -0,0 +1,161 @@
+package a11y
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kinds of node changes.
+const (
+	KindAdded   = "added"
+	KindRemoved = "removed"
+	KindRenamed = "renamed"
+	KindStates  = "states"
+)
+
+// Change is how a node differs between the baseline and current snapshots.
+type Change struct {
+	Path        string   `json:"path"`
+	Kinds       []string `json:"kinds"`
+	Baseline    *Node    `json:"baseline,omitempty"`
+	Current     *Node    `json:"current,omitempty"`
+	Description string   `json:"description"`
+}
+
+// Has reports whether the change is of the given kind.
+func (c *Change) Has(kind string) bool {
+	for _, k := range c.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// CompareNodes returns how a node changed, or nil if it did not. A nil
+// baseline means the node was added, a nil current that it was removed.
+func CompareNodes(baseline, current *Node) *Change {
+	switch {
+	case baseline == nil && current == nil:
+		return nil
+	case baseline == nil:
+		return &Change{Path: current.Path, Kinds: []string{KindAdded}, Current: current, Description: label(current) + " was added"}
+	case current == nil:
+		return &Change{Path: baseline.Path, Kinds: []string{KindRemoved}, Baseline: baseline, Description: label(baseline) + " was removed"}
+	}
+
+	var kinds, details []string
+	if baseline.Name != current.Name {
+		kinds = append(kinds, KindRenamed)
+		switch {
+		case current.Name == "":
+			details = append(details, "lost its name")
+		case baseline.Name == "":
+			details = append(details, fmt.Sprintf("gained the name %q", current.Name))
+		default:
+			details = append(details, fmt.Sprintf("changed name from %q to %q", baseline.Name, current.Name))
+		}
+	}
+
+	statesChanged := false
+	for _, name := range States {
+		before, after := baseline.States[name], current.States[name]
+		if before == after {
+			continue
+		}
+		statesChanged = true
+		details = append(details, fmt.Sprintf("changed %s from %s to %s", name, stateValue(before), stateValue(after)))
+	}
+	if statesChanged {
+		kinds = append(kinds, KindStates)
+	}
+
+	if len(kinds) == 0 {
+		return nil
+	}
+	return &Change{
+		Path:        current.Path,
+		Kinds:       kinds,
+		Baseline:    baseline,
+		Current:     current,
+		Description: label(baseline) + " " + joinDetails(details),
+	}
+}
+
+// Diff returns the changes of all nodes between two snapshots: those of the
+// baseline in tree order, then the added ones. Descendants of an added or
+// removed node are left out.
+func Diff(baseline, current *Snapshot) []Change {
+	baselineIndex := baseline.Index()
+	currentIndex := current.Index()
+
+	var changes []Change
+	for i := range baseline.Nodes {
+		node := &baseline.Nodes[i]
+		if change := CompareNodes(node, currentIndex[node.Path]); change != nil {
+			changes = append(changes, *change)
+		}
+	}
+	for i := range current.Nodes {
+		node := &current.Nodes[i]
+		if baselineIndex[node.Path] == nil {
+			changes = append(changes, *CompareNodes(nil, node))
+		}
+	}
+
+	kept := make([]Change, 0, len(changes))
+	for _, change := range changes {
+		if !withAncestor(change, kept) {
+			kept = append(kept, change)
+		}
+	}
+	return kept
+}
+
+// withAncestor reports whether the change was added or removed along with
+// one of its ancestors.
+func withAncestor(c Change, candidates []Change) bool {
+	for _, ancestor := range candidates {
+		if !strings.HasPrefix(c.Path, ancestor.Path+" > ") {
+			continue
+		}
+		if (c.Has(KindAdded) && ancestor.Has(KindAdded)) || (c.Has(KindRemoved) && ancestor.Has(KindRemoved)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Count returns the number of changes of each kind.
+func Count(changes []Change) map[string]int {
+	counts := make(map[string]int)
+	for _, change := range changes {
+		for _, kind := range change.Kinds {
+			counts[kind]++
+		}
+	}
+	return counts
+}
+
+// label names a node by role and name: `button "Search"`.
+func label(n *Node) string {
+	if n.Name == "" {
+		return n.Role
+	}
+	return fmt.Sprintf("%s %q", n.Role, n.Name)
+}
+
+func stateValue(v string) string {
+	if v == "" {
+		return "unset"
+	}
+	return v
+}
+
+// joinDetails joins details as "a, b and c".
+func joinDetails(details []string) string {
+	if len(details) <= 1 {
+		return strings.Join(details, "")
+	}
+	return strings.Join(details[:len(details)-1], ", ") + " and " + details[len(details)-1]
+}
@@ -0,0 +1,78 @@
+package chromebrowser
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/chromedp/cdproto/accessibility"
+	"github.com/chromedp/chromedp"
+
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
+// AccessibilityTree returns the nodes of the page's accessibility tree, the
+// root first.
+func (b *Browser) AccessibilityTree(ctx context.Context) ([]ports.AXNode, error) {
+	var nodes []*accessibility.Node
+
+	done := make(chan error, 1)
+	go func() {
+		done <- chromedp.Run(b.ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+			if err := accessibility.Enable().Do(ctx); err != nil {
+				return err
+			}
+			var err error
+			nodes, err = accessibility.GetFullAXTree().Do(ctx)
+			return err
+		}))
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case err := <-done:
+		if err != nil {
+			return nil, err
+		}
+		return convertAXNodes(nodes), nil
+	}
+}
+
+// convertAXNodes converts CDP accessibility nodes to ports.AXNode values.
+func convertAXNodes(nodes []*accessibility.Node) []ports.AXNode {
+	converted := make([]ports.AXNode, 0, len(nodes))
+	for _, node := range nodes {
+		n := ports.AXNode{
+			ID:       string(node.NodeID),
+			ParentID: string(node.ParentID),
+			Ignored:  node.Ignored,
+			Role:     axValue(node.Role),
+			Name:     axValue(node.Name),
+		}
+		for _, id := range node.ChildIDs {
+			n.ChildIDs = append(n.ChildIDs, string(id))
+		}
+		for _, prop := range node.Properties {
+			if n.Properties == nil {
+				n.Properties = make(map[string]string)
+			}
+			n.Properties[string(prop.Name)] = axValue(prop.Value)
+		}
+		converted = append(converted, n)
+	}
+	return converted
+}
+
+// axValue renders an accessibility value as a string: strings as they are,
+// other JSON values (booleans, numbers) as their JSON text.
+func axValue(v *accessibility.Value) string {
+	if v == nil || len(v.Value) == 0 {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(v.Value, &s); err == nil {
+		return s
+	}
+	return strings.TrimSpace(string(v.Value))
+}
@@ -0,0 +1,40 @@
+package chromebrowser
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/chromedp/cdproto/accessibility"
+
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
+func TestConvertAXNodes(t *testing.T) {
+	value := func(raw string) *accessibility.Value {
+		return &accessibility.Value{Value: []byte(raw)}
+	}
+
+	got := convertAXNodes([]*accessibility.Node{
+		{NodeID: "1", Role: value(`"RootWebArea"`), Name: value(`"Home"`), ChildIDs: []accessibility.NodeID{"2"}},
+		{
+			NodeID:   "2",
+			ParentID: "1",
+			Role:     value(`"heading"`),
+			Name:     value(`"Pricing"`),
+			Properties: []*accessibility.Property{
+				{Name: accessibility.PropertyNameLevel, Value: value(`2`)},
+				{Name: accessibility.PropertyNameFocusable, Value: value(`true`)},
+			},
+		},
+		{NodeID: "3", Ignored: true},
+	})
+
+	want := []ports.AXNode{
+		{ID: "1", Role: "RootWebArea", Name: "Home", ChildIDs: []string{"2"}},
+		{ID: "2", ParentID: "1", Role: "heading", Name: "Pricing", Properties: map[string]string{"level": "2", "focusable": "true"}},
+		{ID: "3", Ignored: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("convertAXNodes() = %+v, want %+v", got, want)
+	}
+}
@@ -4,12 +4,16 @@ package chromebrowser
 import (
 	"context"
 	"fmt"
+	"math"
 	"strings"
 	"time"
 
+	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/cdp"
 	"github.com/chromedp/cdproto/emulation"
 	"github.com/chromedp/cdproto/network"
 	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/runtime"
 	"github.com/chromedp/chromedp"
 
 	"github.com/ideamans/static-webshot/pkg/ports"
@@ -21,6 +25,7 @@ type Browser struct {
 	allocCancel context.CancelFunc
 	ctx         context.Context
 	cancel      context.CancelFunc
+	diagnostics *diagnosticsCollector
 }
 
 // New creates a new Browser.
@@ -73,6 +78,19 @@ func (b *Browser) Launch(ctx context.Context, opts ports.BrowserOptions) error {
 		chromedpOpts = append(chromedpOpts, chromedp.Flag("proxy-server", opts.ProxyServer))
 	}
 
+	// Software rendering with grayscale, unhinted text for identical output
+	// on every machine
+	if opts.StrictRendering {
+		for _, flag := range strictRenderingFlags {
+			chromedpOpts = append(chromedpOpts, chromedp.Flag(flag.name, flag.value))
+		}
+	}
+
+	// Browser UI language, the fallback for anything the overrides below miss
+	if opts.Locale != "" {
+		chromedpOpts = append(chromedpOpts, chromedp.Flag("lang", opts.Locale))
+	}
+
 	b.allocCtx, b.allocCancel = chromedp.NewExecAllocator(ctx, chromedpOpts...)
 	b.ctx, b.cancel = chromedp.NewContext(b.allocCtx)
 
@@ -88,17 +106,159 @@ func (b *Browser) Launch(ctx context.Context, opts ports.BrowserOptions) error {
 	}
 
 	// Set viewport with device emulation
+	scaleFactor := opts.DeviceScaleFactor
+	if scaleFactor <= 0 {
+		scaleFactor = 1
+	}
 	if err := chromedp.Run(b.ctx,
-		emulation.SetDeviceMetricsOverride(int64(width), int64(height), 1, opts.IsMobile),
+		emulation.SetDeviceMetricsOverride(int64(width), int64(height), scaleFactor, opts.IsMobile),
 	); err != nil {
 		return fmt.Errorf("set viewport: %w", err)
 	}
 
+	// Collect page diagnostics from the events of the now running target
+	b.diagnostics = newDiagnosticsCollector(cdp.FrameID(chromedp.FromContext(b.ctx).Target.TargetID))
+	chromedp.ListenTarget(b.ctx, b.diagnostics.handle)
+
+	// Touch support is what most sites use to pick their touch layouts
+	if opts.HasTouch {
+		if err := chromedp.Run(b.ctx,
+			emulation.SetTouchEmulationEnabled(true).WithMaxTouchPoints(5),
+		); err != nil {
+			return fmt.Errorf("set touch emulation: %w", err)
+		}
+	}
+
+	// Emulate media type and user preference media features
+	if media, features := emulatedMedia(opts); media != "" || len(features) > 0 {
+		if err := chromedp.Run(b.ctx,
+			emulation.SetEmulatedMedia().WithMedia(media).WithFeatures(features),
+		); err != nil {
+			return fmt.Errorf("set emulated media: %w", err)
+		}
+	}
+
+	if err := b.emulateRegion(opts); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// strictRenderingFlags are the Chrome flags of the strict rendering profile.
+// Text rendering and GPU rasterization are what differ between machines
+// running the same Chrome, so both are pinned to their software paths.
+var strictRenderingFlags = []struct {
+	name  string
+	value any
+}{
+	// Rasterize and composite on the CPU; WebGL falls back to SwiftShader
+	{"disable-gpu", true},
+	{"disable-gpu-compositing", true},
+	{"use-gl", "angle"},
+	{"use-angle", "swiftshader"},
+	{"enable-unsafe-swiftshader", true},
+	{"disable-partial-raster", true},
+	{"disable-skia-runtime-opts", true},
+
+	// Grayscale antialiasing on whole pixels, without font hinting
+	{"disable-lcd-text", true},
+	{"disable-font-subpixel-positioning", true},
+	{"font-render-hinting", "none"},
+
+	// Already a chromedp default, repeated so the profile is self-contained
+	{"force-color-profile", "srgb"},
+}
+
+// emulateRegion applies the timezone, locale and geolocation overrides.
+func (b *Browser) emulateRegion(opts ports.BrowserOptions) error {
+	if opts.Timezone != "" {
+		if err := chromedp.Run(b.ctx, emulation.SetTimezoneOverride(opts.Timezone)); err != nil {
+			return fmt.Errorf("set timezone %s: %w", opts.Timezone, err)
+		}
+	}
+
+	if opts.Locale != "" {
+		if err := chromedp.Run(b.ctx, emulation.SetLocaleOverride().WithLocale(opts.Locale)); err != nil {
+			return fmt.Errorf("set locale %s: %w", opts.Locale, err)
+		}
+	}
+
+	// Accept-Language and navigator.languages can only be overridden together
+	// with the user agent, so keep the current one when none is configured
+	acceptLanguage := opts.AcceptLanguage
+	if acceptLanguage == "" {
+		acceptLanguage = AcceptLanguage(opts.Locale)
+	}
+	if acceptLanguage != "" {
+		if err := chromedp.Run(b.ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+			userAgent := opts.UserAgent
+			if userAgent == "" {
+				var err error
+				_, _, _, userAgent, _, err = browser.GetVersion().Do(cdp.WithExecutor(ctx, chromedp.FromContext(ctx).Browser))
+				if err != nil {
+					return err
+				}
+			}
+			return emulation.SetUserAgentOverride(userAgent).WithAcceptLanguage(acceptLanguage).Do(ctx)
+		})); err != nil {
+			return fmt.Errorf("set accept language: %w", err)
+		}
+	}
+
+	if opts.Geolocation != nil {
+		if err := chromedp.Run(b.ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+			grant := browser.GrantPermissions([]browser.PermissionType{browser.PermissionTypeGeolocation})
+			if err := grant.Do(cdp.WithExecutor(ctx, chromedp.FromContext(ctx).Browser)); err != nil {
+				return err
+			}
+			return emulation.SetGeolocationOverride().
+				WithLatitude(opts.Geolocation.Latitude).
+				WithLongitude(opts.Geolocation.Longitude).
+				WithAccuracy(opts.Geolocation.Accuracy).
+				Do(ctx)
+		})); err != nil {
+			return fmt.Errorf("set geolocation: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// AcceptLanguage derives an Accept-Language value from a locale, listing the
+// bare language as a fallback ("ja-JP" -> "ja-JP,ja;q=0.9").
+func AcceptLanguage(locale string) string {
+	if locale == "" {
+		return ""
+	}
+	language, _, found := strings.Cut(locale, "-")
+	if !found || language == "" {
+		return locale
+	}
+	return locale + "," + language + ";q=0.9"
+}
+
+// emulatedMedia returns the CSS media type and media features to emulate.
+func emulatedMedia(opts ports.BrowserOptions) (string, []*emulation.MediaFeature) {
+	var features []*emulation.MediaFeature
+	if opts.ColorScheme != "" {
+		features = append(features, &emulation.MediaFeature{Name: "prefers-color-scheme", Value: opts.ColorScheme})
+	}
+	if opts.ReducedMotion {
+		features = append(features, &emulation.MediaFeature{Name: "prefers-reduced-motion", Value: "reduce"})
+	}
+	if opts.ForcedColors {
+		features = append(features, &emulation.MediaFeature{Name: "forced-colors", Value: "active"})
+	}
+	return opts.MediaType, features
+}
+
 // Navigate loads the specified URL and waits for the load event.
 func (b *Browser) Navigate(ctx context.Context, url string) error {
+	if b.diagnostics != nil {
+		b.diagnostics.reset()
+	}
+
 	done := make(chan error, 1)
 	go func() {
 		done <- chromedp.Run(b.ctx, chromedp.Navigate(url))
@@ -188,23 +348,31 @@ func (b *Browser) WaitForFonts(ctx context.Context) error {
 	}
 }
 
-// WaitForImages waits for all images to be loaded.
+// WaitForImages waits for all images to be loaded, and for the frames and
+// replacements the deterministic scripts register in
+// window.__staticWebshotPending.
 func (b *Browser) WaitForImages(ctx context.Context) error {
 	script := `
-(() => {
+(async () => {
   const images = Array.from(document.querySelectorAll('img'));
-  return Promise.all(images.map(img => {
+  await Promise.all(images.map(img => {
     if (img.complete) return Promise.resolve();
     return new Promise((resolve) => {
       img.addEventListener('load', resolve);
       img.addEventListener('error', resolve);
     });
   }));
+
+  // Work registered while waiting may register more work
+  const pending = window.__staticWebshotPending || [];
+  while (pending.length > 0) {
+    await Promise.all(pending.splice(0));
+  }
 })()
 `
 	done := make(chan error, 1)
 	go func() {
-		done <- chromedp.Run(b.ctx, chromedp.Evaluate(script, nil))
+		done <- chromedp.Run(b.ctx, chromedp.Evaluate(script, nil, awaitPromise))
 	}()
 
 	select {
@@ -215,17 +383,91 @@ func (b *Browser) WaitForImages(ctx context.Context) error {
 	}
 }
 
-// ApplyMasks hides elements matching the given CSS selectors.
-func (b *Browser) ApplyMasks(ctx context.Context, selectors []string) error {
-	if len(selectors) == 0 {
-		return nil
+// Version returns the browser product and version.
+func (b *Browser) Version(ctx context.Context) (string, error) {
+	var product string
+
+	done := make(chan error, 1)
+	go func() {
+		done <- chromedp.Run(b.ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			_, product, _, _, _, err = browser.GetVersion().Do(ctx)
+			return err
+		}))
+	}()
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case err := <-done:
+		if err != nil {
+			return "", err
+		}
+		return product, nil
+	}
+}
+
+// Diagnostics returns the problems reported by the page since the last
+// Navigate.
+func (b *Browser) Diagnostics() ports.PageDiagnostics {
+	if b.diagnostics == nil {
+		return newDiagnosticsCollector("").snapshot()
+	}
+	return b.diagnostics.snapshot()
+}
+
+// Evaluate runs a JavaScript expression in the page and decodes its result.
+func (b *Browser) Evaluate(ctx context.Context, expression string, result any) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- chromedp.Run(b.ctx, chromedp.Evaluate(expression, result, awaitPromise))
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-done:
+		return err
+	}
+}
+
+// awaitPromise makes chromedp.Evaluate wait for a returned promise to settle.
+func awaitPromise(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+	return p.WithAwaitPromise(true)
+}
+
+// ApplyMasks covers the elements matching the masks and returns their areas.
+// Hidden and blurred elements are styled with CSS, so that matching
+// elements added later are covered too; blackout and box masks are drawn
+// as overlays over the elements found now.
+func (b *Browser) ApplyMasks(ctx context.Context, masks []ports.Mask) ([]ports.MaskedRect, error) {
+	if len(masks) == 0 {
+		return nil, nil
 	}
 
 	var cssRules []string
-	for _, selector := range selectors {
-		cssRules = append(cssRules, fmt.Sprintf("%s { visibility: hidden !important; }", selector))
+	for _, mask := range masks {
+		switch mask.Style {
+		case "", ports.MaskHide:
+			cssRules = append(cssRules, fmt.Sprintf("%s { visibility: hidden !important; }", mask.Selector))
+		case ports.MaskBlur:
+			cssRules = append(cssRules, fmt.Sprintf("%s { filter: blur(%dpx) !important; }", mask.Selector, maskBlurRadius))
+		case ports.MaskBlackout, ports.MaskBox:
+		default:
+			return nil, fmt.Errorf("invalid mask style %q", mask.Style)
+		}
+	}
+	if len(cssRules) > 0 {
+		if err := b.InjectCSS(ctx, strings.Join(cssRules, "\n")); err != nil {
+			return nil, err
+		}
+	}
+
+	var rects []ports.MaskedRect
+	if err := b.Evaluate(ctx, GenerateMaskOverlayScript(masks), &rects); err != nil {
+		return nil, err
 	}
-	return b.InjectCSS(ctx, strings.Join(cssRules, "\n"))
+	return rects, nil
 }
 
 // Screenshot captures a viewport screenshot (not full page).
@@ -249,6 +491,45 @@ func (b *Browser) Screenshot(ctx context.Context) ([]byte, error) {
 	}
 }
 
+// FullPageScreenshot captures the whole page, beyond the viewport.
+func (b *Browser) FullPageScreenshot(ctx context.Context) ([]byte, error) {
+	var buf []byte
+
+	done := make(chan error, 1)
+	go func() {
+		done <- chromedp.Run(b.ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+			// Clip to the content size in CSS pixels; the device scale factor
+			// applies on top like for viewport screenshots
+			_, _, _, _, _, contentSize, err := page.GetLayoutMetrics().Do(ctx)
+			if err != nil {
+				return err
+			}
+			buf, err = page.CaptureScreenshot().
+				WithCaptureBeyondViewport(true).
+				WithFromSurface(true).
+				WithClip(&page.Viewport{
+					X:      0,
+					Y:      0,
+					Width:  math.Ceil(contentSize.Width),
+					Height: math.Ceil(contentSize.Height),
+					Scale:  1,
+				}).
+				Do(ctx)
+			return err
+		}))
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case err := <-done:
+		if err != nil {
+			return nil, err
+		}
+		return buf, nil
+	}
+}
+
 // Close shuts down the browser.
 func (b *Browser) Close() error {
 	if b.cancel != nil {
@@ -0,0 +1,79 @@
+package chromebrowser
+
+import (
+	"testing"
+
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
+func TestEmulatedMedia(t *testing.T) {
+	media, features := emulatedMedia(ports.BrowserOptions{})
+	if media != "" || len(features) != 0 {
+		t.Errorf("emulatedMedia() = %q, %d features, want nothing for default options", media, len(features))
+	}
+
+	media, features = emulatedMedia(ports.BrowserOptions{
+		ColorScheme:   "dark",
+		ReducedMotion: true,
+		ForcedColors:  true,
+		MediaType:     "print",
+	})
+	if media != "print" {
+		t.Errorf("emulatedMedia() media = %q, want %q", media, "print")
+	}
+
+	want := map[string]string{
+		"prefers-color-scheme":   "dark",
+		"prefers-reduced-motion": "reduce",
+		"forced-colors":          "active",
+	}
+	if len(features) != len(want) {
+		t.Fatalf("emulatedMedia() returned %d features, want %d", len(features), len(want))
+	}
+	for _, feature := range features {
+		if want[feature.Name] != feature.Value {
+			t.Errorf("feature %s = %q, want %q", feature.Name, feature.Value, want[feature.Name])
+		}
+	}
+}
+
+func TestAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{locale: "", want: ""},
+		{locale: "en", want: "en"},
+		{locale: "en-US", want: "en-US,en;q=0.9"},
+		{locale: "ja-JP", want: "ja-JP,ja;q=0.9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			if got := AcceptLanguage(tt.locale); got != tt.want {
+				t.Errorf("AcceptLanguage(%q) = %q, want %q", tt.locale, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrictRenderingFlags(t *testing.T) {
+	flags := make(map[string]any)
+	for _, flag := range strictRenderingFlags {
+		flags[flag.name] = flag.value
+	}
+
+	want := map[string]any{
+		"disable-gpu":                       true,
+		"disable-lcd-text":                  true,
+		"disable-font-subpixel-positioning": true,
+		"font-render-hinting":               "none",
+		"force-color-profile":               "srgb",
+		"use-angle":                         "swiftshader",
+	}
+	for name, value := range want {
+		if flags[name] != value {
+			t.Errorf("strict flag %s = %v, want %v", name, flags[name], value)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package chromebrowser
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/cdp"
+	cdplog "github.com/chromedp/cdproto/log"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/cdproto/security"
+
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
+// diagnosticsCollector gathers page diagnostics from CDP events. Events
+// arrive on chromedp's event loop, so handlers only record and never block.
+type diagnosticsCollector struct {
+	mainFrame cdp.FrameID // the document status is taken from this frame only
+
+	mu       sync.Mutex
+	diag     ports.PageDiagnostics
+	requests map[network.RequestID]*network.Request
+}
+
+// newDiagnosticsCollector returns a collector for the page whose main frame
+// is mainFrame. The main frame of a page target has the target's ID.
+func newDiagnosticsCollector(mainFrame cdp.FrameID) *diagnosticsCollector {
+	c := &diagnosticsCollector{mainFrame: mainFrame}
+	c.reset()
+	return c
+}
+
+// reset forgets everything collected so far, before a new page loads.
+func (c *diagnosticsCollector) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.diag = ports.PageDiagnostics{
+		ConsoleMessages: []ports.ConsoleMessage{},
+		Exceptions:      []ports.PageException{},
+		FailedRequests:  []ports.FailedRequest{},
+		MixedContent:    []ports.MixedContent{},
+	}
+	c.requests = make(map[network.RequestID]*network.Request)
+}
+
+// snapshot returns a copy of the diagnostics collected so far.
+func (c *diagnosticsCollector) snapshot() ports.PageDiagnostics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	diag := c.diag
+	diag.ConsoleMessages = append([]ports.ConsoleMessage{}, c.diag.ConsoleMessages...)
+	diag.Exceptions = append([]ports.PageException{}, c.diag.Exceptions...)
+	diag.FailedRequests = append([]ports.FailedRequest{}, c.diag.FailedRequests...)
+	diag.MixedContent = append([]ports.MixedContent{}, c.diag.MixedContent...)
+	return diag
+}
+
+// handle records a CDP event; it is a chromedp target listener.
+func (c *diagnosticsCollector) handle(ev any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch ev := ev.(type) {
+	case *runtime.EventConsoleAPICalled:
+		c.diag.ConsoleMessages = append(c.diag.ConsoleMessages, consoleMessage(ev))
+
+	case *runtime.EventExceptionThrown:
+		details := ev.ExceptionDetails
+		message := details.Text
+		if details.Exception != nil && details.Exception.Description != "" {
+			message = details.Exception.Description
+		}
+		c.diag.Exceptions = append(c.diag.Exceptions, ports.PageException{
+			Message: message,
+			URL:     details.URL,
+			Line:    int(details.LineNumber) + 1,
+		})
+
+	case *cdplog.EventEntryAdded:
+		// Network errors are reported in full as failed requests
+		if ev.Entry.Source == cdplog.SourceNetwork {
+			return
+		}
+		message := ports.ConsoleMessage{
+			Source: string(ev.Entry.Source),
+			Level:  string(ev.Entry.Level),
+			Text:   ev.Entry.Text,
+			URL:    ev.Entry.URL,
+		}
+		if ev.Entry.LineNumber > 0 {
+			message.Line = int(ev.Entry.LineNumber) + 1
+		}
+		c.diag.ConsoleMessages = append(c.diag.ConsoleMessages, message)
+
+	case *network.EventRequestWillBeSent:
+		c.requests[ev.RequestID] = ev.Request
+		switch ev.Request.MixedContentType {
+		case security.MixedContentTypeBlockable, security.MixedContentTypeOptionallyBlockable:
+			c.diag.MixedContent = append(c.diag.MixedContent, ports.MixedContent{
+				URL:          ev.Request.URL,
+				ResourceType: string(ev.Type),
+				Type:         string(ev.Request.MixedContentType),
+			})
+		}
+
+	case *network.EventResponseReceived:
+		// Iframes load documents too; the last one of the main frame is the
+		// captured page, as redirects are not reported as responses
+		if ev.Type == network.ResourceTypeDocument && ev.FrameID == c.mainFrame {
+			c.diag.DocumentURL = ev.Response.URL
+			c.diag.DocumentStatus = int(ev.Response.Status)
+		}
+		if ev.Response.Status >= 400 {
+			failed := ports.FailedRequest{
+				URL:          ev.Response.URL,
+				ResourceType: string(ev.Type),
+				Status:       int(ev.Response.Status),
+				Error:        ev.Response.StatusText,
+			}
+			if request := c.requests[ev.RequestID]; request != nil {
+				failed.Method = request.Method
+			}
+			c.diag.FailedRequests = append(c.diag.FailedRequests, failed)
+		}
+
+	case *network.EventLoadingFailed:
+		if ev.Canceled {
+			return
+		}
+		failed := ports.FailedRequest{
+			ResourceType: string(ev.Type),
+			Error:        ev.ErrorText,
+		}
+		if ev.BlockedReason != "" {
+			failed.Error = string(ev.BlockedReason)
+		}
+		if request := c.requests[ev.RequestID]; request != nil {
+			failed.URL = request.URL
+			failed.Method = request.Method
+		}
+		c.diag.FailedRequests = append(c.diag.FailedRequests, failed)
+		if ev.BlockedReason == network.BlockedReasonMixedContent {
+			for i := range c.diag.MixedContent {
+				if c.diag.MixedContent[i].URL == failed.URL {
+					c.diag.MixedContent[i].Blocked = true
+				}
+			}
+		}
+	}
+}
+
+// consoleMessage converts a console API call to a console message.
+func consoleMessage(ev *runtime.EventConsoleAPICalled) ports.ConsoleMessage {
+	args := make([]string, 0, len(ev.Args))
+	for _, arg := range ev.Args {
+		args = append(args, remoteObjectText(arg))
+	}
+
+	level := string(ev.Type)
+	switch ev.Type {
+	case runtime.APITypeWarning:
+		level = "warning"
+	case runtime.APITypeAssert:
+		level = "error"
+	}
+
+	message := ports.ConsoleMessage{
+		Source: "console",
+		Level:  level,
+		Text:   strings.Join(args, " "),
+	}
+	if ev.StackTrace != nil && len(ev.StackTrace.CallFrames) > 0 {
+		frame := ev.StackTrace.CallFrames[0]
+		message.URL = frame.URL
+		message.Line = int(frame.LineNumber) + 1
+	}
+	return message
+}
+
+// remoteObjectText renders a console argument like DevTools does for
+// primitives, falling back to the object description.
+func remoteObjectText(obj *runtime.RemoteObject) string {
+	if len(obj.Value) > 0 {
+		var s string
+		if err := json.Unmarshal(obj.Value, &s); err == nil {
+			return s
+		}
+		return string(obj.Value)
+	}
+	if obj.UnserializableValue != "" {
+		return string(obj.UnserializableValue)
+	}
+	if obj.Description != "" {
+		return obj.Description
+	}
+	return string(obj.Type)
+}
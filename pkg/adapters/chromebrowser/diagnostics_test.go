@@ -0,0 +1,80 @@
+package chromebrowser
+
+import (
+	"testing"
+
+	"github.com/chromedp/cdproto/cdp"
+	cdplog "github.com/chromedp/cdproto/log"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/cdproto/security"
+)
+
+func TestDiagnosticsCollector(t *testing.T) {
+	const mainFrame cdp.FrameID = "main"
+	c := newDiagnosticsCollector(mainFrame)
+
+	// An iframe document is not the page's
+	c.handle(&network.EventResponseReceived{
+		RequestID: "0",
+		FrameID:   "iframe",
+		Type:      network.ResourceTypeDocument,
+		Response:  &network.Response{URL: "https://ads.example.com/frame", Status: 200},
+	})
+	c.handle(&network.EventRequestWillBeSent{
+		RequestID: "1",
+		FrameID:   mainFrame,
+		Type:      network.ResourceTypeDocument,
+		Request:   &network.Request{URL: "https://example.com/", Method: "GET"},
+	})
+	c.handle(&network.EventResponseReceived{
+		RequestID: "1",
+		FrameID:   mainFrame,
+		Type:      network.ResourceTypeDocument,
+		Response:  &network.Response{URL: "https://example.com/", Status: 500, StatusText: "Internal Server Error"},
+	})
+	c.handle(&network.EventRequestWillBeSent{
+		RequestID: "2",
+		Type:      network.ResourceTypeImage,
+		Request:   &network.Request{URL: "http://example.com/a.png", Method: "GET", MixedContentType: security.MixedContentTypeBlockable},
+	})
+	c.handle(&network.EventLoadingFailed{
+		RequestID:     "2",
+		Type:          network.ResourceTypeImage,
+		ErrorText:     "net::ERR_BLOCKED_BY_CLIENT",
+		BlockedReason: network.BlockedReasonMixedContent,
+	})
+	c.handle(&runtime.EventConsoleAPICalled{
+		Type: runtime.APITypeError,
+		Args: []*runtime.RemoteObject{{Type: "string", Value: []byte(`"failed:"`)}, {Type: "number", Value: []byte("42")}},
+	})
+	c.handle(&runtime.EventExceptionThrown{ExceptionDetails: &runtime.ExceptionDetails{
+		Text:       "Uncaught",
+		URL:        "https://example.com/app.js",
+		LineNumber: 9,
+		Exception:  &runtime.RemoteObject{Description: "TypeError: x is undefined"},
+	}})
+	c.handle(&cdplog.EventEntryAdded{Entry: &cdplog.Entry{Source: cdplog.SourceNetwork, Level: cdplog.LevelError, Text: "404"}})
+
+	diag := c.snapshot()
+	if diag.DocumentStatus != 500 || diag.DocumentURL != "https://example.com/" {
+		t.Errorf("document = %d %s, want 500 https://example.com/", diag.DocumentStatus, diag.DocumentURL)
+	}
+	if len(diag.FailedRequests) != 2 || diag.FailedRequests[1].Error != "mixed-content" {
+		t.Errorf("FailedRequests = %+v, want the document and the blocked image", diag.FailedRequests)
+	}
+	if len(diag.MixedContent) != 1 || !diag.MixedContent[0].Blocked {
+		t.Errorf("MixedContent = %+v, want one blocked image", diag.MixedContent)
+	}
+	if len(diag.ConsoleMessages) != 1 || diag.ConsoleMessages[0].Text != "failed: 42" || diag.ConsoleMessages[0].Level != "error" {
+		t.Errorf("ConsoleMessages = %+v, want the console.error call only", diag.ConsoleMessages)
+	}
+	if len(diag.Exceptions) != 1 || diag.Exceptions[0].Message != "TypeError: x is undefined" || diag.Exceptions[0].Line != 10 {
+		t.Errorf("Exceptions = %+v", diag.Exceptions)
+	}
+
+	c.reset()
+	if diag := c.snapshot(); diag.DocumentStatus != 0 || len(diag.FailedRequests) != 0 {
+		t.Errorf("snapshot() after reset = %+v, want empty", diag)
+	}
+}
@@ -1,7 +1,15 @@
 // Package chromebrowser provides deterministic browser scripts.
 package chromebrowser
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
 
 // DisableAnimationsCSS contains CSS to disable all animations and transitions.
 const DisableAnimationsCSS = `
@@ -24,25 +32,60 @@ html {
 }
 `
 
-// GenerateMockTimeScript generates a script to fix Date, Math.random, and Performance.now.
-func GenerateMockTimeScript(fixedTime string) string {
+// DefaultClockStep is the number of milliseconds the virtual clock advances
+// on every read.
+const DefaultClockStep = 1.0
+
+// SettleFrames is the largest number of 60 Hz animation frames
+// SettleFramesExpression runs before the capture.
+const SettleFrames = 60
+
+// SettleFramesExpression runs up to SettleFrames animation frames on the
+// virtual clock of the clock script and evaluates to the number of frames
+// run, 0 without the clock script.
+var SettleFramesExpression = fmt.Sprintf(`window.__staticWebshotSettleFrames ? window.__staticWebshotSettleFrames(%d) : 0`, SettleFrames)
+
+// GenerateClockScript generates a script that replaces Date, performance.now
+// and requestAnimationFrame with a virtual clock starting at startTime.
+//
+// Every read of the clock advances it by step milliseconds, so code that
+// measures elapsed time or waits for the clock to move still terminates. A
+// step of 0 freezes the clock between frames.
+//
+// Animation frames the browser paints do not advance the clock, as their
+// number depends on how fast the page loaded. The clock advances by whole
+// 60 Hz frames only in window.__staticWebshotSettleFrames(count), which runs
+// up to count frames back to back and stops early once no callback is
+// waiting (see SettleFramesExpression).
+func GenerateClockScript(startTime string, step float64) string {
 	return fmt.Sprintf(`
 (() => {
-  // Fix Date
-  const fixedTimestamp = new Date('%s').valueOf();
+  const startTimestamp = new Date('%s').valueOf();
+  const step = %s;
+  const frameStep = 1000 / 60;
+  let elapsed = 0;
+
+  // Return the current virtual time and advance it by one step
+  function tick() {
+    const current = elapsed;
+    elapsed += step;
+    return current;
+  }
+
+  // Date
   const OriginalDate = Date;
 
   window.Date = class extends OriginalDate {
     constructor(...args) {
       if (args.length === 0) {
-        super(fixedTimestamp);
+        super(startTimestamp + tick());
       } else {
         super(...args);
       }
     }
 
     static now() {
-      return fixedTimestamp;
+      return startTimestamp + tick();
     }
   };
 
@@ -50,43 +93,214 @@ func GenerateMockTimeScript(fixedTime string) string {
   Object.setPrototypeOf(window.Date, OriginalDate);
   Object.setPrototypeOf(window.Date.prototype, OriginalDate.prototype);
 
-  // Fix Math.random to always return 0.5 for true determinism
+  // Performance.now counts from the start of the virtual clock
+  if (window.performance && window.performance.now) {
+    performance.now = function() {
+      return tick();
+    };
+  }
+
+  // requestAnimationFrame: callbacks of one frame share a timestamp. Painted
+  // frames run them at the current time; only settle frames advance it.
+  if (window.requestAnimationFrame) {
+    const originalRequestAnimationFrame = window.requestAnimationFrame.bind(window);
+    let callbacks = new Map();
+    let nextId = 1;
+    let scheduled = false;
+
+    function runFrame() {
+      scheduled = false;
+      runCallbacks();
+    }
+
+    // Run the callbacks requested so far; those they request run next frame
+    function runCallbacks() {
+      const timestamp = elapsed;
+      const pending = callbacks;
+      callbacks = new Map();
+      pending.forEach(callback => {
+        try {
+          callback(timestamp);
+        } catch (e) {
+          setTimeout(() => { throw e; }, 0);
+        }
+      });
+    }
+
+    window.requestAnimationFrame = function(callback) {
+      const id = nextId++;
+      callbacks.set(id, callback);
+      if (!scheduled) {
+        scheduled = true;
+        originalRequestAnimationFrame(runFrame);
+      }
+      return id;
+    };
+
+    window.cancelAnimationFrame = function(id) {
+      callbacks.delete(id);
+    };
+
+    window.__staticWebshotSettleFrames = function(count) {
+      let frames = 0;
+      while (frames < count && callbacks.size > 0) {
+        elapsed += frameStep;
+        runCallbacks();
+        frames++;
+      }
+      return frames;
+    };
+  }
+})();
+`, startTime, strconv.FormatFloat(step, 'g', -1, 64))
+}
+
+// GenerateRandomScript generates a script that replaces Math.random,
+// crypto.getRandomValues and crypto.randomUUID with seeded pseudo-random
+// generators (mulberry32), so every page load draws the same sequence.
+// Math.random and crypto use separate streams so that one does not shift
+// the other.
+func GenerateRandomScript(seed int64) string {
+	return fmt.Sprintf(`
+(() => {
+  function mulberry32(seed) {
+    let state = seed >>> 0;
+    return function() {
+      state = (state + 0x6D2B79F5) >>> 0;
+      let t = state;
+      t = Math.imul(t ^ (t >>> 15), t | 1);
+      t ^= t + Math.imul(t ^ (t >>> 7), t | 61);
+      return (t ^ (t >>> 14)) >>> 0;
+    };
+  }
+
+  const seed = %d;
+
+  // Math.random draws from [0, 1) like the original
+  const nextRandom = mulberry32(seed);
   Math.random = function() {
-    return 0.5;
+    return nextRandom() / 4294967296;
   };
 
-  // Fix Performance.now
-  if (window.performance && window.performance.now) {
-    let offset = 0;
-    performance.now = function() {
-      return offset;
+  if (!window.crypto) return;
+
+  const nextCrypto = mulberry32(seed ^ 0x9E3779B9);
+
+  crypto.getRandomValues = function(array) {
+    const bytes = new Uint8Array(array.buffer, array.byteOffset, array.byteLength);
+    for (let i = 0; i < bytes.length; i++) {
+      bytes[i] = nextCrypto() & 0xff;
+    }
+    return array;
+  };
+
+  if (crypto.randomUUID) {
+    crypto.randomUUID = function() {
+      const bytes = crypto.getRandomValues(new Uint8Array(16));
+      // Version 4, RFC 4122 variant
+      bytes[6] = (bytes[6] & 0x0f) | 0x40;
+      bytes[8] = (bytes[8] & 0x3f) | 0x80;
+      const hex = Array.from(bytes, b => b.toString(16).padStart(2, '0')).join('');
+      return hex.slice(0, 8) + '-' + hex.slice(8, 12) + '-' + hex.slice(12, 16) + '-' +
+        hex.slice(16, 20) + '-' + hex.slice(20);
     };
   }
 })();
-`, fixedTime)
+`, uint32(seed))
+}
+
+// GenerateMockTimeScript generates a script that fixes Date and
+// performance.now at fixedTime and makes Math.random deterministic.
+//
+// Deprecated: Use GenerateClockScript and GenerateRandomScript, which this
+// combines with a frozen clock and seed 0.
+func GenerateMockTimeScript(fixedTime string) string {
+	return GenerateClockScript(fixedTime, 0) + "\n;\n" + GenerateRandomScript(0)
 }
 
-// DisableAutoplayScript disables autoplay features and freezes video/audio elements.
-const DisableAutoplayScript = `
+// Video frames shown by the autoplay script.
+const (
+	// VideoFramePoster shows a video's poster image instead of a frame.
+	VideoFramePoster = "poster"
+)
+
+// DisableAutoplayScript disables autoplay features and freezes video/audio
+// elements at their first frame.
+var DisableAutoplayScript = GenerateDisableAutoplayScript("")
+
+// GenerateDisableAutoplayScript generates a script that disables autoplay and
+// freezes video/audio elements. videoFrame picks what a video shows: "" for
+// the first frame, VideoFramePoster for its poster (falling back to the first
+// frame without one), or a time in seconds. Seeks to a time register with
+// window.__staticWebshotPending so the capture waits for the frame.
+func GenerateDisableAutoplayScript(videoFrame string) string {
+	return fmt.Sprintf(`
 (() => {
   // Global flag for applications to check
   window.__E2E_DISABLE_AUTOPLAY__ = true;
 
+  // Video frame to show: '' (first frame), 'poster' or a time in seconds
+  const videoFrame = %s;
+  const pending = window.__staticWebshotPending = window.__staticWebshotPending || [];
+  const originalLoad = HTMLMediaElement.prototype.load;
+  const posterShown = new WeakSet();
+
+  // Seek a media element, letting the capture wait for the frame when the
+  // element has to load first
+  function seek(media, time) {
+    if (time === 0) {
+      media.currentTime = 0;
+      return;
+    }
+    pending.push(new Promise(resolve => {
+      const apply = () => {
+        media.addEventListener('seeked', resolve, { once: true });
+        media.currentTime = Math.min(time, media.duration || time);
+      };
+      if (media.readyState >= 1) {
+        apply();
+      } else {
+        media.preload = 'auto';
+        media.addEventListener('loadedmetadata', apply, { once: true });
+      }
+      setTimeout(resolve, 5000);
+    }));
+  }
+
+  // Show the chosen frame of a paused media element
+  function showFrame(media) {
+    if (media.nodeName !== 'VIDEO' || videoFrame === '') {
+      seek(media, 0);
+      return;
+    }
+    if (videoFrame === 'poster') {
+      if (!media.poster) {
+        seek(media, 0);
+      } else if (!posterShown.has(media)) {
+        // Reloading brings the poster back until playback or a seek
+        posterShown.add(media);
+        originalLoad.call(media);
+      }
+      return;
+    }
+    seek(media, Number(videoFrame));
+  }
+
   // Helper function to freeze a media element
   function freezeMedia(media) {
     try {
       media.pause();
-      media.currentTime = 0;
       media.autoplay = false;
       media.loop = false;
       media.removeAttribute('autoplay');
+      showFrame(media);
     } catch (e) {}
   }
 
-  // Disable video/audio autoplay and freeze at time 0
+  // Disable video/audio autoplay and freeze at the chosen frame
   HTMLMediaElement.prototype.play = function() {
     this.pause();
-    this.currentTime = 0;
+    showFrame(this);
     return Promise.resolve();
   };
 
@@ -105,11 +319,10 @@ const DisableAutoplayScript = `
   });
 
   // Override load method to pause immediately
-  const originalLoad = HTMLMediaElement.prototype.load;
   HTMLMediaElement.prototype.load = function() {
     originalLoad.call(this);
     this.pause();
-    this.currentTime = 0;
+    showFrame(this);
   };
 
   // Watch for dynamically added media elements using MutationObserver
@@ -176,15 +389,19 @@ const DisableAutoplayScript = `
 
   // Clear all intervals after page load and freeze all media elements
   window.addEventListener('load', () => {
-    setTimeout(() => {
-      intervals.forEach(id => originalClearInterval(id));
-      intervals.clear();
+    pending.push(new Promise(resolve => {
+      setTimeout(() => {
+        intervals.forEach(id => originalClearInterval(id));
+        intervals.clear();
 
-      document.querySelectorAll('video, audio').forEach(freezeMedia);
-    }, 100);
+        document.querySelectorAll('video, audio').forEach(freezeMedia);
+        resolve();
+      }, 100);
+    }));
   });
 })();
-`
+`, strconv.Quote(videoFrame))
+}
 
 // FixIntersectionObserverScript makes all elements immediately visible.
 const FixIntersectionObserverScript = `
@@ -215,9 +432,226 @@ const FixIntersectionObserverScript = `
 })();
 `
 
-// DisableScrollScript disables scroll-related behaviors.
+// FreezeAnimatedImagesScript replaces animated GIF, WebP, APNG and AVIF images
+// with their first frame.
+//
+// Only same-origin (or data:) images are read back, and only candidates: a
+// .gif, .webp, .png, .apng or .avif file name, or one of those content types
+// in the resource timing entry. Other images are never fetched again. Frames
+// are decoded with the ImageDecoder API; where it is missing, GIFs are
+// replaced with whatever frame is showing. Cross-origin images keep
+// animating, as do animated CSS background images. Replacements register
+// with window.__staticWebshotPending so the capture waits for them.
+const FreezeAnimatedImagesScript = `
+(() => {
+  const pending = window.__staticWebshotPending = window.__staticWebshotPending || [];
+  const animatedTypes = ['image/gif', 'image/webp', 'image/png', 'image/apng', 'image/avif'];
+  const animatedExtension = /\.(gif|webp|png|apng|avif)([?#]|$)/i;
+  const seen = new WeakSet();
+
+  // The content type of an image, from a data: URL or its resource timing
+  // entry ("" when unknown)
+  function knownType(src) {
+    if (src.startsWith('data:')) {
+      return src.slice(5).split(/[;,]/)[0].toLowerCase();
+    }
+    const entry = performance.getEntriesByName(src, 'resource')[0];
+    return (entry && entry.contentType || '').split(';')[0].trim().toLowerCase();
+  }
+
+  // Whether an image may be animated and can be read back without a
+  // cross-origin request
+  function isCandidate(src) {
+    if (src.startsWith('data:')) {
+      return animatedTypes.includes(knownType(src));
+    }
+    let url;
+    try {
+      url = new URL(src, location.href);
+    } catch (e) {
+      return false;
+    }
+    if (url.origin !== location.origin) return false;
+    return animatedExtension.test(url.pathname) || animatedTypes.includes(knownType(src));
+  }
+
+  function toDataURL(source, width, height) {
+    const canvas = document.createElement('canvas');
+    canvas.width = width;
+    canvas.height = height;
+    canvas.getContext('2d').drawImage(source, 0, 0);
+    return { url: canvas.toDataURL('image/png'), width };
+  }
+
+  // Decode the first frame of an animated image, or return null for a still one
+  async function decodeFirstFrame(src) {
+    const response = await fetch(src, { cache: 'force-cache' });
+    const type = (response.headers.get('content-type') || '').split(';')[0].trim().toLowerCase();
+    if (!animatedTypes.includes(type) || !(await ImageDecoder.isTypeSupported(type))) {
+      return null;
+    }
+
+    const decoder = new ImageDecoder({ data: response.body, type });
+    try {
+      await decoder.tracks.ready;
+      const track = decoder.tracks.selectedTrack;
+      if (!track || !track.animated) {
+        return null;
+      }
+      const { image } = await decoder.decode({ frameIndex: 0 });
+      try {
+        return toDataURL(image, image.displayWidth, image.displayHeight);
+      } finally {
+        image.close();
+      }
+    } finally {
+      decoder.close();
+    }
+  }
+
+  async function freezeImage(img) {
+    if (seen.has(img)) return;
+    seen.add(img);
+
+    if (!img.complete) {
+      await new Promise(resolve => {
+        img.addEventListener('load', resolve, { once: true });
+        img.addEventListener('error', resolve, { once: true });
+      });
+    }
+    const src = img.currentSrc || img.src;
+    if (!src || !img.naturalWidth || !isCandidate(src)) return;
+
+    let frame = null;
+    try {
+      if (typeof ImageDecoder !== 'undefined') {
+        frame = await decodeFirstFrame(src);
+      } else if (/\.gif([?#]|$)/i.test(src) || knownType(src) === 'image/gif') {
+        frame = toDataURL(img, img.naturalWidth, img.naturalHeight);
+      }
+    } catch (e) {}
+    if (!frame) return;
+
+    // A density descriptor keeps the rendered size of srcset images, and
+    // <picture> sources would take precedence over the frame
+    if (img.parentElement && img.parentElement.nodeName === 'PICTURE') {
+      img.parentElement.querySelectorAll('source').forEach(source => source.remove());
+    }
+    img.srcset = frame.url + ' ' + (frame.width / img.naturalWidth) + 'x';
+    img.src = frame.url;
+    try {
+      await img.decode();
+    } catch (e) {}
+  }
+
+  function freezeImages(root) {
+    const images = root.nodeName === 'IMG' ? [root] : Array.from(root.querySelectorAll('img'));
+    images.forEach(img => {
+      pending.push(Promise.race([
+        freezeImage(img),
+        new Promise(resolve => setTimeout(resolve, 5000))
+      ]));
+    });
+  }
+
+  window.addEventListener('load', () => {
+    freezeImages(document);
+
+    // Freeze images added later as well
+    new MutationObserver(mutations => {
+      mutations.forEach(mutation => {
+        mutation.addedNodes.forEach(node => {
+          if (node.nodeType === 1) freezeImages(node);
+        });
+      });
+    }).observe(document.documentElement, { childList: true, subtree: true });
+  });
+})();
+`
+
+// FreezeLottieScript stops Lottie animations at their first frame: lottie-web
+// (bodymovin) animations, and the <lottie-player> and <dotlottie-player>
+// web components.
+const FreezeLottieScript = `
+(() => {
+  const pending = window.__staticWebshotPending = window.__staticWebshotPending || [];
+
+  function players() {
+    return document.querySelectorAll('lottie-player, dotlottie-player, dotlottie-wc');
+  }
+
+  function freezeLottie() {
+    ['lottie', 'bodymovin'].forEach(name => {
+      const lib = window[name];
+      if (!lib || typeof lib.getRegisteredAnimations !== 'function') return;
+      try {
+        lib.getRegisteredAnimations().forEach(animation => {
+          try {
+            animation.goToAndStop(0, true);
+          } catch (e) {}
+        });
+      } catch (e) {}
+    });
+
+    players().forEach(player => {
+      try {
+        if (typeof player.stop === 'function') {
+          player.stop();
+        } else if (typeof player.pause === 'function') {
+          player.pause();
+        }
+      } catch (e) {}
+    });
+  }
+
+  // Players often start after load, so freeze again for a while and let the
+  // capture wait for the last pass when the page uses Lottie
+  window.addEventListener('load', () => {
+    freezeLottie();
+    if (!window.lottie && !window.bodymovin && players().length === 0) return;
+    pending.push(new Promise(resolve => {
+      setTimeout(freezeLottie, 100);
+      setTimeout(freezeLottie, 300);
+      setTimeout(() => {
+        freezeLottie();
+        resolve();
+      }, 1000);
+    }));
+  });
+
+  // Expose for manual use
+  window.__freezeLottie = freezeLottie;
+})();
+`
+
+// StabilizeCanvasScript requests canvas contexts that render the same on
+// every machine: 2D canvases are kept on the CPU, and WebGL contexts are
+// created without multisampling and with a preserved drawing buffer so the
+// screenshot sees the last frame.
+const StabilizeCanvasScript = `
+(() => {
+  const originalGetContext = HTMLCanvasElement.prototype.getContext;
+
+  HTMLCanvasElement.prototype.getContext = function(type, attributes) {
+    const options = Object.assign({}, attributes);
+    if (type === '2d') {
+      options.willReadFrequently = true;
+    } else if (type === 'webgl' || type === 'webgl2' || type === 'experimental-webgl') {
+      options.antialias = false;
+      options.preserveDrawingBuffer = true;
+      options.powerPreference = 'low-power';
+    }
+    return originalGetContext.call(this, type, options);
+  };
+})();
+`
+
+// DisableScrollScript disables scroll-related behaviors. The original
+// window.scrollTo stays available to PreloadScrollScript.
 const DisableScrollScript = `
 (() => {
+  window.__staticWebshotScrollTo = window.scrollTo.bind(window);
+
   const noop = () => {};
   window.scrollTo = noop;
   window.scroll = noop;
@@ -227,86 +661,600 @@ const DisableScrollScript = `
 })();
 `
 
+// CarouselHook is a user-supplied freeze function for carousels the built-in
+// handling does not know. Script is the body of a function called with the
+// matching element as "element".
+type CarouselHook struct {
+	Selector string
+	Script   string
+}
+
+// CarouselReportExpression evaluates to the freeze attempts of the carousel
+// script: objects with library, selector and result fields.
+const CarouselReportExpression = `window.__staticWebshotCarousels || []`
+
+// PreloadScrollScript is an expression that scrolls the real viewport to the
+// bottom of the page one screen at a time, waiting at each step for images
+// and fonts that scrolling triggered, and then returns to the top. It uses
+// the scrollTo saved by DisableScrollScript and evaluates to
+// {steps, height}.
+const PreloadScrollScript = `
+(async () => {
+  const scrollTo = window.__staticWebshotScrollTo || window.scrollTo.bind(window);
+  const sleep = ms => new Promise(resolve => setTimeout(resolve, ms));
+  const frame = () => new Promise(resolve => requestAnimationFrame(() => resolve()));
+
+  // Wait for images started by the last step, but not forever
+  async function settle() {
+    const images = Array.from(document.images).filter(img => !img.complete);
+    await Promise.race([
+      Promise.all(images.map(img => new Promise(resolve => {
+        img.addEventListener('load', resolve, { once: true });
+        img.addEventListener('error', resolve, { once: true });
+      }))),
+      sleep(3000)
+    ]);
+    await document.fonts.ready;
+  }
+
+  window.__staticWebshotPreloading = true;
+  try {
+    const maxSteps = 100;
+    let steps = 0;
+    let y = 0;
+    while (steps < maxSteps) {
+      const bottom = document.documentElement.scrollHeight - window.innerHeight;
+      if (y >= bottom) break;
+      y = Math.min(y + window.innerHeight, bottom);
+      scrollTo(0, y);
+      steps++;
+      await sleep(100);
+      await settle();
+    }
+
+    scrollTo(0, 0);
+    await frame();
+    await frame();
+    return { steps, height: document.documentElement.scrollHeight };
+  } finally {
+    window.__staticWebshotPreloading = false;
+  }
+})()
+`
+
+// describeFunction defines describe(el), which returns a short CSS path of
+// an element for reports.
+const describeFunction = `
+  function describe(el) {
+    if (!el || el.nodeType !== 1) return String(el);
+    const parts = [];
+    for (let node = el; node && node.nodeType === 1 && parts.length < 4; node = node.parentElement) {
+      if (node.id) {
+        parts.unshift('#' + node.id);
+        break;
+      }
+      let part = node.nodeName.toLowerCase();
+      const classes = Array.from(node.classList).slice(0, 2);
+      if (classes.length > 0) part += '.' + classes.join('.');
+      parts.unshift(part);
+    }
+    return parts.join(' > ');
+  }`
+
+// Fixed element modes for GenerateFixedElementsScript.
+const (
+	FixedElementsKeep    = "keep"     // Leave fixed and sticky elements alone
+	FixedElementsStatic  = "static"   // Pin them where they are in the first viewport
+	FixedElementsHideAll = "hide-all" // Hide them everywhere, the first viewport included
+)
+
+// GenerateFixedElementsScript returns an expression that adjusts the visible
+// position: fixed and position: sticky elements of the page for a full-page
+// capture. In FixedElementsStatic mode fixed elements become absolute at
+// their place in the first viewport and sticky elements become static, so
+// that they appear once; in FixedElementsHideAll mode they are hidden
+// everywhere, so the first viewport loses its header as well. The
+// expression evaluates to the adjusted elements as {selector, position,
+// action}. FixedElementsKeep and "" return an empty script.
+func GenerateFixedElementsScript(mode string) (string, error) {
+	switch mode {
+	case "", FixedElementsKeep:
+		return "", nil
+	case FixedElementsStatic, FixedElementsHideAll:
+	default:
+		return "", fmt.Errorf("invalid fixed elements mode %q (want %s, %s or %s)",
+			mode, FixedElementsKeep, FixedElementsStatic, FixedElementsHideAll)
+	}
+
+	return `
+(() => {
+  const mode = ` + strconv.Quote(mode) + `;
+` + describeFunction + `
+
+  // Collect first: adjusting one element must not change what the others
+  // look like, and nested fixed elements move with their ancestor
+  const found = [];
+  for (const el of document.querySelectorAll('body *')) {
+    const position = getComputedStyle(el).position;
+    if (position !== 'fixed' && position !== 'sticky') continue;
+    if (found.some(f => f.el.contains(el))) continue;
+    const rect = el.getBoundingClientRect();
+    if (rect.width === 0 || rect.height === 0) continue;
+    found.push({ el, position, rect });
+  }
+
+  const adjusted = [];
+  for (const { el, position, rect } of found) {
+    let action;
+    if (mode === 'hide-all') {
+      el.style.setProperty('visibility', 'hidden', 'important');
+      action = 'hidden';
+    } else if (position === 'sticky') {
+      el.style.setProperty('position', 'static', 'important');
+      action = 'static';
+    } else {
+      // Place the element where it is now, relative to its new containing
+      // block, and keep its size
+      el.style.setProperty('position', 'absolute', 'important');
+      el.style.setProperty('top', (rect.top + window.scrollY) + 'px', 'important');
+      el.style.setProperty('left', (rect.left + window.scrollX) + 'px', 'important');
+      el.style.setProperty('right', 'auto', 'important');
+      el.style.setProperty('bottom', 'auto', 'important');
+      el.style.setProperty('width', rect.width + 'px', 'important');
+      el.style.setProperty('height', rect.height + 'px', 'important');
+      const moved = el.getBoundingClientRect();
+      el.style.setProperty('top', (rect.top + window.scrollY + rect.top - moved.top) + 'px', 'important');
+      el.style.setProperty('left', (rect.left + window.scrollX + rect.left - moved.left) + 'px', 'important');
+      action = 'absolute';
+    }
+    adjusted.push({ selector: describe(el), position, action });
+  }
+  return adjusted;
+})()
+`, nil
+}
+
+// DefaultMaskBoxColor is the color of box masks without a color.
+const DefaultMaskBoxColor = "#808080"
+
+// maskBlurRadius is the blur radius of blur masks in CSS pixels.
+const maskBlurRadius = 16
+
+// GenerateMaskOverlayScript returns an expression that covers the elements
+// matching blackout and box masks with overlays and evaluates to the areas
+// of the elements matching any of the masks, as ports.MaskedRect values in
+// document coordinates. The area of a blurred element is grown by twice the
+// blur radius on every side, as far as the blur bleeds.
+func GenerateMaskOverlayScript(masks []ports.Mask) string {
+	entries := make([]string, 0, len(masks))
+	for _, mask := range masks {
+		style := mask.Style
+		if style == "" {
+			style = ports.MaskHide
+		}
+		var color string
+		spread := 0
+		switch style {
+		case ports.MaskBlur:
+			spread = 2 * maskBlurRadius
+		case ports.MaskBlackout:
+			color = "#000"
+		case ports.MaskBox:
+			color = mask.Color
+			if color == "" {
+				color = DefaultMaskBoxColor
+			}
+		}
+		entries = append(entries, fmt.Sprintf("    { selector: %s, style: %s, color: %s, spread: %d }",
+			strconv.Quote(mask.Selector), strconv.Quote(style), strconv.Quote(color), spread))
+	}
+
+	return `
+(() => {
+  const masks = [
+` + strings.Join(entries, ",\n") + `
+  ];
+  const rects = [];
+  for (const mask of masks) {
+    let elements;
+    try {
+      elements = document.querySelectorAll(mask.selector);
+    } catch (e) {
+      continue;
+    }
+    for (const el of elements) {
+      const rect = el.getBoundingClientRect();
+      if (rect.width === 0 || rect.height === 0) continue;
+      const x = rect.left + window.scrollX;
+      const y = rect.top + window.scrollY;
+      const left = Math.max(0, x - mask.spread);
+      const top = Math.max(0, y - mask.spread);
+      rects.push({
+        selector: mask.selector,
+        style: mask.style,
+        x: left,
+        y: top,
+        width: x + rect.width + mask.spread - left,
+        height: y + rect.height + mask.spread - top
+      });
+      if (!mask.color) continue;
+
+      // Overlays are placed in document coordinates, corrected for a
+      // positioned <html> element
+      const overlay = document.createElement('div');
+      overlay.setAttribute('data-static-webshot-mask', mask.style);
+      overlay.style.cssText = 'position:absolute;margin:0;padding:0;border:0;pointer-events:none;z-index:2147483647;' +
+        'left:' + x + 'px;top:' + y + 'px;width:' + rect.width + 'px;height:' + rect.height + 'px;';
+      overlay.style.setProperty('background', mask.color, 'important');
+      document.documentElement.appendChild(overlay);
+      const placed = overlay.getBoundingClientRect();
+      overlay.style.left = (x + rect.left - placed.left) + 'px';
+      overlay.style.top = (y + rect.top - placed.top) + 'px';
+    }
+  }
+  return rects;
+})()
+`
+}
+
+// LayoutSnapshotMaxElements caps the number of elements recorded by
+// LayoutSnapshotScript, so that huge pages do not produce huge snapshots.
+const LayoutSnapshotMaxElements = 5000
+
+// LayoutSnapshotScript is an expression that evaluates to
+// { elements, total }: the visible elements of the page in document order,
+// as layout.Element values, and the number of visible elements, which
+// exceeds the length of elements past LayoutSnapshotMaxElements. Elements
+// carry their path, the path of their closest recorded ancestor, label, tag, box in document coordinates, key computed
+// styles and own text. Paths locate an element by tag and position among
+// siblings of the same tag ("div:nth-of-type(2) > p"), starting from the
+// closest ancestor with an id unique in the page ("#main > p"), so they stay
+// stable across captures of the same page.
+var LayoutSnapshotScript = `
+(() => {
+  const maxElements = ` + strconv.Itoa(LayoutSnapshotMaxElements) + `;
+  const maxText = 200;
+  const paths = new Map();
+  const idCounts = new Map();
+  for (const el of document.querySelectorAll('[id]')) {
+    idCounts.set(el.id, (idCounts.get(el.id) || 0) + 1);
+  }
+
+  function segment(el) {
+    const tag = el.nodeName.toLowerCase();
+    const parent = el.parentElement;
+    if (!parent) return tag;
+    const sameTag = Array.from(parent.children).filter(c => c.nodeName === el.nodeName);
+    if (sameTag.length === 1) return tag;
+    return tag + ':nth-of-type(' + (sameTag.indexOf(el) + 1) + ')';
+  }
+
+  function pathOf(el) {
+    if (el.id && idCounts.get(el.id) === 1) return '#' + el.id;
+    if (el === document.body) return 'body';
+    if (paths.has(el)) return paths.get(el);
+    const parent = el.parentElement;
+    const path = (parent ? pathOf(parent) + ' > ' : '') + segment(el);
+    paths.set(el, path);
+    return path;
+  }
+
+  function labelOf(el) {
+    if (el.id) return '#' + el.id;
+    let label = el.nodeName.toLowerCase();
+    const classes = Array.from(el.classList).slice(0, 2);
+    if (classes.length > 0) label += '.' + classes.join('.');
+    return label;
+  }
+
+  function ownText(el) {
+    let text = '';
+    for (const node of el.childNodes) {
+      if (node.nodeType === 3) text += node.textContent;
+    }
+    text = text.replace(/\s+/g, ' ').trim();
+    return text.length > maxText ? text.slice(0, maxText) : text;
+  }
+
+  const elements = [];
+  const recorded = new Map();
+  let total = 0;
+  for (const el of document.querySelectorAll('body *')) {
+    const tag = el.nodeName.toLowerCase();
+    if (tag === 'script' || tag === 'style' || tag === 'noscript' || tag === 'template') continue;
+    if (el.hasAttribute('data-static-webshot-mask')) continue;
+    const style = getComputedStyle(el);
+    if (style.display === 'none' || style.visibility === 'hidden') continue;
+    const rect = el.getBoundingClientRect();
+    if (rect.width === 0 || rect.height === 0) continue;
+    total++;
+    if (elements.length >= maxElements) continue;
+    let ancestor = el.parentElement;
+    while (ancestor && !recorded.has(ancestor)) ancestor = ancestor.parentElement;
+    const path = pathOf(el);
+    recorded.set(el, path);
+    elements.push({
+      path,
+      parent: ancestor ? recorded.get(ancestor) : '',
+      label: labelOf(el),
+      tag,
+      x: rect.left + window.scrollX,
+      y: rect.top + window.scrollY,
+      width: rect.width,
+      height: rect.height,
+      display: style.display,
+      fontFamily: style.fontFamily,
+      fontSize: style.fontSize,
+      fontWeight: style.fontWeight,
+      color: style.color,
+      backgroundColor: style.backgroundColor,
+      text: ownText(el),
+    });
+  }
+  return { elements, total };
+})()
+`
+
 // FreezeCarouselsScript stops and resets common carousel/slider libraries.
+var FreezeCarouselsScript = GenerateFreezeCarouselsScript(nil)
+
+// GenerateFreezeCarouselsScript generates a script that stops and resets
+// carousel/slider libraries and runs the given hooks.
 //
-// NOTE: This is NOT a universal solution. It provides specific handling for
-// popular slider libraries. Custom or less common slider implementations
-// may not be affected by this script.
+// NOTE: Library support is NOT a universal solution. Instances are found
+// through the elements the libraries decorate, or through their global
+// constructors, so libraries bundled as ES modules are only covered by the
+// generic fallback.
 //
 // Supported libraries:
 //   - Swiper (swiper.js)
 //   - Slick (slick.js, requires jQuery)
 //   - Owl Carousel (owl.carousel.js, requires jQuery)
 //   - Flickity (flickity.js)
-//   - Bootstrap Carousel (bootstrap 5)
+//   - Bootstrap Carousel (bootstrap 4 and 5)
+//   - Splide, Glide.js, Embla Carousel, Keen Slider and Tiny Slider
+//     (through their global constructors)
+//
+// The generic fallback watches the page after load and pins any element whose
+// inline transform or horizontal scroll position changes at least twice
+// within autoAdvanceWindow without user input, back to where it was before
+// the first change. A single change is taken for a one-off move.
 //
-// For unsupported sliders, consider using --mask option to hide the element,
-// or --inject-css to manually disable animations.
-const FreezeCarouselsScript = `
+// Hook scripts are compiled separately with new Function, so a hook that
+// does not parse (or a page whose CSP forbids it) is reported as failed
+// without breaking the rest of the script.
+//
+// Every freeze attempt is recorded in window.__staticWebshotCarousels (see
+// CarouselReportExpression). For unsupported sliders, consider using --mask
+// option to hide the element, or a hook.
+func GenerateFreezeCarouselsScript(hooks []CarouselHook) string {
+	var hookEntries []string
+	for _, hook := range hooks {
+		selector, _ := json.Marshal(hook.Selector)
+		source, _ := json.Marshal(hook.Script)
+		hookEntries = append(hookEntries, fmt.Sprintf("    { selector: %s, source: %s }", selector, source))
+	}
+
+	return `
 (() => {
+  const pending = window.__staticWebshotPending = window.__staticWebshotPending || [];
+  const reports = window.__staticWebshotCarousels = window.__staticWebshotCarousels || [];
+
+  // User hooks: { selector, freeze(element) } or { selector, error }
+  const hooks = [
+` + strings.Join(hookEntries, ",\n") + `
+  ].map(({ selector, source }) => {
+    try {
+      return { selector, freeze: new Function('element', source) };
+    } catch (error) {
+      return { selector, error };
+    }
+  });
+
+` + describeFunction + `
+
+  // Record the latest result per library and element
+  function report(library, el, fn) {
+    const selector = typeof el === 'string' ? el : describe(el);
+    let result = 'frozen';
+    try {
+      fn();
+    } catch (e) {
+      result = 'failed: ' + (e && e.message ? e.message : e);
+    }
+    const existing = reports.find(r => r.library === library && r.selector === selector);
+    if (existing) {
+      existing.result = result;
+    } else {
+      reports.push({ library, selector, result });
+    }
+  }
+
+  function resolveElement(target) {
+    if (typeof target === 'string') {
+      try {
+        return document.querySelector(target);
+      } catch (e) {
+        return null;
+      }
+    }
+    return target;
+  }
+
+  // Capture instances of libraries that keep no reference on the element by
+  // wrapping their global constructors as soon as they are defined
+  const instances = [];
+  const wrapped = new WeakSet();
+
+  function captureInstances(name, library, elementOf) {
+    function wrap(original) {
+      if (typeof original !== 'function' || wrapped.has(original)) return original;
+      const proxy = new Proxy(original, {
+        construct(target, args, newTarget) {
+          const instance = Reflect.construct(target, args, newTarget === proxy ? target : newTarget);
+          instances.push({ library, instance, element: resolveElement(elementOf(args)) });
+          return instance;
+        },
+        apply(target, thisArg, args) {
+          const instance = Reflect.apply(target, thisArg, args);
+          instances.push({ library, instance, element: resolveElement(elementOf(args)) });
+          return instance;
+        }
+      });
+      wrapped.add(proxy);
+      return proxy;
+    }
+
+    let value = wrap(window[name]);
+    try {
+      Object.defineProperty(window, name, {
+        configurable: true,
+        get() { return value; },
+        set(v) { value = wrap(v); }
+      });
+    } catch (e) {}
+  }
+
+  captureInstances('Splide', 'splide', args => args[0]);
+  captureInstances('Glide', 'glide', args => args[0]);
+  captureInstances('EmblaCarousel', 'embla', args => args[0]);
+  captureInstances('KeenSlider', 'keen-slider', args => args[0]);
+  captureInstances('tns', 'tiny-slider', args => args[0] && args[0].container);
+
+  const freezers = {
+    'splide': splide => {
+      const autoplay = splide.Components && splide.Components.Autoplay;
+      if (autoplay && typeof autoplay.pause === 'function') autoplay.pause();
+      const move = splide.Components && splide.Components.Move;
+      if (move && typeof move.jump === 'function') {
+        move.jump(0);
+      } else {
+        splide.go(0);
+      }
+    },
+    'glide': glide => {
+      glide.pause();
+      glide.go('=0');
+    },
+    'embla': embla => {
+      const plugins = typeof embla.plugins === 'function' ? embla.plugins() : {};
+      if (plugins.autoplay && typeof plugins.autoplay.stop === 'function') plugins.autoplay.stop();
+      if (plugins.autoScroll && typeof plugins.autoScroll.stop === 'function') plugins.autoScroll.stop();
+      embla.scrollTo(0, true);
+    },
+    'keen-slider': slider => {
+      slider.moveToIdx(0, true, { duration: 0 });
+    },
+    'tiny-slider': slider => {
+      if (typeof slider.pause === 'function') slider.pause();
+      slider.goTo('first');
+    }
+  };
+
+  // Elements handled by a library, which the generic fallback leaves alone
+  const managed = new WeakSet();
+
   // Freeze known slider library instances
   // All checks are defensive to avoid errors when libraries aren't present
   function freezeSliders() {
     // Swiper - check for .swiper property on elements
     document.querySelectorAll('.swiper-container, .swiper').forEach(el => {
-      try {
-        if (el.swiper && typeof el.swiper.slideTo === 'function') {
-          if (el.swiper.autoplay && typeof el.swiper.autoplay.stop === 'function') {
-            el.swiper.autoplay.stop();
-          }
-          el.swiper.slideTo(0, 0);
+      if (!el.swiper || typeof el.swiper.slideTo !== 'function') return;
+      report('swiper', el, () => {
+        if (el.swiper.autoplay && typeof el.swiper.autoplay.stop === 'function') {
+          el.swiper.autoplay.stop();
         }
-      } catch (e) {}
+        el.swiper.slideTo(0, 0);
+      });
     });
 
     // Slick - check for jQuery and slick plugin
     if (typeof jQuery !== 'undefined' && typeof jQuery.fn.slick === 'function') {
-      try {
-        jQuery('.slick-initialized').each(function() {
+      jQuery('.slick-initialized').each(function() {
+        report('slick', this, () => {
           jQuery(this).slick('slickPause');
           jQuery(this).slick('slickGoTo', 0, true);
         });
-      } catch (e) {}
+      });
     }
 
     // Owl Carousel - check for jQuery and owlCarousel plugin
     if (typeof jQuery !== 'undefined' && typeof jQuery.fn.owlCarousel === 'function') {
-      try {
-        jQuery('.owl-carousel').trigger('stop.owl.autoplay');
-        jQuery('.owl-carousel').trigger('to.owl.carousel', [0, 0]);
-      } catch (e) {}
+      jQuery('.owl-carousel').each(function() {
+        report('owl-carousel', this, () => {
+          jQuery(this).trigger('stop.owl.autoplay');
+          jQuery(this).trigger('to.owl.carousel', [0, 0]);
+        });
+      });
     }
 
     // Flickity - check for Flickity global and data method
     if (typeof Flickity !== 'undefined' && typeof Flickity.data === 'function') {
       document.querySelectorAll('.flickity-enabled').forEach(el => {
-        try {
-          const flkty = Flickity.data(el);
-          if (flkty) {
-            if (typeof flkty.pausePlayer === 'function') flkty.pausePlayer();
-            if (typeof flkty.select === 'function') flkty.select(0, false, true);
-          }
-        } catch (e) {}
+        const flkty = Flickity.data(el);
+        if (!flkty) return;
+        report('flickity', el, () => {
+          if (typeof flkty.pausePlayer === 'function') flkty.pausePlayer();
+          if (typeof flkty.select === 'function') flkty.select(0, false, true);
+        });
       });
     }
 
     // Bootstrap 5 Carousel - check for bootstrap global
-    if (typeof bootstrap !== 'undefined' && bootstrap.Carousel && typeof bootstrap.Carousel.getInstance === 'function') {
+    const bootstrap5 = typeof bootstrap !== 'undefined' && bootstrap.Carousel && typeof bootstrap.Carousel.getInstance === 'function';
+    if (bootstrap5) {
       document.querySelectorAll('.carousel').forEach(el => {
-        try {
-          const carousel = bootstrap.Carousel.getInstance(el);
-          if (carousel) {
-            if (typeof carousel.pause === 'function') carousel.pause();
-            if (typeof carousel.to === 'function') carousel.to(0);
-          }
-        } catch (e) {}
+        const carousel = bootstrap.Carousel.getInstance(el);
+        if (!carousel) return;
+        report('bootstrap', el, () => {
+          if (typeof carousel.pause === 'function') carousel.pause();
+          if (typeof carousel.to === 'function') carousel.to(0);
+        });
       });
     }
 
+    // Bootstrap 4 Carousel - a jQuery plugin
+    if (!bootstrap5 && typeof jQuery !== 'undefined' && typeof jQuery.fn.carousel === 'function') {
+      jQuery('.carousel').each(function() {
+        if (!jQuery(this).data('bs.carousel')) return;
+        report('bootstrap4', this, () => {
+          jQuery(this).carousel('pause');
+          jQuery(this).carousel(0);
+        });
+      });
+    }
+
+    // Libraries captured through their global constructors
+    instances.forEach(({ library, instance, element }) => {
+      if (element) managed.add(element);
+      report(library, element, () => freezers[library](instance));
+    });
+
+    // User hooks
+    hooks.forEach(hook => {
+      if (hook.error) {
+        report('hook', hook.selector, () => { throw hook.error; });
+        return;
+      }
+      let elements = [];
+      try {
+        elements = document.querySelectorAll(hook.selector);
+      } catch (e) {
+        report('hook', hook.selector, () => { throw e; });
+        return;
+      }
+      elements.forEach(el => {
+        managed.add(el);
+        report('hook', el, () => hook.freeze(el));
+      });
+    });
+
     // Generic: Reset transforms on common slider wrapper elements
     // This is safe even if the libraries aren't present
-    ['.swiper-wrapper', '.slick-track', '.owl-stage', '.flickity-slider'].forEach(selector => {
+    ['.swiper-wrapper', '.slick-track', '.owl-stage', '.flickity-slider',
+     '.splide__list', '.glide__slides', '.keen-slider', '.tns-slider'].forEach(selector => {
       document.querySelectorAll(selector).forEach(el => {
+        managed.add(el);
         try {
           el.style.transform = 'translate3d(0, 0, 0)';
           el.style.transition = 'none';
@@ -315,19 +1263,126 @@ const FreezeCarouselsScript = `
     });
   }
 
+  // Generic fallback: pin elements that keep moving on their own after load,
+  // either through their inline transform or their scroll position. An
+  // element counts as auto-advancing once it changes twice within
+  // autoAdvanceWindow milliseconds.
+  const autoAdvanceWindow = 10000;
+
+  function watchAutoAdvance() {
+    let userActive = false;
+    ['pointerdown', 'keydown', 'wheel', 'touchstart'].forEach(type => {
+      document.addEventListener(type, () => { userActive = true; }, { capture: true, passive: true });
+    });
+
+    const probe = document.createElement('div');
+    const pinnedTransforms = new Map();
+
+    // Per element: { since, before } of its first change in the window.
+    // Returns the value to pin to on the second change, undefined otherwise.
+    function secondChange(changes, el, before) {
+      const now = performance.now();
+      const first = changes.get(el);
+      if (!first || now - first.since > autoAdvanceWindow) {
+        changes.set(el, { since: now, before });
+        return undefined;
+      }
+      changes.delete(el);
+      return first.before;
+    }
+    const transformChanges = new Map();
+
+    function isManaged(el) {
+      for (let node = el; node; node = node.parentElement) {
+        if (managed.has(node)) return true;
+      }
+      return false;
+    }
+
+    // Scrolling triggered by the capture itself is not auto-advance either
+    const idle = () => !userActive && !window.__staticWebshotPreloading;
+
+    new MutationObserver(mutations => {
+      if (!idle()) return;
+      mutations.forEach(mutation => {
+        const el = mutation.target;
+        if (pinnedTransforms.has(el)) {
+          if (el.style.transform !== pinnedTransforms.get(el)) {
+            el.style.transform = pinnedTransforms.get(el);
+          }
+          return;
+        }
+        if (isManaged(el)) return;
+        probe.setAttribute('style', mutation.oldValue || '');
+        if (probe.style.transform === el.style.transform) return;
+        const before = secondChange(transformChanges, el, probe.style.transform);
+        if (before === undefined) return;
+
+        pinnedTransforms.set(el, before);
+        report('generic', el, () => {
+          el.style.transition = 'none';
+          el.style.transform = before;
+        });
+      });
+    }).observe(document.documentElement, {
+      attributes: true,
+      attributeFilter: ['style'],
+      attributeOldValue: true,
+      subtree: true
+    });
+
+    // Horizontal scrollers and their settled positions. A smooth scroll
+    // fires many scroll events, so a change is counted once it ends.
+    const scrollPositions = new Map();
+    document.querySelectorAll('body *').forEach(el => {
+      if (el.scrollWidth > el.clientWidth + 1) scrollPositions.set(el, el.scrollLeft);
+    });
+    const pinnedScrolls = new Map();
+    const scrollChanges = new Map();
+
+    document.addEventListener('scroll', event => {
+      const el = event.target;
+      if (!idle() || !pinnedScrolls.has(el)) return;
+      if (el.scrollLeft !== pinnedScrolls.get(el)) el.scrollLeft = pinnedScrolls.get(el);
+    }, true);
+
+    document.addEventListener('scrollend', event => {
+      const el = event.target;
+      if (!idle() || !scrollPositions.has(el) || pinnedScrolls.has(el) || isManaged(el)) return;
+      const previous = scrollPositions.get(el);
+      if (el.scrollLeft === previous) return;
+      scrollPositions.set(el, el.scrollLeft);
+      const before = secondChange(scrollChanges, el, previous);
+      if (before === undefined) return;
+
+      pinnedScrolls.set(el, before);
+      report('generic', el, () => { el.scrollLeft = before; });
+    }, true);
+  }
+
   // Run after page load (don't interfere with loading)
   window.addEventListener('load', () => {
     freezeSliders();
     setTimeout(freezeSliders, 100);
     setTimeout(freezeSliders, 300);
     setTimeout(freezeSliders, 500);
-    setTimeout(freezeSliders, 1000);
+    setTimeout(watchAutoAdvance, 100);
+
+    // Let the capture wait for the last pass when sliders were found
+    const lastPass = new Promise(resolve => {
+      setTimeout(() => {
+        freezeSliders();
+        resolve();
+      }, 1000);
+    });
+    if (reports.length > 0) pending.push(lastPass);
   });
 
   // Expose for manual use
   window.__freezeSliders = freezeSliders;
 })();
 `
+}
 
 // DisableWebAnimationsScript disables the Web Animations API.
 const DisableWebAnimationsScript = `
@@ -357,20 +1412,249 @@ const DisableWebAnimationsScript = `
 })();
 `
 
-// GetAllDeterministicScripts combines all deterministic scripts.
-// If mockTime is provided, the mock time script is included.
-func GetAllDeterministicScripts(mockTime string) string {
-	scripts := ""
+// Names of the toggleable deterministic scripts.
+const (
+	ScriptClock         = "clock"
+	ScriptRandom        = "random"
+	ScriptAutoplay      = "autoplay"
+	ScriptIntersection  = "intersection"
+	ScriptScroll        = "scroll"
+	ScriptWebAnimations = "web-animations"
+	ScriptCarousel      = "carousel"
+	ScriptImages        = "animated-images"
+	ScriptLottie        = "lottie"
+	ScriptCanvas        = "canvas"
+)
+
+// Names of the determinism profiles.
+const (
+	// ProfileDefault suppresses page-level nondeterminism.
+	ProfileDefault = "default"
+
+	// ProfileStrict additionally suppresses rendering-level noise: it adds
+	// the canvas script and is meant to be launched with
+	// ports.BrowserOptions.StrictRendering. Other profiles apply the canvas
+	// script only when Enable names it.
+	ProfileStrict = "strict"
+)
+
+// determinismAll selects every toggleable script.
+const determinismAll = "all"
+
+// builtinScripts lists the toggleable scripts in injection order. A script
+// function returns "" when the script does not apply to opts.
+var builtinScripts = []struct {
+	name   string
+	script func(opts DeterminismOptions) string
+}{
+	{ScriptClock, func(opts DeterminismOptions) string {
+		if opts.MockTime == "" {
+			return ""
+		}
+		return GenerateClockScript(opts.MockTime, opts.ClockStep)
+	}},
+	{ScriptRandom, func(opts DeterminismOptions) string {
+		if opts.RandomSeed != nil {
+			return GenerateRandomScript(*opts.RandomSeed)
+		}
+		if opts.MockTime == "" {
+			return ""
+		}
+		return GenerateRandomScript(0)
+	}},
+	{ScriptAutoplay, func(opts DeterminismOptions) string {
+		return GenerateDisableAutoplayScript(opts.VideoFrame)
+	}},
+	{ScriptIntersection, constantScript(FixIntersectionObserverScript)},
+	{ScriptScroll, constantScript(DisableScrollScript)},
+	{ScriptWebAnimations, constantScript(DisableWebAnimationsScript)},
+	{ScriptCarousel, func(opts DeterminismOptions) string {
+		return GenerateFreezeCarouselsScript(opts.CarouselHooks)
+	}},
+	{ScriptImages, constantScript(FreezeAnimatedImagesScript)},
+	{ScriptLottie, constantScript(FreezeLottieScript)},
+	{ScriptCanvas, func(opts DeterminismOptions) string {
+		if opts.Profile != ProfileStrict && !opts.enablesByName(ScriptCanvas) {
+			return ""
+		}
+		return StabilizeCanvasScript
+	}},
+}
 
-	if mockTime != "" {
-		scripts += GenerateMockTimeScript(mockTime) + "\n\n"
+// enablesByName reports whether Enable names script itself rather than
+// through "all".
+func (opts DeterminismOptions) enablesByName(script string) bool {
+	for _, name := range opts.Enable {
+		if strings.TrimSpace(name) == script {
+			return true
+		}
 	}
+	return false
+}
+
+// constantScript returns a script function that always applies script.
+func constantScript(script string) func(DeterminismOptions) string {
+	return func(DeterminismOptions) string {
+		return script
+	}
+}
+
+// DeterministicScriptNames returns the names of the toggleable scripts in
+// injection order.
+func DeterministicScriptNames() []string {
+	names := make([]string, len(builtinScripts))
+	for i, builtin := range builtinScripts {
+		names[i] = builtin.name
+	}
+	return names
+}
+
+// UserScript is a script supplied by the user and injected after the bundle.
+type UserScript struct {
+	Name   string
+	Source string
+}
+
+// UserScriptsRanExpression evaluates to the names of the user scripts that
+// ran to completion in the page. A script missing from it failed to parse or
+// threw.
+const UserScriptsRanExpression = `window.__staticWebshotUserScripts || []`
+
+// wrapUserScript appends a completion marker to a user script and names it
+// for error messages with a sourceURL comment. The source is left as is so
+// it still runs in global scope.
+func wrapUserScript(user UserScript) string {
+	name, _ := json.Marshal(user.Name)
+	sourceURL := strings.NewReplacer("\n", " ", "\r", " ").Replace(user.Name)
+	return fmt.Sprintf("%s\n;(window.__staticWebshotUserScripts = window.__staticWebshotUserScripts || []).push(%s);\n//# sourceURL=%s\n", user.Source, name, sourceURL)
+}
+
+// DeterminismOptions selects the scripts of the deterministic bundle.
+type DeterminismOptions struct {
+	// Profile is ProfileDefault (or "") or ProfileStrict.
+	Profile string
+
+	// MockTime is the start time of the virtual clock. It enables the clock
+	// script, and the random script with RandomSeed.
+	MockTime string
+
+	// ClockStep is the number of milliseconds the clock advances per read.
+	ClockStep float64
+
+	// RandomSeed seeds the random script and enables it even without
+	// MockTime (nil = seed 0 with MockTime, otherwise disabled).
+	RandomSeed *int64
+
+	// CarouselHooks are run by the carousel script for matching elements.
+	CarouselHooks []CarouselHook
+
+	// VideoFrame is what videos show: "" (first frame), VideoFramePoster or
+	// a time in seconds.
+	VideoFrame string
+
+	// Disable lists toggleable scripts to leave out, or "all".
+	Disable []string
+
+	// Enable lists toggleable scripts to keep even if disabled, or "all".
+	Enable []string
+
+	// UserScripts are injected after the built-in scripts, each on its own.
+	UserScripts []UserScript
+}
 
-	scripts += DisableAutoplayScript + "\n\n"
-	scripts += FixIntersectionObserverScript + "\n\n"
-	scripts += DisableScrollScript + "\n\n"
-	scripts += DisableWebAnimationsScript + "\n\n"
-	scripts += FreezeCarouselsScript
+// BuildDeterministicScripts returns the scripts to inject, in order: the
+// selected built-in scripts combined into one bundle, then every user script
+// on its own, so a user script that fails to parse or throws cannot take the
+// others down with it. It also returns the names of the applied scripts in
+// injection order.
+func BuildDeterministicScripts(opts DeterminismOptions) ([]string, []string, error) {
+	switch opts.Profile {
+	case "", ProfileDefault, ProfileStrict:
+	default:
+		return nil, nil, fmt.Errorf("unknown determinism profile %q (want %s or %s)", opts.Profile, ProfileDefault, ProfileStrict)
+	}
+	if err := validateVideoFrame(opts.VideoFrame); err != nil {
+		return nil, nil, err
+	}
+
+	disabled, err := scriptSet(opts.Disable)
+	if err != nil {
+		return nil, nil, fmt.Errorf("disable determinism: %w", err)
+	}
+	enabled, err := scriptSet(opts.Enable)
+	if err != nil {
+		return nil, nil, fmt.Errorf("enable determinism: %w", err)
+	}
+
+	var bundle []string
+	var applied []string
+
+	for _, builtin := range builtinScripts {
+		if disabled[builtin.name] && !enabled[builtin.name] {
+			continue
+		}
+		script := builtin.script(opts)
+		if script == "" {
+			continue
+		}
+		bundle = append(bundle, script)
+		applied = append(applied, builtin.name)
+	}
+
+	var scripts []string
+	if len(bundle) > 0 {
+		scripts = append(scripts, strings.Join(bundle, "\n;\n"))
+	}
+	for _, user := range opts.UserScripts {
+		scripts = append(scripts, wrapUserScript(user))
+		applied = append(applied, user.Name)
+	}
 
-	return scripts
+	return scripts, applied, nil
+}
+
+// validateVideoFrame accepts "", VideoFramePoster or a non-negative number of
+// seconds.
+func validateVideoFrame(videoFrame string) error {
+	if videoFrame == "" || videoFrame == VideoFramePoster {
+		return nil
+	}
+	seconds, err := strconv.ParseFloat(videoFrame, 64)
+	if err != nil || seconds < 0 || math.IsInf(seconds, 0) || math.IsNaN(seconds) {
+		return fmt.Errorf("invalid video frame %q (want %s or a time in seconds)", videoFrame, VideoFramePoster)
+	}
+	return nil
+}
+
+// scriptSet resolves toggleable script names, expanding "all".
+func scriptSet(names []string) (map[string]bool, error) {
+	set := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == determinismAll {
+			for _, builtin := range builtinScripts {
+				set[builtin.name] = true
+			}
+			continue
+		}
+		known := false
+		for _, builtin := range builtinScripts {
+			if builtin.name == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown script %q (available: %s, all)", name, strings.Join(DeterministicScriptNames(), ", "))
+		}
+		set[name] = true
+	}
+	return set, nil
+}
+
+// GetAllDeterministicScripts combines all deterministic scripts.
+// If mockTime is provided, the clock and random scripts are included.
+func GetAllDeterministicScripts(mockTime string) string {
+	scripts, _, _ := BuildDeterministicScripts(DeterminismOptions{MockTime: mockTime, ClockStep: DefaultClockStep})
+	return strings.Join(scripts, "\n;\n")
 }
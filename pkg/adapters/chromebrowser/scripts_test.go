@@ -3,6 +3,8 @@ package chromebrowser
 import (
 	"strings"
 	"testing"
+
+	"github.com/ideamans/static-webshot/pkg/ports"
 )
 
 func TestDisableAnimationsCSS(t *testing.T) {
@@ -16,37 +18,78 @@ func TestDisableAnimationsCSS(t *testing.T) {
 }
 
 func TestGenerateMockTimeScript(t *testing.T) {
+	script := GenerateMockTimeScript("2024-01-01T00:00:00Z")
+
+	for _, want := range []string{"2024-01-01T00:00:00Z", "Date", "performance.now", "Math.random"} {
+		if !strings.Contains(script, want) {
+			t.Errorf("GenerateMockTimeScript() does not contain %q", want)
+		}
+	}
+	if want := GenerateClockScript("2024-01-01T00:00:00Z", 0); !strings.HasPrefix(script, want) {
+		t.Error("GenerateMockTimeScript() does not start with the frozen clock script")
+	}
+}
+
+func TestGenerateClockScript(t *testing.T) {
 	tests := []struct {
 		name         string
-		fixedTime    string
+		startTime    string
+		step         float64
 		wantContains []string
 	}{
 		{
-			name:      "generates script with fixed time and random",
-			fixedTime: "2024-01-01T00:00:00Z",
+			name:      "generates script with start time and step",
+			startTime: "2024-01-01T00:00:00Z",
+			step:      1,
 			wantContains: []string{
 				"2024-01-01T00:00:00Z",
+				"const step = 1;",
 				"Date",
-				"Math.random",
-				"return 0.5",
 				"performance.now",
+				"requestAnimationFrame",
+			},
+		},
+		{
+			name:      "fractional step",
+			startTime: "2024-01-01T00:00:00Z",
+			step:      0.5,
+			wantContains: []string{
+				"const step = 0.5;",
 			},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			script := GenerateMockTimeScript(tt.fixedTime)
+			script := GenerateClockScript(tt.startTime, tt.step)
 
 			for _, want := range tt.wantContains {
 				if !strings.Contains(script, want) {
-					t.Errorf("GenerateMockTimeScript() does not contain %q", want)
+					t.Errorf("GenerateClockScript() does not contain %q", want)
 				}
 			}
 		})
 	}
 }
 
+func TestGenerateRandomScript(t *testing.T) {
+	script := GenerateRandomScript(42)
+
+	for _, want := range []string{"const seed = 42;", "Math.random", "getRandomValues", "randomUUID"} {
+		if !strings.Contains(script, want) {
+			t.Errorf("GenerateRandomScript() does not contain %q", want)
+		}
+	}
+	if strings.Contains(script, "return 0.5") {
+		t.Error("GenerateRandomScript() should not return a constant")
+	}
+
+	// Negative seeds wrap to their low 32 bits
+	if !strings.Contains(GenerateRandomScript(-1), "const seed = 4294967295;") {
+		t.Error("GenerateRandomScript(-1) does not use the low 32 bits of the seed")
+	}
+}
+
 func TestGetAllDeterministicScripts(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -63,14 +106,14 @@ func TestGetAllDeterministicScripts(t *testing.T) {
 				"animate",
 			},
 			wantMissing: []string{
-				"fixedTimestamp",
+				"startTimestamp",
 			},
 		},
 		{
 			name:     "with mock time",
 			mockTime: "2024-01-01T00:00:00Z",
 			wantContains: []string{
-				"fixedTimestamp",
+				"startTimestamp",
 				"IntersectionObserver",
 				"scrollTo",
 				"animate",
@@ -96,3 +139,267 @@ func TestGetAllDeterministicScripts(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildDeterministicScripts(t *testing.T) {
+	seed0, seed7 := int64(0), int64(7)
+	tests := []struct {
+		name        string
+		opts        DeterminismOptions
+		wantApplied []string
+		wantErr     bool
+	}{
+		{
+			name:        "defaults",
+			opts:        DeterminismOptions{},
+			wantApplied: []string{"autoplay", "intersection", "scroll", "web-animations", "carousel", "animated-images", "lottie"},
+		},
+		{
+			name:        "mock time adds clock and random first",
+			opts:        DeterminismOptions{MockTime: "2024-01-01T00:00:00Z"},
+			wantApplied: []string{"clock", "random", "autoplay", "intersection", "scroll", "web-animations", "carousel", "animated-images", "lottie"},
+		},
+		{
+			name:        "random seed without mock time",
+			opts:        DeterminismOptions{RandomSeed: &seed7, Disable: []string{"all"}, Enable: []string{"random"}},
+			wantApplied: []string{"random"},
+		},
+		{
+			name:        "random seed 0 without mock time",
+			opts:        DeterminismOptions{RandomSeed: &seed0, Disable: []string{"all"}, Enable: []string{"random"}},
+			wantApplied: []string{"random"},
+		},
+		{
+			name:        "no random seed without mock time",
+			opts:        DeterminismOptions{Disable: []string{"all"}, Enable: []string{"random"}},
+			wantApplied: nil,
+		},
+		{
+			name:        "mock time with real random",
+			opts:        DeterminismOptions{MockTime: "2024-01-01T00:00:00Z", Disable: []string{"random", "carousel"}},
+			wantApplied: []string{"clock", "autoplay", "intersection", "scroll", "web-animations", "animated-images", "lottie"},
+		},
+		{
+			name:        "disable some",
+			opts:        DeterminismOptions{Disable: []string{"scroll", "intersection"}},
+			wantApplied: []string{"autoplay", "web-animations", "carousel", "animated-images", "lottie"},
+		},
+		{
+			name:        "disable all but enable one",
+			opts:        DeterminismOptions{Disable: []string{"all"}, Enable: []string{"carousel"}},
+			wantApplied: []string{"carousel"},
+		},
+		{
+			name: "user scripts come last",
+			opts: DeterminismOptions{
+				Disable:     []string{"all"},
+				UserScripts: []UserScript{{Name: "freeze.js", Source: "window.__frozen = true;"}},
+			},
+			wantApplied: []string{"freeze.js"},
+		},
+		{
+			name:        "strict profile adds canvas",
+			opts:        DeterminismOptions{Profile: ProfileStrict, Disable: []string{"autoplay", "intersection", "scroll", "animated-images", "lottie"}},
+			wantApplied: []string{"web-animations", "carousel", "canvas"},
+		},
+		{
+			name:        "canvas enabled by name without the strict profile",
+			opts:        DeterminismOptions{Disable: []string{"all"}, Enable: []string{"canvas"}},
+			wantApplied: []string{"canvas"},
+		},
+		{
+			name:        "canvas not enabled by all without the strict profile",
+			opts:        DeterminismOptions{Enable: []string{"all"}, Disable: []string{"carousel", "animated-images", "lottie"}},
+			wantApplied: []string{"autoplay", "intersection", "scroll", "web-animations", "carousel", "animated-images", "lottie"},
+		},
+		{
+			name:    "unknown profile",
+			opts:    DeterminismOptions{Profile: "paranoid"},
+			wantErr: true,
+		},
+		{
+			name:        "video frame time",
+			opts:        DeterminismOptions{VideoFrame: "2.5", Disable: []string{"all"}, Enable: []string{"autoplay"}},
+			wantApplied: []string{"autoplay"},
+		},
+		{
+			name:    "invalid video frame",
+			opts:    DeterminismOptions{VideoFrame: "middle"},
+			wantErr: true,
+		},
+		{
+			name:    "negative video frame",
+			opts:    DeterminismOptions{VideoFrame: "-1"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown name",
+			opts:    DeterminismOptions{Disable: []string{"scrolling"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, applied, err := BuildDeterministicScripts(tt.opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("BuildDeterministicScripts() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("BuildDeterministicScripts() error = %v", err)
+			}
+			if strings.Join(applied, ",") != strings.Join(tt.wantApplied, ",") {
+				t.Errorf("applied = %v, want %v", applied, tt.wantApplied)
+			}
+		})
+	}
+}
+
+func TestBuildDeterministicScripts_Disabled(t *testing.T) {
+	scripts, _, err := BuildDeterministicScripts(DeterminismOptions{Disable: []string{"scroll"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scripts) != 1 {
+		t.Fatalf("BuildDeterministicScripts() returned %d scripts, want the built-in bundle only", len(scripts))
+	}
+	if strings.Contains(scripts[0], "window.scrollTo = noop") {
+		t.Error("disabled scroll script was injected")
+	}
+	if !strings.Contains(scripts[0], "IntersectionObserver") {
+		t.Error("intersection script is missing")
+	}
+}
+
+func TestBuildDeterministicScripts_UserScripts(t *testing.T) {
+	scripts, _, err := BuildDeterministicScripts(DeterminismOptions{
+		UserScripts: []UserScript{
+			{Name: "broken.js", Source: "window.__broken = ("},
+			{Name: "freeze.js", Source: "window.__frozen = true; // no newline"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A user script that does not parse must not take the others down, so
+	// each is injected on its own after the bundle
+	if len(scripts) != 3 {
+		t.Fatalf("BuildDeterministicScripts() returned %d scripts, want the bundle and 2 user scripts", len(scripts))
+	}
+	for i, name := range []string{"broken.js", "freeze.js"} {
+		script := scripts[i+1]
+		if !strings.Contains(script, `.push("`+name+`");`) {
+			t.Errorf("%s does not record that it ran:\n%s", name, script)
+		}
+		if !strings.HasSuffix(script, "\n//# sourceURL="+name+"\n") {
+			t.Errorf("%s is not named by a sourceURL:\n%s", name, script)
+		}
+	}
+
+	// The marker starts on its own line, out of a trailing line comment
+	if !strings.Contains(scripts[2], "// no newline\n;(window.__staticWebshotUserScripts") {
+		t.Errorf("marker is not separated from the script:\n%s", scripts[2])
+	}
+}
+
+func TestGenerateDisableAutoplayScript(t *testing.T) {
+	tests := []struct {
+		videoFrame string
+		want       string
+	}{
+		{"", `const videoFrame = "";`},
+		{"poster", `const videoFrame = "poster";`},
+		{"2.5", `const videoFrame = "2.5";`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.videoFrame, func(t *testing.T) {
+			if script := GenerateDisableAutoplayScript(tt.videoFrame); !strings.Contains(script, tt.want) {
+				t.Errorf("GenerateDisableAutoplayScript(%q) does not contain %q", tt.videoFrame, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateFreezeCarouselsScript(t *testing.T) {
+	script := GenerateFreezeCarouselsScript([]CarouselHook{
+		{Selector: `[data-carousel="hero"]`, Script: "element.dataset.frozen = 'true';"},
+		{Selector: ".broken", Script: "} }]; window.escaped = true; [{"},
+	})
+
+	// Hook sources stay string literals compiled with new Function, so a
+	// broken hook cannot break out of the script
+	for _, want := range []string{
+		"Splide", "Glide", "EmblaCarousel", "KeenSlider", "tns", "bs.carousel",
+		`selector: "[data-carousel=\"hero\"]", source: "element.dataset.frozen = 'true';"`,
+		`selector: ".broken", source: "} }]; window.escaped = true; [{"`,
+		"new Function('element', source)",
+		"__staticWebshotCarousels",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("GenerateFreezeCarouselsScript() does not contain %q", want)
+		}
+	}
+}
+
+func TestPreloadScrollScript(t *testing.T) {
+	// Preloading must scroll with the original scrollTo, which
+	// DisableScrollScript replaces with a no-op
+	const saved = "window.__staticWebshotScrollTo"
+	if !strings.Contains(DisableScrollScript, saved+" = window.scrollTo.bind(window)") {
+		t.Errorf("DisableScrollScript does not save the original scrollTo")
+	}
+	if !strings.Contains(PreloadScrollScript, saved) {
+		t.Errorf("PreloadScrollScript does not use the saved scrollTo")
+	}
+}
+
+func TestGenerateFixedElementsScript(t *testing.T) {
+	for _, mode := range []string{"", FixedElementsKeep} {
+		if script, err := GenerateFixedElementsScript(mode); err != nil || script != "" {
+			t.Errorf("GenerateFixedElementsScript(%q) = %q, %v, want empty script", mode, script, err)
+		}
+	}
+
+	for _, mode := range []string{FixedElementsStatic, FixedElementsHideAll} {
+		script, err := GenerateFixedElementsScript(mode)
+		if err != nil {
+			t.Fatalf("GenerateFixedElementsScript(%q) error: %v", mode, err)
+		}
+		if want := `const mode = "` + mode + `";`; !strings.Contains(script, want) {
+			t.Errorf("GenerateFixedElementsScript(%q) does not contain %q", mode, want)
+		}
+	}
+
+	for _, mode := range []string{"remove", "hide"} {
+		if _, err := GenerateFixedElementsScript(mode); err == nil {
+			t.Errorf("GenerateFixedElementsScript(%q) expected error", mode)
+		}
+	}
+}
+
+func TestGenerateMaskOverlayScript(t *testing.T) {
+	script := GenerateMaskOverlayScript([]ports.Mask{
+		{Selector: ".ad", Style: ports.MaskBlackout},
+		{Selector: `[data-role="date"]`, Style: ports.MaskBox, Color: "#ff00ff"},
+		{Selector: ".logo", Style: ports.MaskBox},
+		{Selector: ".avatar"},
+		{Selector: ".face", Style: ports.MaskBlur},
+	})
+
+	for _, want := range []string{
+		`{ selector: ".ad", style: "blackout", color: "#000", spread: 0 }`,
+		`{ selector: "[data-role=\"date\"]", style: "box", color: "#ff00ff", spread: 0 }`,
+		`{ selector: ".logo", style: "box", color: "` + DefaultMaskBoxColor + `", spread: 0 }`,
+		`{ selector: ".avatar", style: "hide", color: "", spread: 0 }`,
+		// The blur bleeds out of the element
+		`{ selector: ".face", style: "blur", color: "", spread: 32 }`,
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("GenerateMaskOverlayScript() does not contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Package gitcli provides a version control implementation using the git CLI.
+package gitcli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
+// Git implements ports.VersionControl by running git worktree commands.
+type Git struct{}
+
+// New creates a new Git.
+func New() *Git {
+	return &Git{}
+}
+
+// CreateWorktree checks rev out into a new temporary directory as a detached
+// worktree and returns its path.
+func (g *Git) CreateWorktree(ctx context.Context, repoDir, rev string) (string, error) {
+	dir, err := os.MkdirTemp("", "static-webshot-rev-")
+	if err != nil {
+		return "", fmt.Errorf("create worktree directory: %w", err)
+	}
+
+	if _, err := g.run(ctx, repoDir, "worktree", "add", "--detach", "--force", dir, rev); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("check out %s: %w", rev, err)
+	}
+
+	return dir, nil
+}
+
+// RemoveWorktree unregisters the worktree and deletes its directory.
+func (g *Git) RemoveWorktree(ctx context.Context, repoDir, dir string) error {
+	_, gitErr := g.run(ctx, repoDir, "worktree", "remove", "--force", dir)
+
+	// Remove the directory even if git refused, so no checkout is left behind
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("remove worktree %s: %w", dir, err)
+	}
+	if gitErr != nil {
+		// The directory is gone; let git forget the stale registration
+		g.run(ctx, repoDir, "worktree", "prune")
+	}
+	return nil
+}
+
+// run executes git with the given arguments in dir and returns its stdout.
+func (g *Git) run(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return "", fmt.Errorf("git %s: %w", args[0], err)
+		}
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+	}
+
+	return stdout.String(), nil
+}
+
+// Ensure Git implements ports.VersionControl
+var _ ports.VersionControl = (*Git)(nil)
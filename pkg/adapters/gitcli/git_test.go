@@ -0,0 +1,111 @@
+package gitcli
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newRepo creates a repository with one commit holding index.html and
+// returns its directory.
+func newRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"add", "index.html"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "v1"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", args[0], err, output)
+		}
+	}
+	return dir
+}
+
+// worktrees lists the worktrees git has registered for repoDir.
+func worktrees(t *testing.T, repoDir string) string {
+	t.Helper()
+	output, err := New().run(context.Background(), repoDir, "worktree", "list", "--porcelain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return output
+}
+
+func TestGit_Worktree(t *testing.T) {
+	repo := newRepo(t)
+	git := New()
+	ctx := context.Background()
+
+	dir, err := git.CreateWorktree(ctx, repo, "HEAD")
+	if err != nil {
+		t.Fatalf("CreateWorktree() error = %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "index.html"))
+	if err != nil || string(content) != "v1" {
+		t.Errorf("checked out index.html = %q, %v, want %q", content, err, "v1")
+	}
+	if !strings.Contains(worktrees(t, repo), filepath.Base(dir)) {
+		t.Errorf("worktree %s is not registered", dir)
+	}
+
+	if err := git.RemoveWorktree(ctx, repo, dir); err != nil {
+		t.Fatalf("RemoveWorktree() error = %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("worktree directory still exists: %v", err)
+	}
+	if strings.Contains(worktrees(t, repo), filepath.Base(dir)) {
+		t.Errorf("worktree %s is still registered", dir)
+	}
+}
+
+func TestGit_CreateWorktree_UnknownRev(t *testing.T) {
+	repo := newRepo(t)
+
+	_, err := New().CreateWorktree(context.Background(), repo, "no-such-rev")
+	if err == nil {
+		t.Fatal("CreateWorktree() expected error for an unknown revision")
+	}
+	if !strings.Contains(err.Error(), "check out no-such-rev") {
+		t.Errorf("CreateWorktree() error = %v, want it to name the revision", err)
+	}
+	if strings.Count(worktrees(t, repo), "worktree ") != 1 {
+		t.Error("CreateWorktree() left a worktree registered after failing")
+	}
+}
+
+func TestGit_RemoveWorktree_AlreadyDeleted(t *testing.T) {
+	repo := newRepo(t)
+	git := New()
+	ctx := context.Background()
+
+	dir, err := git.CreateWorktree(ctx, repo, "HEAD")
+	if err != nil {
+		t.Fatalf("CreateWorktree() error = %v", err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// git refuses to remove a missing worktree; the stale entry is pruned
+	if err := git.RemoveWorktree(ctx, repo, dir); err != nil {
+		t.Fatalf("RemoveWorktree() error = %v", err)
+	}
+	if strings.Contains(worktrees(t, repo), filepath.Base(dir)) {
+		t.Errorf("stale worktree %s is still registered", dir)
+	}
+}
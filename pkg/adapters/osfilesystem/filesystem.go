@@ -37,6 +37,11 @@ func (fs *OSFileSystem) Exists(path string) bool {
 	return err == nil
 }
 
+// Remove removes the file at the given path.
+func (fs *OSFileSystem) Remove(path string) error {
+	return os.Remove(path)
+}
+
 // MkdirAll creates a directory along with any necessary parents.
 func (fs *OSFileSystem) MkdirAll(path string, perm os.FileMode) error {
 	return os.MkdirAll(path, perm)
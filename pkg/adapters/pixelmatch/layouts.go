@@ -0,0 +1,115 @@
+package pixelmatch
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"sort"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
+// cropPadding is the context in pixels kept around each region of
+// differing pixels when cropping to changes.
+const cropPadding = 32
+
+// galleryGap is the space in pixels between the regions of a gallery.
+const galleryGap = 8
+
+// panels holds the images diff images are laid out from. Only the ones the
+// layout needs are set: diff is the overlay diff panel and mask the
+// pixelmatch diff mask.
+type panels struct {
+	baseline, current, diff, mask *image.RGBA
+}
+
+// crop returns the panels cropped to r.
+func (ps panels) crop(r image.Rectangle) panels {
+	sub := func(img *image.RGBA) *image.RGBA {
+		if img == nil {
+			return nil
+		}
+		return img.SubImage(r).(*image.RGBA)
+	}
+	return panels{baseline: sub(ps.baseline), current: sub(ps.current), diff: sub(ps.diff), mask: sub(ps.mask)}
+}
+
+// renderLayout lays the panels out as layout says. labels are the baseline,
+// diff and current labels.
+func (p *Processor) renderLayout(layout string, ps panels, labels []string, face font.Face, labelHeight int) *image.RGBA {
+	switch layout {
+	case ports.DiffLayoutVertical:
+		return p.createCompositeImage([]*image.RGBA{ps.baseline, ps.diff, ps.current}, labels, true, face, labelHeight)
+	case ports.DiffLayoutDiffOnly:
+		return ps.diff
+	case ports.DiffLayoutPair:
+		return p.createCompositeImage([]*image.RGBA{ps.baseline, ps.current}, []string{labels[0], labels[2]}, false, face, labelHeight)
+	case ports.DiffLayoutRawPixelmatch:
+		return ps.mask
+	default:
+		return p.createCompositeImage([]*image.RGBA{ps.baseline, ps.diff, ps.current}, labels, false, face, labelHeight)
+	}
+}
+
+// cropRegions pads the regions of differing pixels with context, clipped to
+// bounds, and merges the ones that then overlap. The crops are sorted top to
+// bottom.
+func cropRegions(regions []image.Rectangle, bounds image.Rectangle) []image.Rectangle {
+	crops := make([]image.Rectangle, 0, len(regions))
+	for _, r := range regions {
+		crops = append(crops, r.Inset(-cropPadding).Intersect(bounds))
+	}
+
+	for merged := true; merged; {
+		merged = false
+		for i := 0; i < len(crops) && !merged; i++ {
+			for j := i + 1; j < len(crops); j++ {
+				if crops[i].Overlaps(crops[j]) {
+					crops[i] = crops[i].Union(crops[j])
+					crops = append(crops[:j], crops[j+1:]...)
+					merged = true
+					break
+				}
+			}
+		}
+	}
+
+	sort.Slice(crops, func(i, j int) bool {
+		if crops[i].Min.Y != crops[j].Min.Y {
+			return crops[i].Min.Y < crops[j].Min.Y
+		}
+		return crops[i].Min.X < crops[j].Min.X
+	})
+	return crops
+}
+
+// createGallery stacks the rendering of each crop under a caption with its
+// number, position and size.
+func (p *Processor) createGallery(crops []image.Rectangle, render func(image.Rectangle) *image.RGBA, face font.Face, labelHeight int) *image.RGBA {
+	entries := make([]*image.RGBA, len(crops))
+	width, height := 0, galleryGap*(len(crops)-1)
+	for i, r := range crops {
+		entries[i] = render(r)
+		width = max(width, entries[i].Bounds().Dx())
+		height += labelHeight + entries[i].Bounds().Dy()
+	}
+
+	gallery := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(gallery, gallery.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	y := 0
+	for i, r := range crops {
+		caption := fmt.Sprintf("#%d  %d,%d  %dx%d", i+1, r.Min.X, r.Min.Y, r.Dx(), r.Dy())
+		p.drawLabelBar(gallery, image.Rect(0, y, width, y+labelHeight), caption, face)
+		y += labelHeight
+
+		entry := entries[i]
+		draw.Draw(gallery, image.Rect(0, y, entry.Bounds().Dx(), y+entry.Bounds().Dy()), entry, entry.Bounds().Min, draw.Src)
+		y += entry.Bounds().Dy() + galleryGap
+	}
+
+	return gallery
+}
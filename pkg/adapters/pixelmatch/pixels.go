@@ -0,0 +1,157 @@
+package pixelmatch
+
+import (
+	"image"
+	"image/draw"
+	"runtime"
+	"sync"
+
+	"github.com/orisano/pixelmatch"
+)
+
+// minBandRows is the smallest band of rows worth handing to a goroutine.
+// Images shorter than that are processed on the calling goroutine.
+const minBandRows = 64
+
+// parallelRows splits the rows [0, height) into contiguous bands, one per
+// available CPU, and calls fn for each band concurrently. Band boundaries are
+// multiples of align, so work grouped by rows never straddles two bands.
+func parallelRows(height, align int, fn func(y0, y1 int)) {
+	align = max(align, 1)
+	workers := runtime.GOMAXPROCS(0)
+	band := max((height+workers-1)/workers, minBandRows)
+	band = (band + align - 1) / align * align
+	if band >= height {
+		fn(0, height)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for y0 := 0; y0 < height; y0 += band {
+		wg.Add(1)
+		go func(y0, y1 int) {
+			defer wg.Done()
+			fn(y0, y1)
+		}(y0, min(y0+band, height))
+	}
+	wg.Wait()
+}
+
+// rowPix returns the pixels of row y of img, counted from the top of its
+// bounds, as a slice of RGBA quadruplets.
+func rowPix(img *image.RGBA, y int) []uint8 {
+	bounds := img.Bounds()
+	i := img.PixOffset(bounds.Min.X, bounds.Min.Y+y)
+	return img.Pix[i : i+bounds.Dx()*4 : i+bounds.Dx()*4]
+}
+
+// fillRow returns a row of width pixels of color c.
+func fillRow(width int, c [4]uint8) []uint8 {
+	row := make([]uint8, width*4)
+	for i := 0; i < len(row); i += 4 {
+		copy(row[i:i+4], c[:])
+	}
+	return row
+}
+
+// diffLimit converts a normalized per-pixel threshold into the largest sum of
+// absolute 8-bit differences over the given number of channels that still
+// counts as equal. The epsilon keeps thresholds such as 10/255 from rounding
+// down a whole step.
+func diffLimit(threshold float64, channels int) int {
+	return int(threshold*float64(255*channels) + 1e-9)
+}
+
+// channelDiff returns the sum of absolute differences of the first n channels
+// of two pixels.
+func channelDiff(a, b []uint8, n int) int {
+	sum := 0
+	for i := 0; i < n; i++ {
+		if a[i] > b[i] {
+			sum += int(a[i] - b[i])
+		} else {
+			sum += int(b[i] - a[i])
+		}
+	}
+	return sum
+}
+
+// matchPixels counts the pixels pixelmatch considers different and, when
+// wantDiff is set, returns a diff mask: differing pixels red on white. The
+// diff image is nil when the images are identical.
+//
+// Without anti-aliasing detection every pixel is judged on its own, so the
+// images are matched in row bands in parallel. Anti-aliasing detection looks
+// at neighbouring pixels across rows, so with it the images are matched whole.
+func matchPixels(baseline, current *image.RGBA, detectAA, wantDiff bool, opts []pixelmatch.MatchOption) (int, *image.RGBA, error) {
+	bounds := baseline.Bounds()
+	var diffImg *image.RGBA
+	if wantDiff {
+		diffImg = image.NewRGBA(bounds)
+	}
+
+	var (
+		mu       sync.Mutex
+		total    int
+		firstErr error
+		written  bool
+		blank    []image.Rectangle
+	)
+	match := func(r image.Rectangle) {
+		var out image.Image
+		bandOpts := opts[:len(opts):len(opts)]
+		if wantDiff {
+			bandOpts = append(bandOpts, pixelmatch.WriteTo(&out))
+		}
+		count, err := pixelmatch.MatchPixel(baseline.SubImage(r), current.SubImage(r), bandOpts...)
+		if out != nil {
+			draw.Draw(diffImg, r, out, r.Min, draw.Src)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		total += count
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if out != nil {
+			written = true
+		} else {
+			blank = append(blank, r)
+		}
+	}
+	if detectAA {
+		match(bounds)
+	} else {
+		parallelRows(bounds.Dy(), 1, func(y0, y1 int) {
+			match(image.Rect(bounds.Min.X, bounds.Min.Y+y0, bounds.Max.X, bounds.Min.Y+y1))
+		})
+	}
+	if firstErr != nil {
+		return 0, nil, firstErr
+	}
+	if !written {
+		return total, nil, nil
+	}
+
+	// pixelmatch writes no image for identical bands
+	for _, r := range blank {
+		draw.Draw(diffImg, r, image.White, image.Point{}, draw.Src)
+	}
+
+	// pixelmatch's own diff mask keeps marks from earlier rows in its line
+	// buffer, so the mask is derived from its full output instead: only
+	// differing pixels are painted pure red there.
+	parallelRows(bounds.Dy(), 1, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			d := rowPix(diffImg, y)
+			for i := 0; i < len(d); i += 4 {
+				if d[i] != 255 || d[i+1] != 0 || d[i+2] != 0 {
+					d[i], d[i+1], d[i+2] = 255, 255, 255
+				}
+				d[i+3] = 255
+			}
+		}
+	})
+	return total, diffImg, nil
+}
@@ -2,19 +2,21 @@
 package pixelmatch
 
 import (
+	"bytes"
 	"fmt"
 	"image"
 	"image/color"
-	"image/png"
 	"os"
 	"path/filepath"
 
 	"github.com/orisano/pixelmatch"
+	"golang.org/x/image/draw"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/basicfont"
 	"golang.org/x/image/font/opentype"
 	"golang.org/x/image/math/fixed"
 
+	"github.com/ideamans/static-webshot/pkg/imageformat"
 	"github.com/ideamans/static-webshot/pkg/ports"
 )
 
@@ -42,8 +44,23 @@ func (p *Processor) LoadImage(path string) (image.Image, error) {
 	return img, nil
 }
 
-// SaveImage saves an image to the given file path.
-func (p *Processor) SaveImage(path string, img image.Image) error {
+// DecodeImage decodes an image from encoded bytes held in memory.
+func (p *Processor) DecodeImage(data []byte) (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+
+	return img, nil
+}
+
+// SaveImage saves an image to the given file path, encoded as opts says.
+func (p *Processor) SaveImage(path string, img image.Image, opts ports.EncodeOptions) error {
+	opts, err := imageformat.Resolve(opts, path)
+	if err != nil {
+		return err
+	}
+
 	// Ensure the parent directory exists
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -56,7 +73,7 @@ func (p *Processor) SaveImage(path string, img image.Image) error {
 	}
 	defer f.Close()
 
-	if err := png.Encode(f, img); err != nil {
+	if err := imageformat.Encode(f, img, opts); err != nil {
 		return fmt.Errorf("encode image %s: %w", path, err)
 	}
 
@@ -65,6 +82,10 @@ func (p *Processor) SaveImage(path string, img image.Image) error {
 
 // Compare compares two images and returns the comparison result.
 func (p *Processor) Compare(baseline, current image.Image, opts ports.CompareOptions) (*ports.CompareResult, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
 	baselineBounds := baseline.Bounds()
 	currentBounds := current.Bounds()
 
@@ -72,6 +93,25 @@ func (p *Processor) Compare(baseline, current image.Image, opts ports.CompareOpt
 	width := max(baselineBounds.Dx(), currentBounds.Dx())
 	height := max(baselineBounds.Dy(), currentBounds.Dy())
 
+	if baselineBounds.Size() != currentBounds.Size() {
+		switch opts.SizeMismatch {
+		case "", ports.SizeMismatchPad:
+		case ports.SizeMismatchCrop:
+			// Compare the area both images have
+			width = min(baselineBounds.Dx(), currentBounds.Dx())
+			height = min(baselineBounds.Dy(), currentBounds.Dy())
+		case ports.SizeMismatchFail:
+			return nil, fmt.Errorf("images differ in size: baseline is %dx%d, current is %dx%d",
+				baselineBounds.Dx(), baselineBounds.Dy(), currentBounds.Dx(), currentBounds.Dy())
+		case ports.SizeMismatchScale:
+			// Stretch current to the size of baseline
+			width, height = baselineBounds.Dx(), baselineBounds.Dy()
+			scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+			draw.CatmullRom.Scale(scaled, scaled.Bounds(), current, currentBounds, draw.Src, nil)
+			current = scaled
+		}
+	}
+
 	// Apply max height limit if specified
 	if opts.MaxHeight > 0 && height > opts.MaxHeight {
 		height = opts.MaxHeight
@@ -80,16 +120,13 @@ func (p *Processor) Compare(baseline, current image.Image, opts ports.CompareOpt
 	totalPixels := width * height
 
 	// Crop/normalize both images to the same size
-	baseline = p.normalizeImage(baseline, width, height)
-	current = p.normalizeImage(current, width, height)
+	normBaseline := p.normalizeImage(baseline, width, height)
+	normCurrent := p.normalizeImage(current, width, height)
 
 	// Apply ignore regions by masking them in both images
-	maskedBaseline := baseline
-	maskedCurrent := current
-
 	if len(opts.IgnoreRegions) > 0 {
-		maskedBaseline = p.applyMask(baseline, opts.IgnoreRegions)
-		maskedCurrent = p.applyMask(current, opts.IgnoreRegions)
+		p.applyMask(normBaseline, opts.IgnoreRegions)
+		p.applyMask(normCurrent, opts.IgnoreRegions)
 	}
 
 	// Color threshold (normalized to 0-1 range for pixelmatch)
@@ -99,14 +136,10 @@ func (p *Processor) Compare(baseline, current image.Image, opts ports.CompareOpt
 	}
 	colorThreshold = colorThreshold / 255.0
 
-	// Build comparison options with diff image output
-	var diffImgPtr image.Image
 	matchOpts := []pixelmatch.MatchOption{
 		pixelmatch.Threshold(colorThreshold),
 		pixelmatch.Alpha(0.1),
 		pixelmatch.DiffColor(color.RGBA{R: 255, G: 0, B: 0, A: 255}),
-		pixelmatch.WriteTo(&diffImgPtr),
-		pixelmatch.EnableDiffMask,
 	}
 
 	// Include antialiasing detection unless explicitly disabled
@@ -114,34 +147,63 @@ func (p *Processor) Compare(baseline, current image.Image, opts ports.CompareOpt
 		matchOpts = append(matchOpts, pixelmatch.IncludeAntiAlias)
 	}
 
-	// Perform comparison
-	diffCount, err := pixelmatch.MatchPixel(maskedBaseline, maskedCurrent, matchOpts...)
-	if err != nil {
-		return nil, fmt.Errorf("pixel comparison: %w", err)
+	// Perform comparison, keeping pixelmatch's diff image only when it is
+	// the output. Identical pixels need no comparison, whether the caller
+	// found them so or the ignored regions hid the differences.
+	identical := opts.Identical || bytes.Equal(normBaseline.Pix, normCurrent.Pix)
+	var diffCount int
+	var diffImgPtr *image.RGBA
+	var regions []image.Rectangle
+	if !identical {
+		var err error
+		wantDiff := opts.DiffLayout == ports.DiffLayoutRawPixelmatch
+		diffCount, diffImgPtr, err = matchPixels(normBaseline, normCurrent, opts.IgnoreAntialiasing, wantDiff, matchOpts)
+		if err != nil {
+			return nil, fmt.Errorf("pixel comparison: %w", err)
+		}
+		regions = diffRegions(normBaseline, normCurrent, colorThreshold)
+	}
+
+	// Generate diff image based on layout
+	ps := panels{baseline: normBaseline, current: normCurrent}
+	switch opts.DiffLayout {
+	case ports.DiffLayoutPair:
+	case ports.DiffLayoutRawPixelmatch:
+		ps.mask = diffImgPtr
+		if ps.mask == nil {
+			// Fallback: create standard diff image
+			ps.mask = p.createDiffImage(normBaseline, normCurrent, colorThreshold)
+		}
+	default:
+		ps.diff = p.createOverlayDiffImage(normBaseline, normCurrent, colorThreshold)
 	}
 
-	// Generate diff image based on mode
+	labels := []string{opts.BaselineLabel, opts.DiffLabel, opts.CurrentLabel}
+	// Apply defaults if empty
+	if labels[0] == "" {
+		labels[0] = "baseline"
+	}
+	if labels[1] == "" {
+		labels[1] = "diff"
+	}
+	if labels[2] == "" {
+		labels[2] = "current"
+	}
+	// The diff panel and pixelmatch's mask alone have no labels
+	var face font.Face
+	if opts.CropToChanges || (opts.DiffLayout != ports.DiffLayoutDiffOnly && opts.DiffLayout != ports.DiffLayoutRawPixelmatch) {
+		face = p.loadFont(opts.LabelFontPath, opts.LabelFontSize)
+	}
+	labelHeight := labelBarHeight(opts.LabelFontSize)
+
+	render := func(r image.Rectangle) *image.RGBA {
+		return p.renderLayout(opts.DiffLayout, ps.crop(r), labels, face, labelHeight)
+	}
 	var diffImg image.Image
-	if opts.DiffOverlay {
-		// Create side-by-side composite: before | diff | after
-		diffPanel := p.createOverlayDiffImage(maskedBaseline, maskedCurrent, colorThreshold)
-		labels := []string{opts.BaselineLabel, opts.DiffLabel, opts.CurrentLabel}
-		// Apply defaults if empty
-		if labels[0] == "" {
-			labels[0] = "baseline"
-		}
-		if labels[1] == "" {
-			labels[1] = "diff"
-		}
-		if labels[2] == "" {
-			labels[2] = "current"
-		}
-		diffImg = p.createCompositeImage(maskedBaseline, maskedCurrent, diffPanel, opts.LabelFontPath, opts.LabelFontSize, labels)
-	} else if diffImgPtr != nil {
-		diffImg = diffImgPtr
+	if opts.CropToChanges && len(regions) > 0 {
+		diffImg = p.createGallery(cropRegions(regions, normBaseline.Bounds()), render, face, labelHeight)
 	} else {
-		// Fallback: create standard diff image
-		diffImg = p.createDiffImage(maskedBaseline, maskedCurrent, colorThreshold)
+		diffImg = render(normBaseline.Bounds())
 	}
 
 	diffRatio := float64(diffCount) / float64(totalPixels)
@@ -151,113 +213,97 @@ func (p *Processor) Compare(baseline, current image.Image, opts ports.CompareOpt
 		PixelDiffRatio: diffRatio,
 		TotalPixels:    totalPixels,
 		DiffImage:      diffImg,
+		DiffRegions:    regions,
 	}, nil
 }
 
 // createOverlayDiffImage creates the center diff panel.
 // Shows before image faded (c' = c * 0.5 + 0.5) with red overlay on difference pixels.
-func (p *Processor) createOverlayDiffImage(baseline, current image.Image, threshold float64) image.Image {
+func (p *Processor) createOverlayDiffImage(baseline, current *image.RGBA, threshold float64) *image.RGBA {
 	bounds := baseline.Bounds()
 	overlayImg := image.NewRGBA(bounds)
-
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			br, bg, bb, _ := baseline.At(x, y).RGBA()
-			cr, cg, cb, _ := current.At(x, y).RGBA()
-
-			// Calculate color difference (normalized to 0-1)
-			dr := float64(int32(br)-int32(cr)) / 65535.0
-			dg := float64(int32(bg)-int32(cg)) / 65535.0
-			db := float64(int32(bb)-int32(cb)) / 65535.0
-
-			diff := (abs(dr) + abs(dg) + abs(db)) / 3.0
-
-			// Base: before image faded (c' = c * 0.5 + 0.5)
-			// In 8-bit: c' = c / 2 + 128
-			baseR := uint8(br>>9) + 128
-			baseG := uint8(bg>>9) + 128
-			baseB := uint8(bb>>9) + 128
-
-			if diff > threshold {
-				// Overlay red on difference pixels
-				overlayImg.Set(x, y, color.RGBA{
-					R: 255,
-					G: baseG / 2,
-					B: baseB / 2,
-					A: 255,
-				})
-			} else {
-				// Faded before image
-				overlayImg.Set(x, y, color.RGBA{
-					R: baseR,
-					G: baseG,
-					B: baseB,
-					A: 255,
-				})
+	limit := diffLimit(threshold, 3)
+
+	parallelRows(bounds.Dy(), 1, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			b, c, o := rowPix(baseline, y), rowPix(current, y), rowPix(overlayImg, y)
+			for i := 0; i < len(o); i += 4 {
+				// Base: before image faded (c' = c * 0.5 + 0.5)
+				// In 8-bit: c' = c / 2 + 128
+				baseR := b[i]>>1 + 128
+				baseG := b[i+1]>>1 + 128
+				baseB := b[i+2]>>1 + 128
+
+				if channelDiff(b[i:i+3], c[i:i+3], 3) > limit {
+					// Overlay red on difference pixels
+					o[i], o[i+1], o[i+2] = 255, baseG/2, baseB/2
+				} else {
+					// Faded before image
+					o[i], o[i+1], o[i+2] = baseR, baseG, baseB
+				}
+				o[i+3] = 255
 			}
 		}
-	}
+	})
 
 	return overlayImg
 }
 
-// createCompositeImage creates a side-by-side image: before | diff | after
-func (p *Processor) createCompositeImage(baseline, current, diffPanel image.Image, fontPath string, fontSize float64, labels []string) image.Image {
-	bounds := baseline.Bounds()
+// createCompositeImage lays the panels out side by side, each under its
+// label, or stacked top to bottom when vertical.
+func (p *Processor) createCompositeImage(panels []*image.RGBA, labels []string, vertical bool, face font.Face, labelHeight int) *image.RGBA {
+	bounds := panels[0].Bounds()
 	width := bounds.Dx()
 	height := bounds.Dy()
 
-	// Label bar height
-	labelHeight := 24
-	if fontSize > 0 && fontSize > 14 {
-		labelHeight = int(fontSize) + 10
-	}
-
-	// Create composite image with 3 panels side by side + label bar
-	composite := image.NewRGBA(image.Rect(0, 0, width*3, height+labelHeight))
-
-	// Fill label bar with light gray
-	labelBg := color.RGBA{R: 240, G: 240, B: 240, A: 255}
-	for y := 0; y < labelHeight; y++ {
-		for x := 0; x < width*3; x++ {
-			composite.Set(x, y, labelBg)
+	// Top-left corner of the label bar of each panel
+	corners := make([]image.Point, len(panels))
+	size := image.Pt(width*len(panels), height+labelHeight)
+	if vertical {
+		size = image.Pt(width, (height+labelHeight)*len(panels))
+	}
+	for i := range panels {
+		if vertical {
+			corners[i] = image.Pt(0, i*(height+labelHeight))
+		} else {
+			corners[i] = image.Pt(i*width, 0)
 		}
 	}
+	composite := image.NewRGBA(image.Rectangle{Max: size})
 
-	// Draw labels
-	face := p.loadFont(fontPath, fontSize)
-	for i, label := range labels {
-		p.drawCenteredText(composite, label, i*width, 0, width, labelHeight, face)
+	for i, corner := range corners {
+		p.drawLabelBar(composite, image.Rect(corner.X, corner.Y, corner.X+width, corner.Y+labelHeight), labels[i], face)
 	}
 
-	// Draw 1px border line at bottom of label area
-	borderColor := color.RGBA{R: 200, G: 200, B: 200, A: 255}
-	for x := 0; x < width*3; x++ {
-		composite.Set(x, labelHeight-1, borderColor)
-	}
-
-	// Panel 1: Before (left)
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			composite.Set(x, y+labelHeight, baseline.At(x+bounds.Min.X, y+bounds.Min.Y))
+	parallelRows(height, 1, func(y0, y1 int) {
+		for i, panel := range panels {
+			top := corners[i].Y + labelHeight
+			r := image.Rect(corners[i].X, top+y0, corners[i].X+width, top+y1)
+			draw.Draw(composite, r, panel, panel.Bounds().Min.Add(image.Pt(0, y0)), draw.Src)
 		}
-	}
+	})
 
-	// Panel 2: Diff (center)
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			composite.Set(x+width, y+labelHeight, diffPanel.At(x+bounds.Min.X, y+bounds.Min.Y))
-		}
-	}
+	return composite
+}
 
-	// Panel 3: After (right)
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			composite.Set(x+width*2, y+labelHeight, current.At(x+bounds.Min.X, y+bounds.Min.Y))
-		}
+// labelBarHeight returns the height of the label bars for a font size.
+func labelBarHeight(fontSize float64) int {
+	if fontSize > 14 {
+		return int(fontSize) + 10
 	}
+	return 24
+}
 
-	return composite
+// drawLabelBar fills r light gray with a 1px border line at the bottom, and
+// centers text in it.
+func (p *Processor) drawLabelBar(img *image.RGBA, r image.Rectangle, text string, face font.Face) {
+	labelBg := color.RGBA{R: 240, G: 240, B: 240, A: 255}
+	draw.Draw(img, r, image.NewUniform(labelBg), image.Point{}, draw.Src)
+
+	p.drawCenteredText(img, text, r.Min.X, r.Min.Y, r.Dx(), r.Dy(), face)
+
+	borderColor := color.RGBA{R: 200, G: 200, B: 200, A: 255}
+	draw.Draw(img, image.Rect(r.Min.X, r.Max.Y-1, r.Max.X, r.Max.Y), image.NewUniform(borderColor), image.Point{}, draw.Src)
 }
 
 // loadFont loads a TrueType font with the following priority:
@@ -348,94 +394,72 @@ func (p *Processor) drawCenteredText(img *image.RGBA, text string, x, y, width,
 }
 
 // createDiffImage creates a diff image by comparing two images pixel by pixel.
-func (p *Processor) createDiffImage(baseline, current image.Image, threshold float64) image.Image {
+func (p *Processor) createDiffImage(baseline, current *image.RGBA, threshold float64) *image.RGBA {
 	bounds := baseline.Bounds()
 	diffImg := image.NewRGBA(bounds)
+	limit := diffLimit(threshold, 4)
 
 	// Copy baseline as gray and mark differences in red
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			br, bg, bb, ba := baseline.At(x, y).RGBA()
-			cr, cg, cb, ca := current.At(x, y).RGBA()
-
-			// Calculate color difference (normalized to 0-1)
-			dr := float64(int32(br)-int32(cr)) / 65535.0
-			dg := float64(int32(bg)-int32(cg)) / 65535.0
-			db := float64(int32(bb)-int32(cb)) / 65535.0
-			da := float64(int32(ba)-int32(ca)) / 65535.0
-
-			diff := (abs(dr) + abs(dg) + abs(db) + abs(da)) / 4.0
-
-			if diff > threshold {
-				// Mark as red for differences
-				diffImg.Set(x, y, color.RGBA{R: 255, G: 0, B: 0, A: 255})
-			} else {
-				// Gray for unchanged pixels
-				gray := uint8((br + bg + bb) / 3 / 257)
-				diffImg.Set(x, y, color.RGBA{R: gray, G: gray, B: gray, A: 255})
+	parallelRows(bounds.Dy(), 1, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			b, c, d := rowPix(baseline, y), rowPix(current, y), rowPix(diffImg, y)
+			for i := 0; i < len(d); i += 4 {
+				if channelDiff(b[i:i+4], c[i:i+4], 4) > limit {
+					// Mark as red for differences
+					d[i], d[i+1], d[i+2] = 255, 0, 0
+				} else {
+					// Gray for unchanged pixels
+					gray := uint8((int(b[i]) + int(b[i+1]) + int(b[i+2])) / 3)
+					d[i], d[i+1], d[i+2] = gray, gray, gray
+				}
+				d[i+3] = 255
 			}
 		}
-	}
+	})
 
 	return diffImg
 }
 
-func abs(x float64) float64 {
-	if x < 0 {
-		return -x
-	}
-	return x
-}
-
 // normalizeImage creates a new image of the specified size, copying the original
 // and filling any extra space with a distinct color (magenta) to highlight size differences.
-func (p *Processor) normalizeImage(img image.Image, width, height int) image.Image {
+func (p *Processor) normalizeImage(img image.Image, width, height int) *image.RGBA {
 	bounds := img.Bounds()
 	normalized := image.NewRGBA(image.Rect(0, 0, width, height))
 
 	// Fill background with magenta (to highlight areas that don't exist in one image)
-	fillColor := color.RGBA{R: 255, G: 0, B: 255, A: 255}
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			normalized.Set(x, y, fillColor)
-		}
-	}
+	magenta := fillRow(width, [4]uint8{255, 0, 255, 255})
 
-	// Copy original image content
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			normalized.Set(x-bounds.Min.X, y-bounds.Min.Y, img.At(x, y))
+	parallelRows(height, 1, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			copy(rowPix(normalized, y), magenta)
 		}
-	}
+
+		// Copy original image content
+		r := image.Rect(0, y0, width, y1)
+		draw.Draw(normalized, r, img, bounds.Min.Add(image.Pt(0, y0)), draw.Src)
+	})
 
 	return normalized
 }
 
-// applyMask creates a copy of the image with ignored regions filled with black.
-func (p *Processor) applyMask(img image.Image, regions []ports.IgnoreRegion) image.Image {
+// applyMask fills the ignored regions of img with black, in place.
+func (p *Processor) applyMask(img *image.RGBA, regions []ports.IgnoreRegion) {
 	bounds := img.Bounds()
-	masked := image.NewRGBA(bounds)
+	black := fillRow(bounds.Dx(), [4]uint8{0, 0, 0, 255})
 
-	// Copy original image
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			masked.Set(x, y, img.At(x, y))
-		}
-	}
-
-	// Fill ignored regions with black
-	black := color.RGBA{R: 0, G: 0, B: 0, A: 255}
 	for _, region := range regions {
-		for y := region.Y; y < region.Y+region.Height && y < bounds.Max.Y; y++ {
-			for x := region.X; x < region.X+region.Width && x < bounds.Max.X; x++ {
-				if x >= bounds.Min.X && y >= bounds.Min.Y {
-					masked.Set(x, y, black)
-				}
-			}
+		if region.Width <= 0 || region.Height <= 0 {
+			continue
+		}
+		r := image.Rect(region.X, region.Y, region.X+region.Width, region.Y+region.Height).Intersect(bounds)
+		if r.Empty() {
+			continue
+		}
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			i := img.PixOffset(r.Min.X, y)
+			copy(img.Pix[i:i+r.Dx()*4], black)
 		}
 	}
-
-	return masked
 }
 
 // Ensure Processor implements ports.ImageProcessor
@@ -0,0 +1,132 @@
+package pixelmatch
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
+// Benchmark images are the size of a full-page desktop capture.
+const (
+	benchWidth  = 1920
+	benchHeight = 8000
+)
+
+// benchImages returns a baseline with a gradient and a current image that
+// differs from it in a band of blocks, as *image.NRGBA like decoded PNGs.
+func benchImages() (baseline, current *image.NRGBA) {
+	baseline = image.NewNRGBA(image.Rect(0, 0, benchWidth, benchHeight))
+	current = image.NewNRGBA(image.Rect(0, 0, benchWidth, benchHeight))
+	for y := 0; y < benchHeight; y++ {
+		for x := 0; x < benchWidth; x++ {
+			c := color.NRGBA{uint8(x), uint8(y), uint8(x + y), 255}
+			baseline.SetNRGBA(x, y, c)
+			if y%400 < 40 && x%300 < 100 {
+				c.R = 255 - c.R
+			}
+			current.SetNRGBA(x, y, c)
+		}
+	}
+	return baseline, current
+}
+
+func benchmarkCompare(b *testing.B, opts ports.CompareOptions) {
+	baseline, current := benchImages()
+	p := New()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Compare(baseline, current, opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCompare_Horizontal(b *testing.B) {
+	benchmarkCompare(b, ports.CompareOptions{})
+}
+
+func BenchmarkCompare_RawPixelmatch(b *testing.B) {
+	benchmarkCompare(b, ports.CompareOptions{DiffLayout: ports.DiffLayoutRawPixelmatch})
+}
+
+func BenchmarkCompare_CropToChanges(b *testing.B) {
+	benchmarkCompare(b, ports.CompareOptions{CropToChanges: true})
+}
+
+func BenchmarkCompare_IgnoreAntialiasing(b *testing.B) {
+	benchmarkCompare(b, ports.CompareOptions{IgnoreAntialiasing: true})
+}
+
+func BenchmarkCompare_IgnoreRegions(b *testing.B) {
+	benchmarkCompare(b, ports.CompareOptions{
+		IgnoreRegions: []ports.IgnoreRegion{
+			{X: 0, Y: 0, Width: benchWidth, Height: 120},
+			{X: 100, Y: 2000, Width: 600, Height: 3000},
+		},
+	})
+}
+
+func BenchmarkCompare_Identical(b *testing.B) {
+	baseline, _ := benchImages()
+	p := New()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Compare(baseline, baseline, ports.CompareOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNormalizeImage(b *testing.B) {
+	baseline, _ := benchImages()
+	p := New()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.normalizeImage(baseline, benchWidth, benchHeight+200)
+	}
+}
+
+func BenchmarkCreateOverlayDiffImage(b *testing.B) {
+	p := New()
+	baseline, current := benchImages()
+	nb := p.normalizeImage(baseline, benchWidth, benchHeight)
+	nc := p.normalizeImage(current, benchWidth, benchHeight)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.createOverlayDiffImage(nb, nc, 10.0/255.0)
+	}
+}
+
+func BenchmarkCreateCompositeImage(b *testing.B) {
+	p := New()
+	baseline, current := benchImages()
+	nb := p.normalizeImage(baseline, benchWidth, benchHeight)
+	nc := p.normalizeImage(current, benchWidth, benchHeight)
+	panel := p.createOverlayDiffImage(nb, nc, 10.0/255.0)
+	panels := []*image.RGBA{nb, panel, nc}
+	labels := []string{"baseline", "diff", "current"}
+	face := p.loadFont("", 0)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.createCompositeImage(panels, labels, false, face, labelBarHeight(0))
+	}
+}
+
+func BenchmarkDiffRegions(b *testing.B) {
+	p := New()
+	baseline, current := benchImages()
+	nb := p.normalizeImage(baseline, benchWidth, benchHeight)
+	nc := p.normalizeImage(current, benchWidth, benchHeight)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		diffRegions(nb, nc, 10.0/255.0)
+	}
+}
@@ -3,6 +3,7 @@ package pixelmatch
 import (
 	"image"
 	"image/color"
+	"path/filepath"
 	"testing"
 
 	"github.com/ideamans/static-webshot/pkg/ports"
@@ -18,6 +19,29 @@ func createTestImage(width, height int, c color.Color) image.Image {
 	return img
 }
 
+func TestProcessor_SaveLoadImage_Formats(t *testing.T) {
+	p := New()
+	img := createTestImage(10, 10, color.RGBA{255, 0, 0, 255})
+
+	for _, name := range []string{"shot.png", "shot.webp", "shot.jpg"} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := p.SaveImage(path, img, ports.EncodeOptions{}); err != nil {
+			t.Fatalf("SaveImage(%s) error = %v", name, err)
+		}
+		loaded, err := p.LoadImage(path)
+		if err != nil {
+			t.Fatalf("LoadImage(%s) error = %v", name, err)
+		}
+		if loaded.Bounds() != img.Bounds() {
+			t.Errorf("LoadImage(%s) bounds = %v, want %v", name, loaded.Bounds(), img.Bounds())
+		}
+	}
+
+	if err := p.SaveImage(filepath.Join(t.TempDir(), "shot.png"), img, ports.EncodeOptions{Format: "gif"}); err == nil {
+		t.Error("SaveImage() with an unknown format succeeded, want an error")
+	}
+}
+
 func TestProcessor_Compare_IdenticalImages(t *testing.T) {
 	processor := New()
 
@@ -77,6 +101,48 @@ func TestProcessor_Compare_DimensionMismatch(t *testing.T) {
 	}
 }
 
+func TestProcessor_Compare_SizeMismatch(t *testing.T) {
+	processor := New()
+
+	// The current page grew by 20px of the same color
+	baseline := createTestImage(100, 100, color.RGBA{R: 255, G: 0, B: 0, A: 255})
+	current := createTestImage(100, 120, color.RGBA{R: 255, G: 0, B: 0, A: 255})
+
+	tests := []struct {
+		strategy  string
+		wantDiff  int
+		wantTotal int
+		wantErr   bool
+	}{
+		{"", 100 * 20, 100 * 120, false},
+		{ports.SizeMismatchPad, 100 * 20, 100 * 120, false},
+		{ports.SizeMismatchCrop, 0, 100 * 100, false},
+		{ports.SizeMismatchScale, 0, 100 * 100, false},
+		{ports.SizeMismatchFail, 0, 0, true},
+		{"stretch", 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			result, err := processor.Compare(baseline, current, ports.CompareOptions{SizeMismatch: tt.strategy})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Compare() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if result.PixelDiffCount != tt.wantDiff || result.TotalPixels != tt.wantTotal {
+				t.Errorf("Compare() PixelDiffCount, TotalPixels = %d, %d, want %d, %d",
+					result.PixelDiffCount, result.TotalPixels, tt.wantDiff, tt.wantTotal)
+			}
+		})
+	}
+
+	// Images of the same size compare the same whatever the strategy
+	if _, err := processor.Compare(baseline, baseline, ports.CompareOptions{SizeMismatch: ports.SizeMismatchFail}); err != nil {
+		t.Errorf("Compare() same size with fail error = %v", err)
+	}
+}
+
 func TestProcessor_Compare_WithIgnoreRegions(t *testing.T) {
 	processor := New()
 
@@ -115,3 +181,142 @@ func TestProcessor_Compare_WithIgnoreRegions(t *testing.T) {
 			diffWithoutIgnore, result2.PixelDiffCount)
 	}
 }
+
+func TestDiffRegions(t *testing.T) {
+	baseline := createTestImage(200, 100, color.White)
+	current := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 200; x++ {
+			current.Set(x, y, color.White)
+		}
+	}
+	// Two changes close enough to merge, and one far away
+	for y := 10; y < 20; y++ {
+		for x := 10; x < 20; x++ {
+			current.Set(x, y, color.Black)
+		}
+	}
+	current.Set(25, 25, color.Black)
+	for y := 70; y < 80; y++ {
+		for x := 150; x < 180; x++ {
+			current.Set(x, y, color.Black)
+		}
+	}
+
+	got := diffRegions(baseline.(*image.RGBA), current, 10.0/255.0)
+	want := []image.Rectangle{image.Rect(10, 10, 26, 26), image.Rect(150, 70, 180, 80)}
+	if len(got) != len(want) {
+		t.Fatalf("diffRegions() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("diffRegions()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestProcessor_Compare_RawPixelmatch(t *testing.T) {
+	processor := New()
+
+	baseline := createTestImage(300, 300, color.White)
+	current := createTestImage(300, 300, color.White)
+	current.(*image.RGBA).Set(5, 5, color.Black)
+	current.(*image.RGBA).Set(7, 200, color.Black)
+
+	result, err := processor.Compare(baseline, current, ports.CompareOptions{DiffLayout: ports.DiffLayoutRawPixelmatch})
+	if err != nil {
+		t.Fatalf("Compare() error = %v", err)
+	}
+	if result.PixelDiffCount != 2 {
+		t.Fatalf("Compare() PixelDiffCount = %d, want 2", result.PixelDiffCount)
+	}
+
+	red := color.RGBA{R: 255, A: 255}
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	diff := result.DiffImage.(*image.RGBA)
+	for _, tt := range []struct {
+		x, y int
+		want color.RGBA
+	}{
+		{5, 5, red},
+		{7, 200, red},
+		{5, 6, white},
+		{5, 299, white},
+		{7, 201, white},
+	} {
+		if got := diff.RGBAAt(tt.x, tt.y); got != tt.want {
+			t.Errorf("DiffImage at (%d,%d) = %v, want %v", tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestProcessor_Compare_Layouts(t *testing.T) {
+	processor := New()
+
+	baseline := createTestImage(100, 50, color.White)
+	current := createTestImage(100, 50, color.White)
+	for y := 20; y < 30; y++ {
+		for x := 20; x < 30; x++ {
+			current.(*image.RGBA).Set(x, y, color.Black)
+		}
+	}
+
+	// Label bars are 24px high
+	tests := []struct {
+		layout        string
+		cropToChanges bool
+		want          image.Point
+	}{
+		{"", false, image.Pt(300, 74)},
+		{ports.DiffLayoutHorizontal, false, image.Pt(300, 74)},
+		{ports.DiffLayoutVertical, false, image.Pt(100, 3*74)},
+		{ports.DiffLayoutDiffOnly, false, image.Pt(100, 50)},
+		{ports.DiffLayoutPair, false, image.Pt(200, 74)},
+		{ports.DiffLayoutRawPixelmatch, false, image.Pt(100, 50)},
+		// The square with 32px of context, clipped, under a caption
+		{ports.DiffLayoutHorizontal, true, image.Pt(3*62, 24+74)},
+		{ports.DiffLayoutDiffOnly, true, image.Pt(62, 24+50)},
+	}
+	for _, tt := range tests {
+		result, err := processor.Compare(baseline, current, ports.CompareOptions{DiffLayout: tt.layout, CropToChanges: tt.cropToChanges})
+		if err != nil {
+			t.Fatalf("Compare(%q, crop %v) error = %v", tt.layout, tt.cropToChanges, err)
+		}
+		if got := result.DiffImage.Bounds().Size(); got != tt.want {
+			t.Errorf("Compare(%q, crop %v) DiffImage size = %v, want %v", tt.layout, tt.cropToChanges, got, tt.want)
+		}
+	}
+
+	if _, err := processor.Compare(baseline, current, ports.CompareOptions{DiffLayout: "grid"}); err == nil {
+		t.Error("Compare() with an unknown layout succeeded")
+	}
+
+	// Without changes there is nothing to crop to
+	result, err := processor.Compare(baseline, baseline, ports.CompareOptions{CropToChanges: true})
+	if err != nil {
+		t.Fatalf("Compare() error = %v", err)
+	}
+	if got := result.DiffImage.Bounds().Size(); got != image.Pt(300, 74) {
+		t.Errorf("Compare() identical with crop DiffImage size = %v, want the full layout", got)
+	}
+}
+
+func TestCropRegions(t *testing.T) {
+	bounds := image.Rect(0, 0, 1000, 1000)
+	regions := []image.Rectangle{
+		image.Rect(500, 500, 510, 510),
+		image.Rect(10, 10, 20, 20),
+		image.Rect(540, 540, 560, 560), // Within reach of the first once padded
+	}
+
+	got := cropRegions(regions, bounds)
+	want := []image.Rectangle{image.Rect(0, 0, 52, 52), image.Rect(468, 468, 592, 592)}
+	if len(got) != len(want) {
+		t.Fatalf("cropRegions() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("cropRegions()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
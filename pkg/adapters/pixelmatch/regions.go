@@ -0,0 +1,79 @@
+package pixelmatch
+
+import (
+	"image"
+	"sort"
+)
+
+// regionCellSize is the size in pixels of the grid cells differing pixels
+// are clustered on. Differences closer than a cell end up in one region.
+const regionCellSize = 16
+
+// diffRegions returns the bounding boxes of clusters of pixels that differ
+// between two images of the same size, using the same per-pixel test as the
+// overlay diff panel.
+func diffRegions(baseline, current *image.RGBA, threshold float64) []image.Rectangle {
+	bounds := baseline.Bounds()
+	cols := (bounds.Dx() + regionCellSize - 1) / regionCellSize
+	rows := (bounds.Dy() + regionCellSize - 1) / regionCellSize
+	limit := diffLimit(threshold, 3)
+
+	// Bounding box of the differing pixels of each cell. Bands are whole
+	// rows of cells, so no two goroutines touch the same cell.
+	cells := make([]image.Rectangle, cols*rows)
+	parallelRows(bounds.Dy(), regionCellSize, func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			b, c := rowPix(baseline, y), rowPix(current, y)
+			for i := 0; i < len(b); i += 4 {
+				if channelDiff(b[i:i+3], c[i:i+3], 3) <= limit {
+					continue
+				}
+				x := i / 4
+				px := image.Rect(bounds.Min.X+x, bounds.Min.Y+y, bounds.Min.X+x+1, bounds.Min.Y+y+1)
+				j := (y/regionCellSize)*cols + x/regionCellSize
+				cells[j] = cells[j].Union(px)
+			}
+		}
+	})
+
+	// Merge neighbouring cells, diagonals included
+	visited := make([]bool, len(cells))
+	var regions []image.Rectangle
+	for start := range cells {
+		if visited[start] || cells[start].Empty() {
+			continue
+		}
+		visited[start] = true
+		region := cells[start]
+		stack := []int{start}
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			col, row := i%cols, i/cols
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					c, r := col+dx, row+dy
+					if c < 0 || c >= cols || r < 0 || r >= rows {
+						continue
+					}
+					j := r*cols + c
+					if visited[j] || cells[j].Empty() {
+						continue
+					}
+					visited[j] = true
+					region = region.Union(cells[j])
+					stack = append(stack, j)
+				}
+			}
+		}
+		regions = append(regions, region)
+	}
+
+	sort.Slice(regions, func(i, j int) bool {
+		if regions[i].Min.Y != regions[j].Min.Y {
+			return regions[i].Min.Y < regions[j].Min.Y
+		}
+		return regions[i].Min.X < regions[j].Min.X
+	})
+	return regions
+}
@@ -0,0 +1,74 @@
+// Package staticserver provides an embedded HTTP server for static files.
+package staticserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
+// Server implements ports.StaticServer using net/http on the loopback interface.
+type Server struct {
+	server *http.Server
+	addr   string
+}
+
+// New creates a new Server.
+func New() *Server {
+	return &Server{}
+}
+
+// Serve starts serving dir and returns the base URL. The port chosen by the
+// first call is reused by later calls when it is still free, so pages served
+// one after another see the same origin.
+func (s *Server) Serve(dir string) (string, error) {
+	if err := s.Close(); err != nil {
+		return "", err
+	}
+
+	addr := s.addr
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil && s.addr != "" {
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	if err != nil {
+		return "", fmt.Errorf("listen: %w", err)
+	}
+	s.addr = listener.Addr().String()
+
+	s.server = &http.Server{
+		Handler:           http.FileServer(http.Dir(dir)),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go s.server.Serve(listener)
+
+	return "http://" + s.addr, nil
+}
+
+// Close stops serving. It is a no-op when nothing is being served.
+func (s *Server) Close() error {
+	if s.server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := s.server.Shutdown(ctx)
+	s.server = nil
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("shut down static server: %w", err)
+	}
+	return nil
+}
+
+// Ensure Server implements ports.StaticServer
+var _ ports.StaticServer = (*Server)(nil)
@@ -0,0 +1,57 @@
+package staticserver
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServer_Serve(t *testing.T) {
+	first := t.TempDir()
+	second := t.TempDir()
+	if err := os.WriteFile(filepath.Join(first, "index.html"), []byte("first"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(second, "index.html"), []byte("second"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := New()
+	defer server.Close()
+
+	firstURL, err := server.Serve(first)
+	if err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+	if got := get(t, firstURL+"/"); got != "first" {
+		t.Errorf("GET / = %q, want %q", got, "first")
+	}
+
+	// Serving another directory replaces the first and keeps the origin
+	secondURL, err := server.Serve(second)
+	if err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+	if secondURL != firstURL {
+		t.Errorf("Serve() URL = %s, want the same origin %s", secondURL, firstURL)
+	}
+	if got := get(t, secondURL+"/"); got != "second" {
+		t.Errorf("GET / = %q, want %q", got, "second")
+	}
+}
+
+func get(t *testing.T, url string) string {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read %s: %v", url, err)
+	}
+	return string(body)
+}
@@ -1,6 +1,11 @@
 // Package compare provides the compare command logic.
 package compare
 
+import (
+	"github.com/ideamans/static-webshot/pkg/layout"
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
 // Config holds configuration for the compare command.
 type Config struct {
 	// BaselinePath is the path to the baseline image.
@@ -12,17 +17,57 @@ type Config struct {
 	// OutputPath is the path where the diff image will be saved.
 	OutputPath string
 
+	// Encoding is how the diff image is written; its format defaults to the
+	// one of the OutputPath extension.
+	Encoding ports.EncodeOptions
+
 	// ColorThreshold is the per-pixel color difference threshold (0-255).
 	ColorThreshold int
 
 	// IgnoreAntialiasing ignores antialiased pixels when comparing.
 	IgnoreAntialiasing bool
 
+	// IgnoreRegions are areas in image pixels to exclude from comparison.
+	IgnoreRegions []ports.IgnoreRegion
+
+	// CurrentIgnoreRegions are areas in the pixels of the current image to
+	// exclude from comparison, such as its masked areas. With
+	// SizeMismatchScale they are scaled along with the current image.
+	CurrentIgnoreRegions []ports.IgnoreRegion
+
+	// IgnoreMasked excludes the masked areas recorded in the images'
+	// metadata sidecars (<image>.meta.json) from comparison.
+	IgnoreMasked bool
+
+	// BaselineLayout and CurrentLayout are the layout snapshots of the
+	// images (optional). With both, differing regions are attributed to the
+	// elements that changed.
+	BaselineLayout *layout.Snapshot
+	CurrentLayout  *layout.Snapshot
+
+	// LayoutTolerance is how far elements may move or resize, in CSS pixels,
+	// before they count as changed.
+	LayoutTolerance layout.Tolerance
+
 	// MaxHeight limits comparison to the top N pixels (0 = no limit).
 	MaxHeight int
 
-	// DiffOverlay overlays diff markers on the current image.
-	DiffOverlay bool
+	// SizeMismatch is how images of different sizes are compared: pad,
+	// crop, fail or scale (ports.SizeMismatchPad and so on).
+	SizeMismatch string
+
+	// DiffLayout is how the diff image is laid out: horizontal, vertical,
+	// diff-only, pair or raw-pixelmatch (ports.DiffLayoutHorizontal and so
+	// on).
+	DiffLayout string
+
+	// CropToChanges renders only the regions of differing pixels, with
+	// some context around them, stacked as a gallery.
+	CropToChanges bool
+
+	// SkipDiffOnEqual skips the diff image when the images have the same
+	// pixels. The digests are still written.
+	SkipDiffOnEqual bool
 
 	// DigestTxtPath is the path for text digest output (optional).
 	DigestTxtPath string
@@ -49,11 +94,14 @@ type Config struct {
 // DefaultConfig returns a Config with default values.
 func DefaultConfig() Config {
 	return Config{
-		OutputPath:     "./diff.png",
-		ColorThreshold: 10,
-		DiffOverlay:    true, // Default to overlay mode
-		BaselineLabel:  "baseline",
-		DiffLabel:      "diff",
-		CurrentLabel:   "current",
+		OutputPath:      "./diff.png",
+		ColorThreshold:  10,
+		DiffLayout:      ports.DiffLayoutHorizontal,
+		IgnoreMasked:    true,
+		SizeMismatch:    ports.SizeMismatchPad,
+		LayoutTolerance: layout.DefaultTolerance,
+		BaselineLabel:   "baseline",
+		DiffLabel:       "diff",
+		CurrentLabel:    "current",
 	}
 }
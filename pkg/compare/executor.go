@@ -2,11 +2,19 @@
 package compare
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"image"
+	"math"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
+	"github.com/ideamans/static-webshot/pkg/imagehash"
+	"github.com/ideamans/static-webshot/pkg/layout"
+	"github.com/ideamans/static-webshot/pkg/metadata"
 	"github.com/ideamans/static-webshot/pkg/ports"
 )
 
@@ -28,22 +36,134 @@ func NewExecutor(processor ports.ImageProcessor, filesystem ports.FileSystem, lo
 
 // Execute runs the compare command with the given configuration.
 func (e *Executor) Execute(ctx context.Context, cfg Config) (*Result, error) {
-	baseline, err := e.processor.LoadImage(cfg.BaselinePath)
+	baselineData, err := e.filesystem.ReadFile(cfg.BaselinePath)
 	if err != nil {
 		return nil, fmt.Errorf("load baseline: %w", err)
 	}
-
-	current, err := e.processor.LoadImage(cfg.CurrentPath)
+	currentData, err := e.filesystem.ReadFile(cfg.CurrentPath)
 	if err != nil {
 		return nil, fmt.Errorf("load current: %w", err)
 	}
 
+	baseline, err := e.processor.DecodeImage(baselineData)
+	if err != nil {
+		return nil, fmt.Errorf("load baseline %s: %w", cfg.BaselinePath, err)
+	}
+
+	// Byte-identical files need decoding only once
+	current := baseline
+	if bytes.Equal(baselineData, currentData) {
+		e.logger.Debug("%s and %s are byte-identical", cfg.BaselinePath, cfg.CurrentPath)
+	} else if current, err = e.processor.DecodeImage(currentData); err != nil {
+		return nil, fmt.Errorf("load current %s: %w", cfg.CurrentPath, err)
+	}
+
+	// Capture metadata explains diffs that come from the capture settings
+	// rather than the pages, and carries the masked areas
+	baselineMeta, err := e.loadMetadata(cfg.BaselinePath)
+	if err != nil {
+		return nil, err
+	}
+	currentMeta, err := e.loadMetadata(cfg.CurrentPath)
+	if err != nil {
+		return nil, err
+	}
+	if baselineMeta != nil && currentMeta != nil {
+		for _, problem := range metadata.Incompatibilities(baselineMeta, currentMeta) {
+			e.logger.Warn("Baseline and current were captured with different settings, %s", problem)
+		}
+	}
+	if cfg.IgnoreMasked {
+		cfg.IgnoreRegions = append(cfg.IgnoreRegions, MaskedRegions(baselineMeta)...)
+		cfg.CurrentIgnoreRegions = append(cfg.CurrentIgnoreRegions, MaskedRegions(currentMeta)...)
+		if n := len(cfg.IgnoreRegions) + len(cfg.CurrentIgnoreRegions); n > 0 {
+			e.logger.Debug("Ignoring %d masked regions", n)
+		}
+	}
+
+	// Layout snapshots explain the differing regions by element
+	if cfg.BaselineLayout == nil {
+		if cfg.BaselineLayout, err = e.loadLayout(cfg.BaselinePath); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.CurrentLayout == nil {
+		if cfg.CurrentLayout, err = e.loadLayout(cfg.CurrentPath); err != nil {
+			return nil, err
+		}
+	}
+
+	return e.CompareImages(ctx, cfg, baseline, current)
+}
+
+// scaleRegions scales regions by the given factors, rounding outwards and
+// growing them by the pixel that interpolation bleeds, so that they still
+// cover the same content.
+func scaleRegions(regions []ports.IgnoreRegion, scaleX, scaleY float64) []ports.IgnoreRegion {
+	scaled := make([]ports.IgnoreRegion, 0, len(regions))
+	for _, r := range regions {
+		x0 := max(int(math.Floor(float64(r.X)*scaleX))-1, 0)
+		y0 := max(int(math.Floor(float64(r.Y)*scaleY))-1, 0)
+		x1 := int(math.Ceil(float64(r.X+r.Width)*scaleX)) + 1
+		y1 := int(math.Ceil(float64(r.Y+r.Height)*scaleY)) + 1
+		scaled = append(scaled, ports.IgnoreRegion{X: x0, Y: y0, Width: x1 - x0, Height: y1 - y0})
+	}
+	return scaled
+}
+
+// MaskedRegions returns the masked areas recorded in the capture metadata of
+// the compared images, for Config.IgnoreMasked. Nil metadata is skipped.
+func MaskedRegions(metas ...*metadata.Metadata) []ports.IgnoreRegion {
+	var regions []ports.IgnoreRegion
+	for _, meta := range metas {
+		if meta != nil {
+			regions = append(regions, meta.IgnoreRegions()...)
+		}
+	}
+	return regions
+}
+
+// loadLayout returns the layout snapshot of the image at path, or nil if it
+// has none.
+func (e *Executor) loadLayout(imagePath string) (*layout.Snapshot, error) {
+	snapshot, err := layout.Load(e.filesystem, imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("load layout snapshot of %s: %w", imagePath, err)
+	}
+	if snapshot != nil {
+		e.logger.Debug("Read layout snapshot of %s", imagePath)
+		if snapshot.Truncated {
+			e.logger.Warn("Layout snapshot of %s holds only %d of %d elements; additions and removals past them are not reported", imagePath, len(snapshot.Elements), snapshot.Total)
+		}
+	}
+	return snapshot, nil
+}
+
+// loadMetadata returns the capture metadata of the image at path, or nil if
+// it has none.
+func (e *Executor) loadMetadata(imagePath string) (*metadata.Metadata, error) {
+	meta, err := metadata.Load(e.filesystem, imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("load metadata of %s: %w", imagePath, err)
+	}
+	if meta != nil {
+		e.logger.Debug("Read capture metadata of %s", imagePath)
+	}
+	return meta, nil
+}
+
+// CompareImages compares two decoded images, saves the diff image and the
+// digests, and returns the result. cfg.BaselinePath and cfg.CurrentPath are
+// only used to label the result.
+func (e *Executor) CompareImages(ctx context.Context, cfg Config, baseline, current image.Image) (*Result, error) {
 	e.logger.Debug("Comparing %s vs %s", cfg.BaselinePath, cfg.CurrentPath)
 	compareOpts := ports.CompareOptions{
 		ColorThreshold:     cfg.ColorThreshold,
 		IgnoreAntialiasing: cfg.IgnoreAntialiasing,
 		MaxHeight:          cfg.MaxHeight,
-		DiffOverlay:        cfg.DiffOverlay,
+		SizeMismatch:       cfg.SizeMismatch,
+		DiffLayout:         cfg.DiffLayout,
+		CropToChanges:      cfg.CropToChanges,
 		LabelFontPath:      cfg.LabelFontPath,
 		LabelFontSize:      cfg.LabelFontSize,
 		BaselineLabel:      cfg.BaselineLabel,
@@ -51,23 +171,82 @@ func (e *Executor) Execute(ctx context.Context, cfg Config) (*Result, error) {
 		CurrentLabel:       cfg.CurrentLabel,
 	}
 
-	compareResult, err := e.processor.Compare(baseline, current, compareOpts)
-	if err != nil {
-		return nil, fmt.Errorf("compare images: %w", err)
+	if err := compareOpts.Validate(); err != nil {
+		return nil, err
 	}
 
-	// Save diff image
-	if err := e.processor.SaveImage(cfg.OutputPath, compareResult.DiffImage); err != nil {
-		return nil, fmt.Errorf("save diff image: %w", err)
+	// Identical images are told apart before any pixel comparison
+	baselineDHash := imagehash.DHash(baseline)
+	currentDHash := baselineDHash
+	if current != baseline {
+		currentDHash = imagehash.DHash(current)
 	}
 
 	result := &Result{
-		PixelDiffCount: compareResult.PixelDiffCount,
-		PixelDiffRatio: compareResult.PixelDiffRatio,
-		TotalPixels:    compareResult.TotalPixels,
-		BaselinePath:   cfg.BaselinePath,
-		CurrentPath:    cfg.CurrentPath,
-		DiffPath:       cfg.OutputPath,
+		Identical:     imagehash.Equal(baseline, current),
+		BaselinePath:  cfg.BaselinePath,
+		CurrentPath:   cfg.CurrentPath,
+		BaselineDHash: imagehash.Hex(baselineDHash),
+		CurrentDHash:  imagehash.Hex(currentDHash),
+		DHashDistance: imagehash.Distance(baselineDHash, currentDHash),
+	}
+	compareOpts.Identical = result.Identical
+
+	// A page that grew or shrank is reported as such, not only as pixels
+	baselineSize, currentSize := baseline.Bounds().Size(), current.Bounds().Size()
+	result.BaselineWidth, result.BaselineHeight = baselineSize.X, baselineSize.Y
+	result.CurrentWidth, result.CurrentHeight = currentSize.X, currentSize.Y
+	if baselineSize != currentSize {
+		result.SizeMismatch = cfg.SizeMismatch
+		if result.SizeMismatch == "" {
+			result.SizeMismatch = ports.SizeMismatchPad
+		}
+		e.logger.Warn("Baseline is %dx%d but current is %dx%d, comparing with --size-mismatch %s",
+			baselineSize.X, baselineSize.Y, currentSize.X, currentSize.Y, result.SizeMismatch)
+		if result.SizeMismatch == ports.SizeMismatchScale {
+			// Current is stretched to the size of baseline, and so are its
+			// regions and element boxes
+			scaleX := float64(baselineSize.X) / float64(currentSize.X)
+			scaleY := float64(baselineSize.Y) / float64(currentSize.Y)
+			cfg.CurrentIgnoreRegions = scaleRegions(cfg.CurrentIgnoreRegions, scaleX, scaleY)
+			if cfg.CurrentLayout != nil {
+				cfg.CurrentLayout = cfg.CurrentLayout.Scaled(scaleX, scaleY)
+			}
+		}
+	}
+	compareOpts.IgnoreRegions = append(slices.Clip(cfg.IgnoreRegions), cfg.CurrentIgnoreRegions...)
+
+	if result.Identical && cfg.SkipDiffOnEqual {
+		e.logger.Debug("Images are identical, skipping the diff image")
+		// A diff image left by an earlier run would no longer match
+		if e.filesystem.Exists(cfg.OutputPath) {
+			e.logger.Debug("Removing stale diff image %s", cfg.OutputPath)
+			if err := e.filesystem.Remove(cfg.OutputPath); err != nil {
+				return nil, fmt.Errorf("remove stale diff image: %w", err)
+			}
+		}
+		bounds := baseline.Bounds()
+		height := bounds.Dy()
+		if cfg.MaxHeight > 0 && height > cfg.MaxHeight {
+			height = cfg.MaxHeight
+		}
+		result.TotalPixels = bounds.Dx() * height
+	} else {
+		compareResult, err := e.processor.Compare(baseline, current, compareOpts)
+		if err != nil {
+			return nil, fmt.Errorf("compare images: %w", err)
+		}
+
+		// Save diff image
+		if err := e.processor.SaveImage(cfg.OutputPath, compareResult.DiffImage, cfg.Encoding); err != nil {
+			return nil, fmt.Errorf("save diff image: %w", err)
+		}
+
+		result.PixelDiffCount = compareResult.PixelDiffCount
+		result.PixelDiffRatio = compareResult.PixelDiffRatio
+		result.TotalPixels = compareResult.TotalPixels
+		result.DiffPath = cfg.OutputPath
+		result.DiffRegions = e.attribute(cfg, compareResult.DiffRegions)
 	}
 
 	// Generate digest text
@@ -97,23 +276,93 @@ func (e *Executor) Execute(ctx context.Context, cfg Config) (*Result, error) {
 	return result, nil
 }
 
+// attribute pairs the regions of differing pixels with the element changes
+// behind them, when both layout snapshots are available.
+func (e *Executor) attribute(cfg Config, regions []image.Rectangle) []layout.Attribution {
+	if cfg.BaselineLayout == nil || cfg.CurrentLayout == nil {
+		attributions := make([]layout.Attribution, 0, len(regions))
+		for _, r := range regions {
+			attributions = append(attributions, layout.Attribution{
+				Region: layout.Region{X: r.Min.X, Y: r.Min.Y, Width: r.Dx(), Height: r.Dy()},
+			})
+		}
+		return attributions
+	}
+	return layout.Attribute(regions, cfg.BaselineLayout, cfg.CurrentLayout, cfg.LayoutTolerance)
+}
+
+// maxDigestChanges caps the element changes listed in the text digest.
+const maxDigestChanges = 20
+
 // generateDigest creates a digest text summary of the comparison result.
 func (e *Executor) generateDigest(result *Result) string {
+	digest := e.generateSummary(result)
+
+	changes := layout.Changes(result.DiffRegions)
+	if len(changes) == 0 {
+		return digest
+	}
+	var b strings.Builder
+	b.WriteString(digest)
+	b.WriteString("\nChanges:")
+	for i, change := range changes {
+		if i == maxDigestChanges {
+			fmt.Fprintf(&b, "\n  ... and %d more", len(changes)-maxDigestChanges)
+			break
+		}
+		b.WriteString("\n  " + change.Description)
+	}
+	return b.String()
+}
+
+// generateSummary creates the pixel summary of the comparison result.
+func (e *Executor) generateSummary(result *Result) string {
+	output := result.DiffPath
+	if output == "" {
+		output = "(not written, images are identical)"
+	}
 	return fmt.Sprintf(`[Compare Result]
 Baseline: %s
 Current: %s
 Output: %s
 Diff Pixels: %d / %d
-Diff Percent: %.4f%%`,
+Diff Percent: %.4f%%
+Size: %s
+Identical: %t
+DHash: %s / %s (distance %d)`,
 		result.BaselinePath,
 		result.CurrentPath,
-		result.DiffPath,
+		output,
 		result.PixelDiffCount,
 		result.TotalPixels,
 		result.PixelDiffRatio*100,
+		sizeSummary(result),
+		result.Identical,
+		result.BaselineDHash,
+		result.CurrentDHash,
+		result.DHashDistance,
 	)
 }
 
+// sizeSummary describes the image dimensions, and how they changed when
+// they differ, e.g. "1280x3000 -> 1280x3200 (height +200px, pad)".
+func sizeSummary(result *Result) string {
+	baseline := fmt.Sprintf("%dx%d", result.BaselineWidth, result.BaselineHeight)
+	if result.SizeMismatch == "" {
+		return baseline
+	}
+
+	var changes []string
+	if d := result.CurrentWidth - result.BaselineWidth; d != 0 {
+		changes = append(changes, fmt.Sprintf("width %+dpx", d))
+	}
+	if d := result.CurrentHeight - result.BaselineHeight; d != 0 {
+		changes = append(changes, fmt.Sprintf("height %+dpx", d))
+	}
+	changes = append(changes, result.SizeMismatch)
+	return fmt.Sprintf("%s -> %dx%d (%s)", baseline, result.CurrentWidth, result.CurrentHeight, strings.Join(changes, ", "))
+}
+
 // saveFile saves content to a file, creating directories as needed.
 func (e *Executor) saveFile(path, content string) error {
 	dir := filepath.Dir(path)
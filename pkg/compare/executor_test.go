@@ -0,0 +1,274 @@
+package compare
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+
+	"github.com/ideamans/static-webshot/pkg/adapters/logger"
+	"github.com/ideamans/static-webshot/pkg/adapters/osfilesystem"
+	"github.com/ideamans/static-webshot/pkg/adapters/pixelmatch"
+	"github.com/ideamans/static-webshot/pkg/layout"
+	"github.com/ideamans/static-webshot/pkg/metadata"
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
+// writeSquareImages saves 40x30 white baseline and current images that
+// differ only in the black 10x10 square at (5,5) of the current image.
+func writeSquareImages(t *testing.T, processor *pixelmatch.Processor) Config {
+	t.Helper()
+	dir := t.TempDir()
+
+	baseline := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	current := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 40; x++ {
+			baseline.Set(x, y, color.White)
+			current.Set(x, y, color.White)
+		}
+	}
+	for y := 5; y < 15; y++ {
+		for x := 5; x < 15; x++ {
+			current.Set(x, y, color.Black)
+		}
+	}
+
+	cfg := DefaultConfig()
+	cfg.BaselinePath = filepath.Join(dir, "baseline.png")
+	cfg.CurrentPath = filepath.Join(dir, "current.png")
+	cfg.OutputPath = filepath.Join(dir, "diff.png")
+	if err := processor.SaveImage(cfg.BaselinePath, baseline, ports.EncodeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := processor.SaveImage(cfg.CurrentPath, current, ports.EncodeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestExecutor_Execute_IgnoresMaskedRegions(t *testing.T) {
+	processor := pixelmatch.New()
+	fs := osfilesystem.New()
+	cfg := writeSquareImages(t, processor)
+
+	executor := NewExecutor(processor, fs, logger.New())
+	result, err := executor.Execute(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if result.PixelDiffCount == 0 {
+		t.Fatal("PixelDiffCount = 0 without metadata, want a difference")
+	}
+
+	// Masking the square in the current image's metadata hides the difference
+	meta := &metadata.Metadata{Masks: []metadata.MaskRegion{
+		{Selector: ".ad", Style: "blackout", X: 5, Y: 5, Width: 10, Height: 10},
+	}}
+	if err := metadata.Write(fs, metadata.SidecarPath(cfg.CurrentPath), meta); err != nil {
+		t.Fatal(err)
+	}
+	result, err = executor.Execute(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if result.PixelDiffCount != 0 {
+		t.Errorf("PixelDiffCount = %d with masked metadata, want 0", result.PixelDiffCount)
+	}
+
+	cfg.IgnoreMasked = false
+	result, err = executor.Execute(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if result.PixelDiffCount == 0 {
+		t.Error("PixelDiffCount = 0 with IgnoreMasked = false, want a difference")
+	}
+}
+
+func TestExecutor_Execute_AttributesDiffRegions(t *testing.T) {
+	processor := pixelmatch.New()
+	fs := osfilesystem.New()
+	cfg := writeSquareImages(t, processor)
+
+	executor := NewExecutor(processor, fs, logger.New())
+	result, err := executor.Execute(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if len(result.DiffRegions) != 1 || len(result.DiffRegions[0].Changes) != 0 {
+		t.Fatalf("DiffRegions = %+v without layouts, want one region without changes", result.DiffRegions)
+	}
+
+	// The square is a badge that appeared in the current page
+	badge := layout.Element{Path: "body > span", Label: "span.badge", Tag: "span", X: 5, Y: 5, Width: 10, Height: 10}
+	if err := layout.Write(fs, layout.SidecarPath(cfg.BaselinePath), &layout.Snapshot{ScaleX: 1, ScaleY: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := layout.Write(fs, layout.SidecarPath(cfg.CurrentPath), &layout.Snapshot{ScaleX: 1, ScaleY: 1, Elements: []layout.Element{badge}}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = executor.Execute(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	changes := layout.Changes(result.DiffRegions)
+	if len(changes) != 1 || changes[0].Description != "span.badge was added" {
+		t.Errorf("changes = %+v, want span.badge was added", changes)
+	}
+}
+
+func TestExecutor_Execute_IdenticalImages(t *testing.T) {
+	processor := pixelmatch.New()
+	fs := osfilesystem.New()
+	cfg := writeSquareImages(t, processor)
+	executor := NewExecutor(processor, fs, logger.New())
+
+	result, err := executor.Execute(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if result.Identical {
+		t.Error("Identical = true for differing images")
+	}
+	if result.BaselineDHash == "" || result.CurrentDHash == "" {
+		t.Errorf("DHashes = %q, %q, want both", result.BaselineDHash, result.CurrentDHash)
+	}
+
+	// The same pixels encoded differently are identical
+	img, err := processor.LoadImage(cfg.BaselinePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := processor.SaveImage(cfg.CurrentPath, img, ports.EncodeOptions{Compression: ports.CompressionNone}); err != nil {
+		t.Fatal(err)
+	}
+	cfg.SkipDiffOnEqual = true
+	cfg.OutputPath = filepath.Join(t.TempDir(), "diff.png")
+	result, err = executor.Execute(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if !result.Identical || result.DHashDistance != 0 {
+		t.Errorf("Identical = %v, DHashDistance = %d for the same pixels, want true, 0", result.Identical, result.DHashDistance)
+	}
+	if result.TotalPixels != 40*30 || result.DiffPath != "" {
+		t.Errorf("TotalPixels = %d, DiffPath = %q, want %d, none", result.TotalPixels, result.DiffPath, 40*30)
+	}
+	if fs.Exists(cfg.OutputPath) {
+		t.Error("diff image written with SkipDiffOnEqual for identical images")
+	}
+
+	// Byte-identical files still get a diff image without SkipDiffOnEqual
+	cfg.CurrentPath = cfg.BaselinePath
+	cfg.SkipDiffOnEqual = false
+	result, err = executor.Execute(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if !result.Identical || result.PixelDiffCount != 0 {
+		t.Errorf("Identical = %v, PixelDiffCount = %d for the same file, want true, 0", result.Identical, result.PixelDiffCount)
+	}
+	if !fs.Exists(cfg.OutputPath) {
+		t.Error("diff image not written without SkipDiffOnEqual")
+	}
+
+	// With SkipDiffOnEqual, that diff image is now stale and removed
+	cfg.SkipDiffOnEqual = true
+	if _, err := executor.Execute(context.Background(), cfg); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if fs.Exists(cfg.OutputPath) {
+		t.Error("stale diff image left with SkipDiffOnEqual for identical images")
+	}
+}
+
+func TestExecutor_Execute_SizeMismatch(t *testing.T) {
+	processor := pixelmatch.New()
+	fs := osfilesystem.New()
+	cfg := writeSquareImages(t, processor)
+	executor := NewExecutor(processor, fs, logger.New())
+
+	// The current page grew by 20px
+	if err := processor.SaveImage(cfg.CurrentPath, image.NewRGBA(image.Rect(0, 0, 40, 50)), ports.EncodeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := executor.Execute(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if result.BaselineWidth != 40 || result.BaselineHeight != 30 || result.CurrentWidth != 40 || result.CurrentHeight != 50 {
+		t.Errorf("sizes = %dx%d, %dx%d, want 40x30, 40x50",
+			result.BaselineWidth, result.BaselineHeight, result.CurrentWidth, result.CurrentHeight)
+	}
+	if result.SizeMismatch != ports.SizeMismatchPad {
+		t.Errorf("SizeMismatch = %q, want %q", result.SizeMismatch, ports.SizeMismatchPad)
+	}
+	if got, want := sizeSummary(result), "40x30 -> 40x50 (height +20px, pad)"; got != want {
+		t.Errorf("sizeSummary() = %q, want %q", got, want)
+	}
+
+	cfg.SizeMismatch = ports.SizeMismatchFail
+	if _, err := executor.Execute(context.Background(), cfg); err == nil {
+		t.Error("Execute() with fail succeeded for images of different sizes")
+	}
+
+	cfg.SizeMismatch = "stretch"
+	if _, err := executor.Execute(context.Background(), cfg); err == nil {
+		t.Error("Execute() with an unknown strategy succeeded")
+	}
+}
+
+func TestExecutor_CompareImages_ScaleMapsCurrent(t *testing.T) {
+	processor := pixelmatch.New()
+	executor := NewExecutor(processor, osfilesystem.New(), logger.New())
+
+	// Current is twice the size of baseline, with a masked black square and
+	// a box that moved onto another black square
+	baseline := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	current := image.NewRGBA(image.Rect(0, 0, 80, 60))
+	for _, img := range []*image.RGBA{baseline, current} {
+		for y := 0; y < img.Rect.Dy(); y++ {
+			for x := 0; x < img.Rect.Dx(); x++ {
+				img.Set(x, y, color.White)
+			}
+		}
+	}
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			current.Set(40+x, y, color.Black)
+			current.Set(40+x, 40+y, color.Black)
+		}
+	}
+
+	cfg := DefaultConfig()
+	cfg.OutputPath = filepath.Join(t.TempDir(), "diff.png")
+	cfg.SizeMismatch = ports.SizeMismatchScale
+	cfg.CurrentIgnoreRegions = []ports.IgnoreRegion{{X: 40, Y: 0, Width: 20, Height: 20}}
+	cfg.BaselineLayout = &layout.Snapshot{ScaleX: 1, ScaleY: 1, Elements: []layout.Element{
+		{Path: "#box", Label: "#box", X: 20, Y: 0, Width: 10, Height: 10},
+	}}
+	cfg.CurrentLayout = &layout.Snapshot{ScaleX: 2, ScaleY: 2, Elements: []layout.Element{
+		{Path: "#box", Label: "#box", X: 20, Y: 20, Width: 10, Height: 10},
+	}}
+
+	result, err := executor.CompareImages(context.Background(), cfg, baseline, current)
+	if err != nil {
+		t.Fatalf("CompareImages() error = %v", err)
+	}
+
+	// Only the unmasked square differs, at (20,20) in baseline pixels
+	if len(result.DiffRegions) != 1 {
+		t.Fatalf("DiffRegions = %+v, want 1", result.DiffRegions)
+	}
+	if r := result.DiffRegions[0].Region; r.X < 19 || r.Y < 19 || r.X+r.Width > 31 || r.Y+r.Height > 31 {
+		t.Errorf("Region = %+v, want about (20,20) 10x10", r)
+	}
+	changes := layout.Changes(result.DiffRegions)
+	if len(changes) != 1 || changes[0].Path != "#box" {
+		t.Errorf("Changes = %+v, want #box", changes)
+	}
+}
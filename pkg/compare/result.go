@@ -4,6 +4,8 @@ package compare
 import (
 	"encoding/json"
 	"fmt"
+
+	"github.com/ideamans/static-webshot/pkg/layout"
 )
 
 // Result holds the comparison result data.
@@ -15,6 +17,32 @@ type Result struct {
 	BaselinePath   string  `json:"baselinePath"`
 	CurrentPath    string  `json:"currentPath"`
 	DiffPath       string  `json:"diffPath,omitempty"`
+
+	// The dimensions of the images as loaded, before any padding, cropping
+	// or scaling.
+	BaselineWidth  int `json:"baselineWidth"`
+	BaselineHeight int `json:"baselineHeight"`
+	CurrentWidth   int `json:"currentWidth"`
+	CurrentHeight  int `json:"currentHeight"`
+
+	// SizeMismatch is the strategy the images were compared with, set only
+	// when their sizes differ.
+	SizeMismatch string `json:"sizeMismatch,omitempty"`
+
+	// Identical is set when the images have the same pixels. The diff image
+	// is not written for identical images with SkipDiffOnEqual.
+	Identical bool `json:"identical"`
+
+	// BaselineDHash and CurrentDHash are the perceptual difference hashes
+	// of the images, as hexadecimal. Images that look alike have hashes a
+	// small DHashDistance (differing bits, 0-64) apart.
+	BaselineDHash string `json:"baselineDHash"`
+	CurrentDHash  string `json:"currentDHash"`
+	DHashDistance int    `json:"dHashDistance"`
+
+	// DiffRegions are the regions of differing pixels, with the element
+	// changes behind them when both images have layout snapshots.
+	DiffRegions []layout.Attribution `json:"diffRegions,omitempty"`
 }
 
 // ToJSON converts the result to JSON string.
@@ -0,0 +1,24 @@
+// Package comparea11y provides the compare-a11y command logic.
+package comparea11y
+
+// Config holds configuration for the compare-a11y command.
+type Config struct {
+	// BaselinePath is the baseline accessibility snapshot, or the screenshot
+	// it was recorded next to.
+	BaselinePath string
+
+	// CurrentPath is the current accessibility snapshot, or the screenshot
+	// it was recorded next to.
+	CurrentPath string
+
+	// DigestTxtPath is the path for text digest output (optional).
+	DigestTxtPath string
+
+	// DigestJSONPath is the path for JSON digest output (optional).
+	DigestJSONPath string
+}
+
+// DefaultConfig returns a Config with default values.
+func DefaultConfig() Config {
+	return Config{}
+}
@@ -0,0 +1,65 @@
+package comparea11y
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ideamans/static-webshot/pkg/a11y"
+	"github.com/ideamans/static-webshot/pkg/digest"
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
+// Executor executes the compare-a11y command.
+type Executor struct {
+	filesystem ports.FileSystem
+	logger     ports.Logger
+}
+
+// NewExecutor creates a new Executor with the given dependencies.
+func NewExecutor(filesystem ports.FileSystem, logger ports.Logger) *Executor {
+	return &Executor{
+		filesystem: filesystem,
+		logger:     logger,
+	}
+}
+
+// Execute runs the compare-a11y command with the given configuration.
+func (e *Executor) Execute(ctx context.Context, cfg Config) (*Result, error) {
+	baseline, err := e.loadSnapshot(cfg.BaselinePath)
+	if err != nil {
+		return nil, fmt.Errorf("load baseline: %w", err)
+	}
+
+	current, err := e.loadSnapshot(cfg.CurrentPath)
+	if err != nil {
+		return nil, fmt.Errorf("load current: %w", err)
+	}
+
+	e.logger.Debug("Comparing accessibility trees %s vs %s", cfg.BaselinePath, cfg.CurrentPath)
+	changes := a11y.Diff(baseline, current)
+
+	result := &Result{
+		BaselinePath:  cfg.BaselinePath,
+		CurrentPath:   cfg.CurrentPath,
+		BaselineNodes: len(baseline.Nodes),
+		CurrentNodes:  len(current.Nodes),
+		Counts:        a11y.Count(changes),
+		Changes:       changes,
+	}
+
+	if err := digest.Write(e.filesystem, result, cfg.DigestTxtPath, cfg.DigestJSONPath); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// loadSnapshot reads the accessibility snapshot at path, or the one recorded
+// next to the screenshot at path.
+func (e *Executor) loadSnapshot(path string) (*a11y.Snapshot, error) {
+	path, err := digest.SnapshotPath(e.filesystem, path, a11y.SidecarPath, "accessibility", "--a11y-snapshot")
+	if err != nil {
+		return nil, err
+	}
+	return a11y.Read(e.filesystem, path)
+}
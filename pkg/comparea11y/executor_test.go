@@ -0,0 +1,63 @@
+package comparea11y
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/ideamans/static-webshot/pkg/a11y"
+	"github.com/ideamans/static-webshot/pkg/adapters/logger"
+	"github.com/ideamans/static-webshot/pkg/adapters/osfilesystem"
+)
+
+func TestExecutor_Execute(t *testing.T) {
+	dir := t.TempDir()
+	fs := osfilesystem.New()
+
+	baseline := &a11y.Snapshot{Nodes: []a11y.Node{
+		{Path: "banner", Role: "banner"},
+		{Path: "banner > button", Role: "button", Name: "Open menu"},
+	}}
+	// The icon-only button lost its aria-label
+	current := &a11y.Snapshot{Nodes: []a11y.Node{
+		{Path: "banner", Role: "banner"},
+		{Path: "banner > button", Role: "button"},
+	}}
+
+	cfg := DefaultConfig()
+	cfg.BaselinePath = filepath.Join(dir, "baseline.png")
+	cfg.CurrentPath = filepath.Join(dir, "current.a11y.json")
+	cfg.DigestJSONPath = filepath.Join(dir, "digest.json")
+	if err := a11y.Write(fs, a11y.SidecarPath(cfg.BaselinePath), baseline); err != nil {
+		t.Fatal(err)
+	}
+	if err := a11y.Write(fs, cfg.CurrentPath, current); err != nil {
+		t.Fatal(err)
+	}
+
+	executor := NewExecutor(fs, logger.New())
+	result, err := executor.Execute(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if len(result.Changes) != 1 || result.Changes[0].Description != `button "Open menu" lost its name` {
+		t.Errorf("Changes = %+v, want the button losing its name", result.Changes)
+	}
+	if result.Counts[a11y.KindRenamed] != 1 {
+		t.Errorf("Counts = %v", result.Counts)
+	}
+	if !fs.Exists(cfg.DigestJSONPath) {
+		t.Error("JSON digest was not written")
+	}
+}
+
+func TestExecutor_Execute_MissingSnapshot(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.BaselinePath = filepath.Join(t.TempDir(), "baseline.png")
+	cfg.CurrentPath = cfg.BaselinePath
+
+	executor := NewExecutor(osfilesystem.New(), logger.New())
+	if _, err := executor.Execute(context.Background(), cfg); err == nil {
+		t.Error("Execute() without snapshots succeeded, want an error")
+	}
+}
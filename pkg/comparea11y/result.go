@@ -0,0 +1,53 @@
+package comparea11y
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ideamans/static-webshot/pkg/a11y"
+	"github.com/ideamans/static-webshot/pkg/digest"
+)
+
+// Result holds the accessibility comparison result data.
+type Result struct {
+	BaselinePath string `json:"baselinePath"`
+	CurrentPath  string `json:"currentPath"`
+
+	// Nodes are the numbers of nodes in the snapshots.
+	BaselineNodes int `json:"baselineNodes"`
+	CurrentNodes  int `json:"currentNodes"`
+
+	// Counts are the numbers of changes of each kind.
+	Counts map[string]int `json:"counts"`
+
+	Changes []a11y.Change `json:"changes"`
+}
+
+// ToJSON converts the result to JSON string.
+func (r *Result) ToJSON() (string, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// ToText converts the result to human-readable text.
+func (r *Result) ToText() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `[Compare Accessibility Result]
+Baseline: %s (%d nodes)
+Current: %s (%d nodes)
+Changes: %d`,
+		r.BaselinePath, r.BaselineNodes,
+		r.CurrentPath, r.CurrentNodes,
+		len(r.Changes),
+	)
+	b.WriteString(digest.Counts(r.Counts, []string{a11y.KindAdded, a11y.KindRemoved, a11y.KindRenamed, a11y.KindStates}))
+
+	for _, change := range r.Changes {
+		b.WriteString("\n  " + change.Description)
+	}
+	return b.String()
+}
@@ -0,0 +1,32 @@
+// Package comparelayout provides the compare-layout command logic.
+package comparelayout
+
+import "github.com/ideamans/static-webshot/pkg/layout"
+
+// Config holds configuration for the compare-layout command.
+type Config struct {
+	// BaselinePath is the baseline layout snapshot, or the screenshot it was
+	// recorded next to.
+	BaselinePath string
+
+	// CurrentPath is the current layout snapshot, or the screenshot it was
+	// recorded next to.
+	CurrentPath string
+
+	// Tolerance is how far elements may move or resize, in CSS pixels,
+	// before they count as changed.
+	Tolerance layout.Tolerance
+
+	// DigestTxtPath is the path for text digest output (optional).
+	DigestTxtPath string
+
+	// DigestJSONPath is the path for JSON digest output (optional).
+	DigestJSONPath string
+}
+
+// DefaultConfig returns a Config with default values.
+func DefaultConfig() Config {
+	return Config{
+		Tolerance: layout.DefaultTolerance,
+	}
+}
@@ -0,0 +1,72 @@
+package comparelayout
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ideamans/static-webshot/pkg/digest"
+	"github.com/ideamans/static-webshot/pkg/layout"
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
+// Executor executes the compare-layout command.
+type Executor struct {
+	filesystem ports.FileSystem
+	logger     ports.Logger
+}
+
+// NewExecutor creates a new Executor with the given dependencies.
+func NewExecutor(filesystem ports.FileSystem, logger ports.Logger) *Executor {
+	return &Executor{
+		filesystem: filesystem,
+		logger:     logger,
+	}
+}
+
+// Execute runs the compare-layout command with the given configuration.
+func (e *Executor) Execute(ctx context.Context, cfg Config) (*Result, error) {
+	baseline, err := e.loadSnapshot(cfg.BaselinePath)
+	if err != nil {
+		return nil, fmt.Errorf("load baseline: %w", err)
+	}
+
+	current, err := e.loadSnapshot(cfg.CurrentPath)
+	if err != nil {
+		return nil, fmt.Errorf("load current: %w", err)
+	}
+
+	e.logger.Debug("Comparing layouts %s vs %s", cfg.BaselinePath, cfg.CurrentPath)
+	changes := layout.Diff(baseline, current, cfg.Tolerance)
+
+	result := &Result{
+		BaselinePath:     cfg.BaselinePath,
+		CurrentPath:      cfg.CurrentPath,
+		BaselineElements: len(baseline.Elements),
+		CurrentElements:  len(current.Elements),
+		Counts:           layout.Count(changes),
+		Changes:          changes,
+	}
+
+	if err := digest.Write(e.filesystem, result, cfg.DigestTxtPath, cfg.DigestJSONPath); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// loadSnapshot reads the layout snapshot at path, or the one recorded next to
+// the screenshot at path.
+func (e *Executor) loadSnapshot(path string) (*layout.Snapshot, error) {
+	path, err := digest.SnapshotPath(e.filesystem, path, layout.SidecarPath, "layout", "--layout-snapshot")
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := layout.Read(e.filesystem, path)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot.Truncated {
+		e.logger.Warn("Layout snapshot %s holds only %d of %d elements; additions and removals past them are not reported", path, len(snapshot.Elements), snapshot.Total)
+	}
+	return snapshot, nil
+}
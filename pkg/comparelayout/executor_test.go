@@ -0,0 +1,88 @@
+package comparelayout
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/ideamans/static-webshot/pkg/adapters/logger"
+	"github.com/ideamans/static-webshot/pkg/adapters/osfilesystem"
+	"github.com/ideamans/static-webshot/pkg/layout"
+)
+
+func TestExecutor_Execute(t *testing.T) {
+	dir := t.TempDir()
+	fs := osfilesystem.New()
+
+	baseline := &layout.Snapshot{Elements: []layout.Element{
+		{Path: "body > main", Label: "main", X: 0, Y: 60, Width: 800, Height: 400},
+		{Path: "body > main > h1", Label: "h1", X: 0, Y: 60, Width: 800, Height: 40},
+		{Path: "body > main > ul", Label: "ul.list", X: 0, Y: 100, Width: 800, Height: 40},
+		{Path: "body > main > ul > li", Label: "li", X: 0, Y: 100, Width: 800, Height: 40},
+	}}
+	// main shifted by 1px, the list was removed and a banner was added
+	current := &layout.Snapshot{Elements: []layout.Element{
+		{Path: "body > div", Label: "div.banner", X: 0, Y: 0, Width: 800, Height: 60},
+		{Path: "body > div > p", Label: "p", X: 0, Y: 0, Width: 800, Height: 20},
+		{Path: "body > main", Label: "main", X: 0, Y: 61, Width: 800, Height: 400},
+		{Path: "body > main > h1", Label: "h1", X: 0, Y: 61, Width: 800, Height: 40},
+	}}
+
+	cfg := DefaultConfig()
+	cfg.BaselinePath = filepath.Join(dir, "baseline.png")
+	cfg.CurrentPath = filepath.Join(dir, "current.layout.json")
+	cfg.DigestJSONPath = filepath.Join(dir, "digest.json")
+	if err := layout.Write(fs, layout.SidecarPath(cfg.BaselinePath), baseline); err != nil {
+		t.Fatal(err)
+	}
+	if err := layout.Write(fs, cfg.CurrentPath, current); err != nil {
+		t.Fatal(err)
+	}
+
+	executor := NewExecutor(fs, logger.New())
+	result, err := executor.Execute(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	want := []string{
+		"main moved 1px down",
+		"ul.list was removed",
+		"div.banner was added",
+	}
+	if len(result.Changes) != len(want) {
+		t.Fatalf("Changes = %+v, want %v", result.Changes, want)
+	}
+	for i, change := range result.Changes {
+		if change.Description != want[i] {
+			t.Errorf("Changes[%d] = %q, want %q", i, change.Description, want[i])
+		}
+	}
+	if result.Counts[layout.KindMoved] != 1 || result.Counts[layout.KindAdded] != 1 || result.Counts[layout.KindRemoved] != 1 {
+		t.Errorf("Counts = %v", result.Counts)
+	}
+	if !fs.Exists(cfg.DigestJSONPath) {
+		t.Error("JSON digest was not written")
+	}
+
+	// A 1px shift is within a 1px tolerance
+	cfg.Tolerance = layout.Tolerance{Position: 1, Size: 1}
+	result, err = executor.Execute(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if result.Counts[layout.KindMoved] != 0 {
+		t.Errorf("Counts = %v with 1px tolerance, want no moves", result.Counts)
+	}
+}
+
+func TestExecutor_Execute_MissingSnapshot(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.BaselinePath = filepath.Join(t.TempDir(), "baseline.png")
+	cfg.CurrentPath = cfg.BaselinePath
+
+	executor := NewExecutor(osfilesystem.New(), logger.New())
+	if _, err := executor.Execute(context.Background(), cfg); err == nil {
+		t.Error("Execute() without snapshots succeeded, want an error")
+	}
+}
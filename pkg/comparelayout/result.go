@@ -0,0 +1,53 @@
+package comparelayout
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ideamans/static-webshot/pkg/digest"
+	"github.com/ideamans/static-webshot/pkg/layout"
+)
+
+// Result holds the layout comparison result data.
+type Result struct {
+	BaselinePath string `json:"baselinePath"`
+	CurrentPath  string `json:"currentPath"`
+
+	// Elements are the numbers of elements in the snapshots.
+	BaselineElements int `json:"baselineElements"`
+	CurrentElements  int `json:"currentElements"`
+
+	// Counts are the numbers of changes of each kind.
+	Counts map[string]int `json:"counts"`
+
+	Changes []layout.Change `json:"changes"`
+}
+
+// ToJSON converts the result to JSON string.
+func (r *Result) ToJSON() (string, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// ToText converts the result to human-readable text.
+func (r *Result) ToText() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `[Compare Layout Result]
+Baseline: %s (%d elements)
+Current: %s (%d elements)
+Changes: %d`,
+		r.BaselinePath, r.BaselineElements,
+		r.CurrentPath, r.CurrentElements,
+		len(r.Changes),
+	)
+	b.WriteString(digest.Counts(r.Counts, []string{layout.KindAdded, layout.KindRemoved, layout.KindMoved, layout.KindResized, layout.KindRestyled, layout.KindText}))
+
+	for _, change := range r.Changes {
+		b.WriteString("\n  " + change.Description)
+	}
+	return b.String()
+}
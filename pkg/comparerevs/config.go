@@ -0,0 +1,51 @@
+// Package comparerevs provides the compare-revs command logic.
+package comparerevs
+
+import (
+	"github.com/ideamans/static-webshot/pkg/compare"
+	"github.com/ideamans/static-webshot/pkg/record"
+)
+
+// Config holds configuration for the compare-revs command.
+type Config struct {
+	// RepoDir is the git repository containing the site (default: ".").
+	RepoDir string
+
+	// BaselineRev is the git revision captured as the baseline.
+	BaselineRev string
+
+	// CurrentRev is the git revision captured as the current state.
+	CurrentRev string
+
+	// BuildCommand is the shell command that builds the site in each checkout (optional).
+	BuildCommand string
+
+	// SiteDir is the build output directory served for capture, relative to the checkout root.
+	SiteDir string
+
+	// Pages are the URL paths captured from the served site (default: "/").
+	Pages []string
+
+	// OutputDir is the directory receiving baseline, current and diff images.
+	OutputDir string
+
+	// Record holds the capture settings applied to every page of both revisions.
+	// URL and OutputPath are set per page.
+	Record record.Config
+
+	// Compare holds the comparison settings applied to every page.
+	// Paths are set per page.
+	Compare compare.Config
+}
+
+// DefaultConfig returns a Config with default values.
+func DefaultConfig() Config {
+	return Config{
+		RepoDir:   ".",
+		SiteDir:   ".",
+		Pages:     []string{"/"},
+		OutputDir: "./compare-revs",
+		Record:    record.DefaultConfig(),
+		Compare:   compare.DefaultConfig(),
+	}
+}
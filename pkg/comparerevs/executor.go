@@ -0,0 +1,187 @@
+// Package comparerevs provides the compare-revs command execution logic.
+package comparerevs
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ideamans/static-webshot/pkg/compare"
+	"github.com/ideamans/static-webshot/pkg/devserver"
+	"github.com/ideamans/static-webshot/pkg/imageformat"
+	"github.com/ideamans/static-webshot/pkg/ports"
+	"github.com/ideamans/static-webshot/pkg/record"
+)
+
+// Executor executes the compare-revs command.
+type Executor struct {
+	vcs        ports.VersionControl
+	server     ports.StaticServer
+	browser    ports.Browser
+	processor  ports.ImageProcessor
+	filesystem ports.FileSystem
+	logger     ports.Logger
+}
+
+// NewExecutor creates a new Executor with the given dependencies.
+func NewExecutor(vcs ports.VersionControl, server ports.StaticServer, browser ports.Browser, processor ports.ImageProcessor, filesystem ports.FileSystem, logger ports.Logger) *Executor {
+	return &Executor{
+		vcs:        vcs,
+		server:     server,
+		browser:    browser,
+		processor:  processor,
+		filesystem: filesystem,
+		logger:     logger,
+	}
+}
+
+// Execute builds and captures both revisions, then compares every page.
+func (e *Executor) Execute(ctx context.Context, cfg Config) ([]*compare.Result, error) {
+	pages := cfg.Pages
+	if len(pages) == 0 {
+		pages = []string{"/"}
+	}
+	if err := checkPageNames(pages); err != nil {
+		return nil, err
+	}
+
+	baselineDir := filepath.Join(cfg.OutputDir, "baseline")
+	currentDir := filepath.Join(cfg.OutputDir, "current")
+	diffDir := filepath.Join(cfg.OutputDir, "diff")
+
+	if err := e.captureRevision(ctx, cfg, cfg.BaselineRev, pages, baselineDir); err != nil {
+		return nil, fmt.Errorf("baseline %s: %w", cfg.BaselineRev, err)
+	}
+	if err := e.captureRevision(ctx, cfg, cfg.CurrentRev, pages, currentDir); err != nil {
+		return nil, fmt.Errorf("current %s: %w", cfg.CurrentRev, err)
+	}
+
+	comparer := compare.NewExecutor(e.processor, e.filesystem, e.logger)
+	var results []*compare.Result
+	for _, page := range pages {
+		name := PageFileName(page)
+
+		compareCfg := cfg.Compare
+		compareCfg.BaselinePath = filepath.Join(baselineDir, name+imageformat.Extension(cfg.Record.Encoding.Format))
+		compareCfg.CurrentPath = filepath.Join(currentDir, name+imageformat.Extension(cfg.Record.Encoding.Format))
+		compareCfg.OutputPath = filepath.Join(diffDir, name+imageformat.Extension(cfg.Compare.Encoding.Format))
+		compareCfg.DigestJSONPath = filepath.Join(diffDir, name+".json")
+
+		e.logger.Info("Comparing %s...", page)
+		result, err := comparer.Execute(ctx, compareCfg)
+		if err != nil {
+			return nil, fmt.Errorf("compare %s: %w", page, err)
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// captureRevision checks rev out, builds it, serves the site and captures
+// every page into outputDir. The checkout is removed even on failure.
+func (e *Executor) captureRevision(ctx context.Context, cfg Config, rev string, pages []string, outputDir string) (err error) {
+	e.logger.Info("Checking out %s...", rev)
+	worktree, err := e.vcs.CreateWorktree(ctx, cfg.RepoDir, rev)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if removeErr := e.vcs.RemoveWorktree(context.Background(), cfg.RepoDir, worktree); removeErr != nil {
+			e.logger.Warn("Failed to remove worktree %s: %v", worktree, removeErr)
+		}
+	}()
+
+	if cfg.BuildCommand != "" {
+		if err := e.build(ctx, cfg.BuildCommand, worktree); err != nil {
+			return err
+		}
+	}
+
+	siteDir := filepath.Join(worktree, cfg.SiteDir)
+	if !e.filesystem.Exists(siteDir) {
+		return fmt.Errorf("site directory %s does not exist after build", cfg.SiteDir)
+	}
+
+	baseURL, err := e.server.Serve(siteDir)
+	if err != nil {
+		return fmt.Errorf("serve %s: %w", cfg.SiteDir, err)
+	}
+	defer e.server.Close()
+	e.logger.Debug("Serving %s at %s", siteDir, baseURL)
+
+	recorder := record.NewExecutor(e.browser, e.filesystem, e.logger)
+	for _, page := range pages {
+		recordCfg := cfg.Record
+		recordCfg.URL = baseURL + "/" + strings.TrimPrefix(page, "/")
+		recordCfg.OutputPath = filepath.Join(outputDir, PageFileName(page)+imageformat.Extension(cfg.Record.Encoding.Format))
+
+		if err := recorder.Execute(ctx, recordCfg); err != nil {
+			return fmt.Errorf("capture %s: %w", page, err)
+		}
+	}
+
+	return nil
+}
+
+// build runs the build command inside the checkout.
+func (e *Executor) build(ctx context.Context, command, dir string) error {
+	e.logger.Info("Building: %s", command)
+	cmd := devserver.Command(ctx, command)
+	cmd.Dir = dir
+
+	output, err := cmd.CombinedOutput()
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		e.logger.Debug("[build] %s", scanner.Text())
+	}
+	if err != nil {
+		return fmt.Errorf("build command failed: %w\n%s", err, lastLines(string(output), 20))
+	}
+
+	return nil
+}
+
+// pageNameEscaper maps path separators to underscores. Underscores and the
+// escape character itself are percent-encoded first, so distinct paths such
+// as "/a/b" and "/a_b" never share a file name.
+var pageNameEscaper = strings.NewReplacer("%", "%25", "_", "%5F", "/", "_")
+
+// PageFileName converts a URL path into a file name stem ("/" -> "index",
+// "/docs/intro/" -> "docs_intro", "/a_b" -> "a%5Fb"). The query and fragment
+// are dropped.
+func PageFileName(page string) string {
+	if i := strings.IndexAny(page, "?#"); i >= 0 {
+		page = page[:i]
+	}
+	name := strings.Trim(page, "/")
+	if name == "" {
+		return "index"
+	}
+	return pageNameEscaper.Replace(name)
+}
+
+// checkPageNames rejects page lists in which two pages would be saved under
+// the same file name, as the later capture would overwrite the earlier one.
+func checkPageNames(pages []string) error {
+	seen := make(map[string]string, len(pages))
+	for _, page := range pages {
+		name := PageFileName(page)
+		if other, ok := seen[name]; ok {
+			return fmt.Errorf("pages %q and %q would both be saved as %q", other, page, name)
+		}
+		seen[name] = page
+	}
+	return nil
+}
+
+// lastLines returns at most n trailing lines of s.
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
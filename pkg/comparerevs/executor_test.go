@@ -0,0 +1,185 @@
+package comparerevs
+
+import (
+	"context"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ideamans/static-webshot/internal/portstest"
+	"github.com/ideamans/static-webshot/pkg/adapters/logger"
+	"github.com/ideamans/static-webshot/pkg/adapters/osfilesystem"
+	"github.com/ideamans/static-webshot/pkg/adapters/pixelmatch"
+)
+
+// fakeVCS checks revisions out into empty temporary directories and records
+// which of them are still present.
+type fakeVCS struct {
+	t       *testing.T
+	revs    map[string]string // worktree -> revision
+	removed []string
+}
+
+func (v *fakeVCS) CreateWorktree(ctx context.Context, repoDir, rev string) (string, error) {
+	dir := v.t.TempDir()
+	v.revs[dir] = rev
+	return dir, nil
+}
+
+func (v *fakeVCS) RemoveWorktree(ctx context.Context, repoDir, dir string) error {
+	v.removed = append(v.removed, v.revs[dir])
+	return nil
+}
+
+// fakeServer serves a worktree's site under a host named after its revision.
+type fakeServer struct {
+	vcs    *fakeVCS
+	served []string
+	closed int
+}
+
+func (s *fakeServer) Serve(dir string) (string, error) {
+	for worktree, rev := range s.vcs.revs {
+		if strings.HasPrefix(dir, worktree) {
+			s.served = append(s.served, dir)
+			return "http://" + rev, nil
+		}
+	}
+	return "", os.ErrNotExist
+}
+
+func (s *fakeServer) Close() error {
+	s.closed++
+	return nil
+}
+
+func newTestExecutor(t *testing.T, browser *portstest.Browser) (*Executor, *fakeVCS, *fakeServer) {
+	vcs := &fakeVCS{t: t, revs: map[string]string{}}
+	server := &fakeServer{vcs: vcs}
+	return NewExecutor(vcs, server, browser, pixelmatch.New(), osfilesystem.New(), logger.New()), vcs, server
+}
+
+func TestExecutor_Execute(t *testing.T) {
+	dir := t.TempDir()
+	browser := &portstest.Browser{Colors: map[string]color.Color{
+		"http://feature/docs/intro/": color.RGBA{G: 255, A: 255},
+	}}
+	executor, vcs, server := newTestExecutor(t, browser)
+
+	cfg := DefaultConfig()
+	cfg.BaselineRev = "main"
+	cfg.CurrentRev = "feature"
+	cfg.BuildCommand = "mkdir dist"
+	cfg.SiteDir = "dist"
+	cfg.Pages = []string{"/", "/docs/intro/"}
+	cfg.OutputDir = dir
+	cfg.Record.WaitAfter = 0
+
+	results, err := executor.Execute(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("Execute() returned %d results, want 2", len(results))
+	}
+	if results[0].PixelDiffCount != 0 {
+		t.Errorf("PixelDiffCount of / = %d, want 0", results[0].PixelDiffCount)
+	}
+	if results[1].PixelDiffCount != 200 {
+		t.Errorf("PixelDiffCount of /docs/intro/ = %d, want 200", results[1].PixelDiffCount)
+	}
+
+	// Each revision is built, served from its site directory and cleaned up
+	if len(server.served) != 2 || server.closed != 2 {
+		t.Errorf("served %v and closed %d times, want both revisions served and closed", server.served, server.closed)
+	}
+	for _, served := range server.served {
+		if filepath.Base(served) != "dist" {
+			t.Errorf("served %s, want the dist directory", served)
+		}
+	}
+	if strings.Join(vcs.removed, ",") != "main,feature" {
+		t.Errorf("removed worktrees of %v, want main and feature", vcs.removed)
+	}
+
+	for _, path := range []string{
+		"baseline/index.png",
+		"baseline/docs_intro.png",
+		"current/index.png",
+		"current/docs_intro.png",
+		"diff/index.png",
+		"diff/docs_intro.png",
+		"diff/docs_intro.json",
+	} {
+		if _, err := os.Stat(filepath.Join(dir, path)); err != nil {
+			t.Errorf("%s was not written: %v", path, err)
+		}
+	}
+}
+
+func TestExecutor_Execute_MissingSiteDir(t *testing.T) {
+	executor, vcs, server := newTestExecutor(t, &portstest.Browser{})
+
+	cfg := DefaultConfig()
+	cfg.BaselineRev = "main"
+	cfg.CurrentRev = "feature"
+	cfg.SiteDir = "dist"
+	cfg.OutputDir = t.TempDir()
+
+	_, err := executor.Execute(context.Background(), cfg)
+	if err == nil || !strings.Contains(err.Error(), "site directory dist does not exist") {
+		t.Fatalf("Execute() error = %v, want a missing site directory error", err)
+	}
+	if len(server.served) != 0 {
+		t.Errorf("served %v, want nothing served", server.served)
+	}
+	if strings.Join(vcs.removed, ",") != "main" {
+		t.Errorf("removed worktrees of %v, want the failed main checkout removed", vcs.removed)
+	}
+}
+
+func TestExecutor_Execute_CollidingPages(t *testing.T) {
+	executor, vcs, _ := newTestExecutor(t, &portstest.Browser{})
+
+	cfg := DefaultConfig()
+	cfg.BaselineRev = "main"
+	cfg.CurrentRev = "feature"
+	cfg.Pages = []string{"/about", "/about/"}
+	cfg.OutputDir = t.TempDir()
+
+	_, err := executor.Execute(context.Background(), cfg)
+	if err == nil || !strings.Contains(err.Error(), `would both be saved as "about"`) {
+		t.Fatalf("Execute() error = %v, want a file name collision error", err)
+	}
+	if len(vcs.revs) != 0 {
+		t.Error("Execute() checked revisions out before rejecting the pages")
+	}
+}
+
+func TestPageFileName(t *testing.T) {
+	tests := []struct {
+		page string
+		want string
+	}{
+		{page: "/", want: "index"},
+		{page: "", want: "index"},
+		{page: "/about/", want: "about"},
+		{page: "/docs/intro/", want: "docs_intro"},
+		{page: "/blog/post.html", want: "blog_post.html"},
+		{page: "/search?q=test#top", want: "search"},
+		{page: "/a/b", want: "a_b"},
+		{page: "/a_b", want: "a%5Fb"},
+		{page: "/100%/", want: "100%25"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.page, func(t *testing.T) {
+			if got := PageFileName(tt.page); got != tt.want {
+				t.Errorf("PageFileName(%q) = %q, want %q", tt.page, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+// Package devserver starts a local development server command, waits for
+// its URL to answer and stops its process group again.
+package devserver
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
+// Options configures a managed development server.
+type Options struct {
+	// Command is the shell command line that starts the server.
+	Command string
+
+	// URL is polled until it answers, signalling the server is ready.
+	URL string
+
+	// Dir is the working directory for the command (empty = current directory).
+	Dir string
+
+	// Timeout is the maximum time to wait for URL to answer (default: 60s).
+	Timeout time.Duration
+
+	// StopTimeout is the grace period between the polite and the forced
+	// shutdown of the process group (default: 5s).
+	StopTimeout time.Duration
+}
+
+// Server is a running development server.
+type Server struct {
+	cmd         *exec.Cmd
+	logger      ports.Logger
+	stopTimeout time.Duration
+	exited      chan struct{}
+	waitErr     error
+	stopOnce    sync.Once
+}
+
+// Command returns an exec.Cmd that runs line through the platform shell.
+func Command(ctx context.Context, line string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", line)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", line)
+}
+
+// Start launches the server command and waits until opts.URL answers.
+// If the server exits or the timeout elapses first, the process group is
+// torn down and an error is returned.
+func Start(ctx context.Context, opts Options, logger ports.Logger) (*Server, error) {
+	if opts.Command == "" {
+		return nil, errors.New("serve command is empty")
+	}
+	if opts.URL == "" {
+		return nil, errors.New("serve URL is empty")
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 60 * time.Second
+	}
+	if opts.StopTimeout <= 0 {
+		opts.StopTimeout = 5 * time.Second
+	}
+
+	// The server must outlive ctx cancellation until Stop runs, so it is not
+	// bound to ctx; Stop is responsible for the shutdown.
+	cmd := Command(context.Background(), opts.Command)
+	cmd.Dir = opts.Dir
+	cmd.WaitDelay = opts.StopTimeout
+	setProcessGroup(cmd)
+
+	output := newLogWriter(logger)
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	logger.Info("Starting dev server: %s", opts.Command)
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start serve command: %w", err)
+	}
+
+	s := &Server{
+		cmd:         cmd,
+		logger:      logger,
+		stopTimeout: opts.StopTimeout,
+		exited:      make(chan struct{}),
+	}
+	go func() {
+		s.waitErr = cmd.Wait()
+		output.Close()
+		close(s.exited)
+	}()
+
+	logger.Info("Waiting for %s...", opts.URL)
+	waitCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	if err := s.waitReady(waitCtx, opts.URL); err != nil {
+		s.Stop()
+		return nil, err
+	}
+
+	logger.Debug("Dev server is ready at %s", opts.URL)
+	return s, nil
+}
+
+// waitReady polls url until it answers, the server exits or ctx is done.
+func (s *Server) waitReady(ctx context.Context, url string) error {
+	readyErr := make(chan error, 1)
+	go func() {
+		readyErr <- WaitForURL(ctx, url)
+	}()
+
+	select {
+	case err := <-readyErr:
+		if err != nil {
+			return fmt.Errorf("wait for %s: %w", url, err)
+		}
+		return nil
+	case <-s.exited:
+		if s.waitErr != nil {
+			return fmt.Errorf("serve command exited before %s answered: %w", url, s.waitErr)
+		}
+		return fmt.Errorf("serve command exited before %s answered", url)
+	}
+}
+
+// Stop terminates the server's process group. It asks politely first and
+// kills it after the stop timeout. Calling Stop more than once is safe.
+func (s *Server) Stop() error {
+	var err error
+	s.stopOnce.Do(func() {
+		select {
+		case <-s.exited:
+			return
+		default:
+		}
+
+		s.logger.Debug("Stopping dev server...")
+		if termErr := terminateProcessGroup(s.cmd); termErr != nil {
+			s.logger.Debug("Failed to signal dev server: %v", termErr)
+		}
+
+		select {
+		case <-s.exited:
+			return
+		case <-time.After(s.stopTimeout):
+		}
+
+		s.logger.Warn("Dev server did not exit within %s, killing it", s.stopTimeout)
+		if killErr := killProcessGroup(s.cmd); killErr != nil {
+			err = fmt.Errorf("kill serve command: %w", killErr)
+			return
+		}
+		<-s.exited
+	})
+	return err
+}
+
+// WaitForURL polls url until it returns any HTTP response below 500, or ctx
+// is done.
+func WaitForURL(ctx context.Context, url string) error {
+	client := &http.Client{Timeout: 2 * time.Second}
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err == nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode < 500 {
+				return nil
+			}
+			lastErr = fmt.Errorf("status %d", resp.StatusCode)
+		} else if ctx.Err() == nil {
+			lastErr = err
+		}
+
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return fmt.Errorf("%w (last error: %v)", ctx.Err(), lastErr)
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// logWriter forwards server output to the logger line by line.
+type logWriter struct {
+	pw   *io.PipeWriter
+	done chan struct{}
+}
+
+func newLogWriter(logger ports.Logger) *logWriter {
+	pr, pw := io.Pipe()
+	w := &logWriter{pw: pw, done: make(chan struct{})}
+	go func() {
+		defer close(w.done)
+		scanner := bufio.NewScanner(pr)
+		for scanner.Scan() {
+			logger.Debug("[serve] %s", scanner.Text())
+		}
+		io.Copy(io.Discard, pr)
+	}()
+	return w
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+// Close flushes the remaining output.
+func (w *logWriter) Close() error {
+	err := w.pw.Close()
+	<-w.done
+	return err
+}
@@ -0,0 +1,78 @@
+package devserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ideamans/static-webshot/pkg/adapters/logger"
+)
+
+func TestWaitForURL(t *testing.T) {
+	ready := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-ready:
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		close(ready)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := WaitForURL(ctx, server.URL); err != nil {
+		t.Fatalf("WaitForURL() error = %v", err)
+	}
+}
+
+func TestWaitForURL_Timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	err := WaitForURL(ctx, server.URL)
+	if err == nil {
+		t.Fatal("WaitForURL() expected error for a server that never becomes ready")
+	}
+	if !strings.Contains(err.Error(), "status 500") {
+		t.Errorf("WaitForURL() error = %v, want it to mention the last status", err)
+	}
+}
+
+func TestStart_CommandExitsEarly(t *testing.T) {
+	_, err := Start(context.Background(), Options{
+		Command: "exit 3",
+		URL:     "http://127.0.0.1:1/",
+		Timeout: 10 * time.Second,
+	}, logger.New())
+	if err == nil {
+		t.Fatal("Start() expected error when the serve command exits")
+	}
+	if !strings.Contains(err.Error(), "exited before") {
+		t.Errorf("Start() error = %v, want it to report the early exit", err)
+	}
+}
+
+func TestStart_MissingOptions(t *testing.T) {
+	if _, err := Start(context.Background(), Options{URL: "http://localhost"}, logger.New()); err == nil {
+		t.Error("Start() expected error for an empty command")
+	}
+	if _, err := Start(context.Background(), Options{Command: "true"}, logger.New()); err == nil {
+		t.Error("Start() expected error for an empty URL")
+	}
+}
@@ -0,0 +1,32 @@
+// Package diffurls provides the diff-urls command logic.
+package diffurls
+
+import (
+	"github.com/ideamans/static-webshot/pkg/compare"
+	"github.com/ideamans/static-webshot/pkg/record"
+)
+
+// Config holds configuration for the diff-urls command.
+type Config struct {
+	// BaselineURL is the page captured as the baseline.
+	BaselineURL string
+
+	// CurrentURL is the page captured as the current state.
+	CurrentURL string
+
+	// Record holds the capture settings applied to both URLs.
+	// URL and OutputPath are ignored.
+	Record record.Config
+
+	// Compare holds the comparison settings. OutputPath and the digest paths
+	// are used as is; BaselinePath and CurrentPath are set to the URLs.
+	Compare compare.Config
+}
+
+// DefaultConfig returns a Config with default values.
+func DefaultConfig() Config {
+	return Config{
+		Record:  record.DefaultConfig(),
+		Compare: compare.DefaultConfig(),
+	}
+}
@@ -0,0 +1,65 @@
+// Package diffurls provides the diff-urls command execution logic.
+package diffurls
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ideamans/static-webshot/pkg/compare"
+	"github.com/ideamans/static-webshot/pkg/ports"
+	"github.com/ideamans/static-webshot/pkg/record"
+)
+
+// Executor executes the diff-urls command.
+type Executor struct {
+	browser    ports.Browser
+	processor  ports.ImageProcessor
+	filesystem ports.FileSystem
+	logger     ports.Logger
+}
+
+// NewExecutor creates a new Executor with the given dependencies.
+func NewExecutor(browser ports.Browser, processor ports.ImageProcessor, filesystem ports.FileSystem, logger ports.Logger) *Executor {
+	return &Executor{
+		browser:    browser,
+		processor:  processor,
+		filesystem: filesystem,
+		logger:     logger,
+	}
+}
+
+// Execute captures both URLs in one browser session and compares the
+// screenshots in memory. Only the diff image and digests are written.
+func (e *Executor) Execute(ctx context.Context, cfg Config) (*compare.Result, error) {
+	recorder := record.NewExecutor(e.browser, e.filesystem, e.logger)
+	captures, err := recorder.CaptureURLs(ctx, cfg.Record, []string{cfg.BaselineURL, cfg.CurrentURL})
+	if err != nil {
+		return nil, fmt.Errorf("capture: %w", err)
+	}
+
+	baseline, err := e.processor.DecodeImage(captures[0].Screenshot)
+	if err != nil {
+		return nil, fmt.Errorf("decode baseline: %w", err)
+	}
+
+	current, err := e.processor.DecodeImage(captures[1].Screenshot)
+	if err != nil {
+		return nil, fmt.Errorf("decode current: %w", err)
+	}
+
+	compareCfg := cfg.Compare
+	compareCfg.BaselinePath = cfg.BaselineURL
+	compareCfg.CurrentPath = cfg.CurrentURL
+
+	// Masked areas are left out as compare does with the metadata sidecars
+	if compareCfg.IgnoreMasked {
+		compareCfg.IgnoreRegions = append(compareCfg.IgnoreRegions, compare.MaskedRegions(captures[0].Metadata)...)
+		compareCfg.CurrentIgnoreRegions = append(compareCfg.CurrentIgnoreRegions, compare.MaskedRegions(captures[1].Metadata)...)
+		if n := len(compareCfg.IgnoreRegions) + len(compareCfg.CurrentIgnoreRegions); n > 0 {
+			e.logger.Debug("Ignoring %d masked regions", n)
+		}
+	}
+
+	comparer := compare.NewExecutor(e.processor, e.filesystem, e.logger)
+	return comparer.CompareImages(ctx, compareCfg, baseline, current)
+}
@@ -0,0 +1,95 @@
+package diffurls
+
+import (
+	"context"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ideamans/static-webshot/internal/portstest"
+	"github.com/ideamans/static-webshot/pkg/adapters/logger"
+	"github.com/ideamans/static-webshot/pkg/adapters/osfilesystem"
+	"github.com/ideamans/static-webshot/pkg/adapters/pixelmatch"
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
+func TestExecutor_Execute(t *testing.T) {
+	dir := t.TempDir()
+	browser := &portstest.Browser{Colors: map[string]color.Color{
+		"https://prod.example.com":    color.RGBA{R: 255, A: 255},
+		"https://staging.example.com": color.RGBA{G: 255, A: 255},
+	}}
+
+	cfg := DefaultConfig()
+	cfg.BaselineURL = "https://prod.example.com"
+	cfg.CurrentURL = "https://staging.example.com"
+	cfg.Record.WaitAfter = 0
+	cfg.Compare.OutputPath = filepath.Join(dir, "diff.png")
+	cfg.Compare.DigestJSONPath = filepath.Join(dir, "result.json")
+
+	executor := NewExecutor(browser, pixelmatch.New(), osfilesystem.New(), logger.New())
+	result, err := executor.Execute(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	if browser.Launches != 1 {
+		t.Errorf("browser launched %d times, want 1", browser.Launches)
+	}
+	if result.PixelDiffCount != 200 {
+		t.Errorf("PixelDiffCount = %d, want 200", result.PixelDiffCount)
+	}
+	if result.BaselinePath != cfg.BaselineURL || result.CurrentPath != cfg.CurrentURL {
+		t.Errorf("result paths = %q, %q, want the URLs", result.BaselinePath, result.CurrentPath)
+	}
+
+	// Only the diff image and the digest may be written
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if len(names) != 2 {
+		t.Errorf("output directory contains %v, want only diff.png and result.json", names)
+	}
+}
+
+func TestExecutor_Execute_IgnoresMasks(t *testing.T) {
+	for _, tt := range []struct {
+		ignoreMasked bool
+		want         int
+	}{
+		{ignoreMasked: true, want: 100},
+		{ignoreMasked: false, want: 200},
+	} {
+		dir := t.TempDir()
+		browser := &portstest.Browser{
+			Colors: map[string]color.Color{
+				"https://prod.example.com":    color.RGBA{R: 255, A: 255},
+				"https://staging.example.com": color.RGBA{G: 255, A: 255},
+			},
+			Masked: []ports.MaskedRect{{Selector: ".ad", Style: ports.MaskBlackout, Width: 10, Height: 10}},
+		}
+
+		cfg := DefaultConfig()
+		cfg.BaselineURL = "https://prod.example.com"
+		cfg.CurrentURL = "https://staging.example.com"
+		cfg.Record.WaitAfter = 0
+		cfg.Record.Masks = []ports.Mask{{Selector: ".ad", Style: ports.MaskBlackout}}
+		cfg.Compare.OutputPath = filepath.Join(dir, "diff.png")
+		cfg.Compare.IgnoreMasked = tt.ignoreMasked
+
+		executor := NewExecutor(browser, pixelmatch.New(), osfilesystem.New(), logger.New())
+		result, err := executor.Execute(context.Background(), cfg)
+		if err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		if result.PixelDiffCount != tt.want {
+			t.Errorf("IgnoreMasked %v: PixelDiffCount = %d, want %d", tt.ignoreMasked, result.PixelDiffCount, tt.want)
+		}
+	}
+}
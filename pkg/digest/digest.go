@@ -0,0 +1,91 @@
+// Package digest provides the pieces shared by the commands that compare the
+// snapshots recorded next to screenshots (compare-layout, compare-a11y):
+// finding the snapshot of a screenshot, and printing and saving the digest.
+package digest
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
+// Result is a comparison result that renders as a digest.
+type Result interface {
+	ToText() string
+	ToJSON() (string, error)
+}
+
+// SnapshotPath returns the snapshot to read for path: path itself if it is a
+// JSON file, else the sidecar recorded next to the screenshot at path.
+// sidecarPath maps a screenshot to its sidecar; kind ("layout") and flag
+// ("--layout-snapshot") explain a missing sidecar.
+func SnapshotPath(fs ports.FileSystem, path string, sidecarPath func(string) string, kind, flag string) (string, error) {
+	if strings.HasSuffix(path, ".json") {
+		return path, nil
+	}
+	sidecar := sidecarPath(path)
+	if !fs.Exists(sidecar) {
+		return "", fmt.Errorf("no %s snapshot %s (capture with %s)", kind, sidecar, flag)
+	}
+	return sidecar, nil
+}
+
+// Write prints the text digest of the result to stdout and saves the text
+// and JSON digests to txtPath and jsonPath, when set.
+func Write(fs ports.FileSystem, result Result, txtPath, jsonPath string) error {
+	text := result.ToText()
+
+	// Output digest to stdout
+	fmt.Println(text)
+
+	// Save text digest to file if path is specified
+	if txtPath != "" {
+		if err := save(fs, txtPath, text+"\n"); err != nil {
+			return fmt.Errorf("save text digest: %w", err)
+		}
+	}
+
+	// Save JSON digest to file if path is specified
+	if jsonPath != "" {
+		jsonStr, err := result.ToJSON()
+		if err != nil {
+			return fmt.Errorf("marshal JSON digest: %w", err)
+		}
+		if err := save(fs, jsonPath, jsonStr+"\n"); err != nil {
+			return fmt.Errorf("save JSON digest: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// save saves content to a file, creating directories as needed.
+func save(fs ports.FileSystem, path, content string) error {
+	dir := filepath.Dir(path)
+	if err := fs.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create directory %s: %w", dir, err)
+	}
+
+	if err := fs.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("write file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Counts renders the numbers of changes of each kind, in the order of kinds,
+// as " (2 added, 1 moved)", or "" when there are none.
+func Counts(counts map[string]int, kinds []string) string {
+	var parts []string
+	for _, kind := range kinds {
+		if n := counts[kind]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, kind))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
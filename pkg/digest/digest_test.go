@@ -0,0 +1,64 @@
+package digest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ideamans/static-webshot/pkg/adapters/osfilesystem"
+)
+
+type fakeResult struct{}
+
+func (fakeResult) ToText() string          { return "[Result]" }
+func (fakeResult) ToJSON() (string, error) { return `{"ok":true}`, nil }
+
+func TestSnapshotPath(t *testing.T) {
+	fs := osfilesystem.New()
+	dir := t.TempDir()
+	sidecar := func(path string) string { return path + ".json" }
+
+	if got, err := SnapshotPath(fs, "home.layout.json", sidecar, "layout", "--layout-snapshot"); err != nil || got != "home.layout.json" {
+		t.Errorf("SnapshotPath(json) = %q, %v", got, err)
+	}
+
+	image := filepath.Join(dir, "home.png")
+	if _, err := SnapshotPath(fs, image, sidecar, "layout", "--layout-snapshot"); err == nil {
+		t.Error("SnapshotPath() without sidecar succeeded")
+	}
+	if err := os.WriteFile(image+".json", []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := SnapshotPath(fs, image, sidecar, "layout", "--layout-snapshot"); err != nil || got != image+".json" {
+		t.Errorf("SnapshotPath(image) = %q, %v", got, err)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	txtPath := filepath.Join(dir, "out", "digest.txt")
+	jsonPath := filepath.Join(dir, "out", "digest.json")
+
+	if err := Write(osfilesystem.New(), fakeResult{}, txtPath, jsonPath); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	for path, want := range map[string]string{txtPath: "[Result]\n", jsonPath: "{\"ok\":true}\n"} {
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(path), got, want)
+		}
+	}
+}
+
+func TestCounts(t *testing.T) {
+	kinds := []string{"added", "removed", "moved"}
+	if got := Counts(map[string]int{"moved": 1, "added": 2}, kinds); got != " (2 added, 1 moved)" {
+		t.Errorf("Counts() = %q", got)
+	}
+	if got := Counts(nil, kinds); got != "" {
+		t.Errorf("Counts(nil) = %q, want empty", got)
+	}
+}
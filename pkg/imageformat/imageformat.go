@@ -0,0 +1,126 @@
+// Package imageformat encodes and decodes the image formats captures and
+// diffs can be written in: PNG, JPEG and lossless WebP.
+package imageformat
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+	_ "golang.org/x/image/webp" // Register the WebP decoder
+
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
+// DefaultQuality is the JPEG quality used when none is given.
+const DefaultQuality = 90
+
+// FromPath returns the format implied by the extension of path, PNG if it is
+// not known.
+func FromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg":
+		return ports.FormatJPEG
+	case ".webp":
+		return ports.FormatWebP
+	default:
+		return ports.FormatPNG
+	}
+}
+
+// Extension returns the file extension for a format ("" = PNG), with the
+// leading dot.
+func Extension(format string) string {
+	switch format {
+	case ports.FormatJPEG, "jpg":
+		return ".jpg"
+	case ports.FormatWebP:
+		return ".webp"
+	default:
+		return ".png"
+	}
+}
+
+// Resolve validates opts and fills in the defaults, taking the format from
+// the extension of path when opts has none.
+func Resolve(opts ports.EncodeOptions, path string) (ports.EncodeOptions, error) {
+	switch opts.Format {
+	case "":
+		opts.Format = FromPath(path)
+	case ports.FormatPNG, ports.FormatJPEG, ports.FormatWebP:
+	case "jpg":
+		opts.Format = ports.FormatJPEG
+	default:
+		return opts, fmt.Errorf("invalid image format %q (want %s, %s or %s)",
+			opts.Format, ports.FormatPNG, ports.FormatJPEG, ports.FormatWebP)
+	}
+
+	if opts.Quality == 0 {
+		opts.Quality = DefaultQuality
+	}
+	if opts.Quality < 1 || opts.Quality > 100 {
+		return opts, fmt.Errorf("invalid JPEG quality %d (want 1-100)", opts.Quality)
+	}
+
+	switch opts.Compression {
+	case "":
+		opts.Compression = ports.CompressionDefault
+	case ports.CompressionDefault, ports.CompressionSpeed, ports.CompressionBest, ports.CompressionNone:
+	default:
+		return opts, fmt.Errorf("invalid PNG compression %q (want %s, %s, %s or %s)", opts.Compression,
+			ports.CompressionDefault, ports.CompressionSpeed, ports.CompressionBest, ports.CompressionNone)
+	}
+
+	return opts, nil
+}
+
+// Encode writes img to w in the format of opts, which must have been
+// resolved.
+func Encode(w io.Writer, img image.Image, opts ports.EncodeOptions) error {
+	switch opts.Format {
+	case ports.FormatJPEG:
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: opts.Quality})
+	case ports.FormatWebP:
+		return nativewebp.Encode(w, img, nil)
+	default:
+		encoder := png.Encoder{CompressionLevel: compressionLevel(opts.Compression)}
+		return encoder.Encode(w, img)
+	}
+}
+
+// Convert re-encodes PNG data in the format of opts, which must have been
+// resolved. PNG data with the default compression is returned as is.
+func Convert(data []byte, opts ports.EncodeOptions) ([]byte, error) {
+	if opts.Format == ports.FormatPNG && opts.Compression == ports.CompressionDefault {
+		return data, nil
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode PNG: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, img, opts); err != nil {
+		return nil, fmt.Errorf("encode %s: %w", opts.Format, err)
+	}
+	return buf.Bytes(), nil
+}
+
+func compressionLevel(compression string) png.CompressionLevel {
+	switch compression {
+	case ports.CompressionSpeed:
+		return png.BestSpeed
+	case ports.CompressionBest:
+		return png.BestCompression
+	case ports.CompressionNone:
+		return png.NoCompression
+	default:
+		return png.DefaultCompression
+	}
+}
@@ -0,0 +1,129 @@
+package imageformat
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
+func TestFromPath(t *testing.T) {
+	tests := map[string]string{
+		"shot.png":      ports.FormatPNG,
+		"shot.JPG":      ports.FormatJPEG,
+		"shot.jpeg":     ports.FormatJPEG,
+		"out/diff.webp": ports.FormatWebP,
+		"shot":          ports.FormatPNG,
+	}
+	for path, want := range tests {
+		if got := FromPath(path); got != want {
+			t.Errorf("FromPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	got, err := Resolve(ports.EncodeOptions{}, "diff.webp")
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	want := ports.EncodeOptions{Format: ports.FormatWebP, Quality: DefaultQuality, Compression: ports.CompressionDefault}
+	if got != want {
+		t.Errorf("Resolve() = %+v, want %+v", got, want)
+	}
+
+	// An explicit format wins over the extension
+	got, err = Resolve(ports.EncodeOptions{Format: "jpg", Quality: 70}, "diff.png")
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if got.Format != ports.FormatJPEG || got.Quality != 70 {
+		t.Errorf("Resolve() = %+v, want jpeg at quality 70", got)
+	}
+
+	for _, opts := range []ports.EncodeOptions{
+		{Format: "avif"},
+		{Quality: 101},
+		{Compression: "max"},
+	} {
+		if _, err := Resolve(opts, "shot.png"); err == nil {
+			t.Errorf("Resolve(%+v) succeeded, want an error", opts)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 16; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 16), uint8(y * 32), 128, 255})
+		}
+	}
+
+	for _, format := range []string{ports.FormatPNG, ports.FormatJPEG, ports.FormatWebP} {
+		t.Run(format, func(t *testing.T) {
+			opts, err := Resolve(ports.EncodeOptions{Format: format}, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := Encode(&buf, img, opts); err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+
+			decoded, name, err := image.Decode(&buf)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if name != format {
+				t.Errorf("decoded format = %q, want %q", name, format)
+			}
+			if decoded.Bounds() != img.Bounds() {
+				t.Errorf("bounds = %v, want %v", decoded.Bounds(), img.Bounds())
+			}
+
+			// PNG and WebP are lossless
+			if format == ports.FormatJPEG {
+				return
+			}
+			for y := 0; y < 8; y++ {
+				for x := 0; x < 16; x++ {
+					r1, g1, b1, a1 := img.At(x, y).RGBA()
+					r2, g2, b2, a2 := decoded.At(x, y).RGBA()
+					if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+						t.Fatalf("pixel (%d,%d) differs after round trip", x, y)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	opts, _ := Resolve(ports.EncodeOptions{}, "shot.png")
+	got, err := Convert(data, opts)
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("Convert() re-encoded PNG with the default compression")
+	}
+
+	opts, _ = Resolve(ports.EncodeOptions{}, "shot.webp")
+	got, err = Convert(data, opts)
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	if _, name, err := image.DecodeConfig(bytes.NewReader(got)); err != nil || name != ports.FormatWebP {
+		t.Errorf("Convert() to WebP decodes as %q, %v", name, err)
+	}
+}
@@ -0,0 +1,119 @@
+// Package imagehash fingerprints decoded images with a perceptual difference
+// hash (dHash) to group images that look alike, and tells pixel-identical
+// images apart without comparing them pixel by pixel.
+package imagehash
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"math/bits"
+)
+
+// The dHash grid: each row of cells yields dhashCols-1 bits from comparing
+// horizontally adjacent cells.
+const (
+	dhashCols = 9
+	dhashRows = 8
+)
+
+// DHash computes the difference hash of img in a single pass over its
+// pixels. Images that look alike have hashes a few bits apart.
+func DHash(img image.Image) uint64 {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	// Grid column of each pixel column, and the width of each grid column
+	cellX := make([]int, width)
+	var colWidth [dhashCols]uint64
+	for x := range cellX {
+		cellX[x] = x * dhashCols / width
+		colWidth[cellX[x]]++
+	}
+
+	rows := newRowReader(img)
+	var luma [dhashRows][dhashCols]uint64
+	for y := 0; y < height; y++ {
+		pix := rows.row(y)
+		cells := &luma[y*dhashRows/height]
+		for x, c := range cellX {
+			p := pix[x*4 : x*4+3 : x*4+3]
+			cells[c] += 299*uint64(p[0]) + 587*uint64(p[1]) + 114*uint64(p[2])
+		}
+	}
+
+	// A bit is set where a cell is brighter on average than the cell to its
+	// right. Cells of a row have the same height, so their sums are
+	// compared weighted by the other's width.
+	var dhash uint64
+	for r := 0; r < dhashRows; r++ {
+		for c := 0; c < dhashCols-1; c++ {
+			dhash <<= 1
+			if luma[r][c]*colWidth[c+1] > luma[r][c+1]*colWidth[c] {
+				dhash |= 1
+			}
+		}
+	}
+	return dhash
+}
+
+// Equal reports whether a and b have the same size and the same pixels as
+// premultiplied RGBA, however they were decoded. It stops at the first
+// differing row.
+func Equal(a, b image.Image) bool {
+	if a == b {
+		return true
+	}
+	if a.Bounds().Size() != b.Bounds().Size() {
+		return false
+	}
+	rowsA, rowsB := newRowReader(a), newRowReader(b)
+	for y := 0; y < a.Bounds().Dy(); y++ {
+		if !bytes.Equal(rowsA.row(y), rowsB.row(y)) {
+			return false
+		}
+	}
+	return true
+}
+
+// rowReader reads the rows of an image as premultiplied RGBA. Rows of other
+// image types are converted into a reused buffer.
+type rowReader struct {
+	img    image.Image
+	rgba   *image.RGBA
+	buffer *image.RGBA
+}
+
+func newRowReader(img image.Image) *rowReader {
+	r := &rowReader{img: img}
+	if rgba, ok := img.(*image.RGBA); ok {
+		r.rgba = rgba
+	} else {
+		r.buffer = image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), 1))
+	}
+	return r
+}
+
+// row returns row y, counted from the top of the image. The slice is only
+// valid until the next call.
+func (r *rowReader) row(y int) []uint8 {
+	bounds := r.img.Bounds()
+	if r.rgba != nil {
+		i := r.rgba.PixOffset(bounds.Min.X, bounds.Min.Y+y)
+		return r.rgba.Pix[i : i+bounds.Dx()*4]
+	}
+	draw.Draw(r.buffer, r.buffer.Rect, r.img, image.Pt(bounds.Min.X, bounds.Min.Y+y), draw.Src)
+	return r.buffer.Pix
+}
+
+// Hex formats a dHash as 16 hexadecimal digits.
+func Hex(dhash uint64) string {
+	return fmt.Sprintf("%016x", dhash)
+}
+
+// Distance returns the number of bits two dHashes differ in, 0 (alike) to
+// 64.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
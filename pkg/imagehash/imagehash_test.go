@@ -0,0 +1,79 @@
+package imagehash
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// gradient returns a w x h image getting darker from left to right.
+func gradient(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8(255 - x*255/w)
+			img.SetNRGBA(x, y, color.NRGBA{v, v, v, 255})
+		}
+	}
+	return img
+}
+
+func TestEqual(t *testing.T) {
+	nrgba := gradient(90, 40)
+
+	// The same pixels in another image type are equal
+	rgba := image.NewRGBA(nrgba.Bounds())
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 90; x++ {
+			rgba.Set(x, y, nrgba.At(x, y))
+		}
+	}
+	if !Equal(nrgba, rgba) {
+		t.Error("Equal() = false for the same pixels as NRGBA and RGBA")
+	}
+
+	changed := gradient(90, 40)
+	changed.SetNRGBA(45, 20, color.NRGBA{255, 0, 0, 255})
+	if Equal(nrgba, changed) {
+		t.Error("Equal() = true for images differing in one pixel")
+	}
+
+	// As many blank pixels in another shape
+	wide := image.NewRGBA(image.Rect(0, 0, 90, 40))
+	tall := image.NewRGBA(image.Rect(0, 0, 40, 90))
+	if Equal(wide, tall) {
+		t.Error("Equal() = true for blank images of different sizes")
+	}
+}
+
+func TestDHash(t *testing.T) {
+	img := gradient(90, 40)
+	if got := Hex(DHash(img)); got != "ffffffffffffffff" {
+		t.Errorf("DHash() of a darkening gradient = %s, want ffffffffffffffff", got)
+	}
+
+	// A small change keeps the hash close
+	changed := gradient(90, 40)
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 3; x++ {
+			changed.SetNRGBA(40+x, 20+y, color.NRGBA{0, 0, 0, 255})
+		}
+	}
+	if d := Distance(DHash(img), DHash(changed)); d > 4 {
+		t.Errorf("Distance() after a small change = %d, want at most 4", d)
+	}
+
+	// Blank images have no brighter cells
+	if got := DHash(image.NewRGBA(image.Rect(0, 0, 5, 5))); got != 0 {
+		t.Errorf("DHash() of a blank image smaller than the grid = %x, want 0", got)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	if got := Distance(0, ^uint64(0)); got != 64 {
+		t.Errorf("Distance(0, all ones) = %d, want 64", got)
+	}
+	if got := Distance(0xf0, 0xf1); got != 1 {
+		t.Errorf("Distance(0xf0, 0xf1) = %d, want 1", got)
+	}
+}
@@ -0,0 +1,340 @@
+package layout
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strings"
+)
+
+// Kinds of element changes.
+const (
+	KindAdded    = "added"
+	KindRemoved  = "removed"
+	KindMoved    = "moved"
+	KindResized  = "resized"
+	KindRestyled = "restyled"
+	KindText     = "text"
+)
+
+// Tolerance is how far an element may move or resize, in CSS pixels,
+// before it counts as changed.
+type Tolerance struct {
+	Position float64
+	Size     float64
+}
+
+// DefaultTolerance ignores sub-pixel jitter but catches 1px shifts.
+var DefaultTolerance = Tolerance{Position: 0.5, Size: 0.5}
+
+// Change is how an element differs between the baseline and current
+// snapshots.
+type Change struct {
+	Path        string   `json:"path"`
+	Label       string   `json:"label"`
+	Kinds       []string `json:"kinds"`
+	Baseline    *Element `json:"baseline,omitempty"`
+	Current     *Element `json:"current,omitempty"`
+	Description string   `json:"description"`
+}
+
+// Has reports whether the change is of the given kind.
+func (c *Change) Has(kind string) bool {
+	for _, k := range c.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// CompareElements returns how an element changed, or nil if it did not. A
+// nil baseline means the element was added, a nil current that it was
+// removed.
+func CompareElements(baseline, current *Element, tol Tolerance) *Change {
+	switch {
+	case baseline == nil && current == nil:
+		return nil
+	case baseline == nil:
+		return newChange(current.Path, current.Label, nil, current, []string{KindAdded}, []string{"was added"})
+	case current == nil:
+		return newChange(baseline.Path, baseline.Label, baseline, nil, []string{KindRemoved}, []string{"was removed"})
+	}
+
+	var kinds, details []string
+	dx, dy := current.X-baseline.X, current.Y-baseline.Y
+	if math.Abs(dx) > tol.Position || math.Abs(dy) > tol.Position {
+		kinds = append(kinds, KindMoved)
+		details = append(details, "moved "+describeMove(dx, dy))
+	}
+	if math.Abs(current.Width-baseline.Width) > tol.Size || math.Abs(current.Height-baseline.Height) > tol.Size {
+		kinds = append(kinds, KindResized)
+		details = append(details, fmt.Sprintf("resized from %s to %s", size(baseline), size(current)))
+	}
+
+	restyled := false
+	for _, style := range []struct {
+		name          string
+		before, after string
+	}{
+		{"display", baseline.Display, current.Display},
+		{"font family", baseline.FontFamily, current.FontFamily},
+		{"font size", baseline.FontSize, current.FontSize},
+		{"font weight", baseline.FontWeight, current.FontWeight},
+		{"color", baseline.Color, current.Color},
+		{"background color", baseline.BackgroundColor, current.BackgroundColor},
+	} {
+		if style.before != style.after {
+			restyled = true
+			details = append(details, fmt.Sprintf("changed %s from %s to %s", style.name, style.before, style.after))
+		}
+	}
+	if restyled {
+		kinds = append(kinds, KindRestyled)
+	}
+
+	if baseline.Text != current.Text {
+		kinds = append(kinds, KindText)
+		details = append(details, fmt.Sprintf("changed text from %s to %s", quote(baseline.Text), quote(current.Text)))
+	}
+
+	if len(kinds) == 0 {
+		return nil
+	}
+	return newChange(current.Path, current.Label, baseline, current, kinds, details)
+}
+
+func newChange(path, label string, baseline, current *Element, kinds, details []string) *Change {
+	return &Change{
+		Path:        path,
+		Label:       label,
+		Kinds:       kinds,
+		Baseline:    baseline,
+		Current:     current,
+		Description: label + " " + joinDetails(details),
+	}
+}
+
+// Region is an area of the screenshot in image pixels.
+type Region struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Attribution explains a region of differing pixels with the changes of the
+// elements that occupy it.
+type Attribution struct {
+	Region  Region   `json:"region"`
+	Changes []Change `json:"changes,omitempty"`
+}
+
+// Diff returns the changes of all elements between two snapshots: those of
+// the baseline in document order, then the added ones. Changes that follow
+// from a changed ancestor are left out: the descendants of an added or
+// removed element, and descendants that only moved along with it.
+//
+// An element missing from a truncated snapshot may just lie past its cap, so
+// no removals are reported against a truncated current snapshot and no
+// additions against a truncated baseline.
+func Diff(baseline, current *Snapshot, tol Tolerance) []Change {
+	currentIndex := current.Index()
+	baselineIndex := baseline.Index()
+
+	var changes []Change
+	for i := range baseline.Elements {
+		el := &baseline.Elements[i]
+		if change := compareIn(baseline, current, el, currentIndex[el.Path], tol); change != nil {
+			changes = append(changes, *change)
+		}
+	}
+	for i := range current.Elements {
+		el := &current.Elements[i]
+		if baselineIndex[el.Path] != nil {
+			continue
+		}
+		if change := compareIn(baseline, current, nil, el, tol); change != nil {
+			changes = append(changes, *change)
+		}
+	}
+	return collapse(changes, baselineIndex, currentIndex)
+}
+
+// compareIn compares the elements like CompareElements, but does not report
+// an element as added or removed when the snapshot it is missing from was
+// truncated.
+func compareIn(baselineSnapshot, currentSnapshot *Snapshot, baseline, current *Element, tol Tolerance) *Change {
+	if (baseline == nil && baselineSnapshot.Truncated) || (current == nil && currentSnapshot.Truncated) {
+		return nil
+	}
+	return CompareElements(baseline, current, tol)
+}
+
+// Count returns the number of changes of each kind.
+func Count(changes []Change) map[string]int {
+	counts := make(map[string]int)
+	for _, change := range changes {
+		for _, kind := range change.Kinds {
+			counts[kind]++
+		}
+	}
+	return counts
+}
+
+// Changes returns the changes of the attributions, each element once, in
+// order of appearance.
+func Changes(attributions []Attribution) []Change {
+	seen := make(map[string]bool)
+	var changes []Change
+	for _, a := range attributions {
+		for _, change := range a.Changes {
+			if !seen[change.Path] {
+				seen[change.Path] = true
+				changes = append(changes, change)
+			}
+		}
+	}
+	return changes
+}
+
+// Attribute finds the element changes behind each region of differing
+// pixels, given in image pixels. Changes that follow from a changed ancestor
+// are left out, and so are additions and removals against a truncated
+// snapshot (see Diff).
+func Attribute(regions []image.Rectangle, baseline, current *Snapshot, tol Tolerance) []Attribution {
+	baselineIndex := baseline.Index()
+	currentIndex := current.Index()
+
+	attributions := make([]Attribution, 0, len(regions))
+	for _, r := range regions {
+		seen := make(map[string]bool)
+		var changes []Change
+		for _, candidates := range [][]*Element{baseline.ElementsIn(r), current.ElementsIn(r)} {
+			for _, el := range candidates {
+				if seen[el.Path] {
+					continue
+				}
+				seen[el.Path] = true
+				if change := compareIn(baseline, current, baselineIndex[el.Path], currentIndex[el.Path], tol); change != nil {
+					changes = append(changes, *change)
+				}
+			}
+		}
+		attributions = append(attributions, Attribution{
+			Region:  Region{X: r.Min.X, Y: r.Min.Y, Width: r.Dx(), Height: r.Dy()},
+			Changes: collapse(changes, baselineIndex, currentIndex),
+		})
+	}
+	return attributions
+}
+
+// collapse drops the changes that follow from a changed ancestor: elements
+// added or removed with their ancestor, and elements that only moved by the
+// same distance as their ancestor. Ancestors are found through the parent
+// links of the snapshots, so that a descendant path rerooted at an id
+// ("#main > p") still collapses into a changed "body > div".
+func collapse(changes []Change, baseline, current map[string]*Element) []Change {
+	kept := make([]Change, 0, len(changes))
+	keptByPath := make(map[string]Change, len(changes))
+	for _, change := range changes {
+		if !followsAncestor(change, keptByPath, baseline, current) {
+			kept = append(kept, change)
+			keptByPath[change.Path] = change
+		}
+	}
+	return kept
+}
+
+func followsAncestor(c Change, kept map[string]Change, baseline, current map[string]*Element) bool {
+	// Walk the ancestors in the snapshot the element is in
+	index, el := current, c.Current
+	if el == nil {
+		index, el = baseline, c.Baseline
+	}
+	for depth := 0; depth < len(index); depth++ {
+		parent := parentPath(el)
+		if parent == "" {
+			return false
+		}
+		if ancestor, ok := kept[parent]; ok {
+			switch {
+			case c.Has(KindAdded) && ancestor.Has(KindAdded),
+				c.Has(KindRemoved) && ancestor.Has(KindRemoved):
+				return true
+			case onlyMoved(c) && ancestor.Has(KindMoved) && moveOf(ancestor) == moveOf(c):
+				return true
+			}
+		}
+		if el = index[parent]; el == nil {
+			return false
+		}
+	}
+	return false
+}
+
+// parentPath returns the path of the element's parent: its parent link, or
+// for snapshots recorded without links, its path up to the last segment.
+func parentPath(el *Element) string {
+	if el.Parent != "" {
+		return el.Parent
+	}
+	if i := strings.LastIndex(el.Path, " > "); i >= 0 {
+		return el.Path[:i]
+	}
+	return ""
+}
+
+func onlyMoved(c Change) bool {
+	return len(c.Kinds) == 1 && c.Kinds[0] == KindMoved
+}
+
+func moveOf(c Change) [2]float64 {
+	return [2]float64{math.Round(c.Current.X - c.Baseline.X), math.Round(c.Current.Y - c.Baseline.Y)}
+}
+
+// describeMove renders a move as "12px down" or "3px left and 12px down".
+func describeMove(dx, dy float64) string {
+	var parts []string
+	if dx != 0 {
+		direction := "right"
+		if dx < 0 {
+			direction = "left"
+		}
+		parts = append(parts, fmt.Sprintf("%spx %s", formatPixels(math.Abs(dx)), direction))
+	}
+	if dy != 0 {
+		direction := "down"
+		if dy < 0 {
+			direction = "up"
+		}
+		parts = append(parts, fmt.Sprintf("%spx %s", formatPixels(math.Abs(dy)), direction))
+	}
+	return strings.Join(parts, " and ")
+}
+
+func size(el *Element) string {
+	return formatPixels(el.Width) + "x" + formatPixels(el.Height)
+}
+
+// formatPixels formats a CSS pixel value with at most one decimal.
+func formatPixels(v float64) string {
+	return fmt.Sprintf("%g", math.Round(v*10)/10)
+}
+
+// quote quotes text for a description, shortening long text.
+func quote(text string) string {
+	const maxRunes = 40
+	if runes := []rune(text); len(runes) > maxRunes {
+		text = string(runes[:maxRunes]) + "…"
+	}
+	return fmt.Sprintf("%q", text)
+}
+
+// joinDetails joins details as "a, b and c".
+func joinDetails(details []string) string {
+	if len(details) <= 1 {
+		return strings.Join(details, "")
+	}
+	return strings.Join(details[:len(details)-1], ", ") + " and " + details[len(details)-1]
+}
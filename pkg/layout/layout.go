@@ -0,0 +1,157 @@
+// Package layout records the visible elements of a captured page as a JSON
+// sidecar and compares them between captures.
+package layout
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
+// Snapshot is the layout of a captured page.
+type Snapshot struct {
+	// URL is the URL of the captured page.
+	URL string `json:"url"`
+
+	// ScaleX and ScaleY are the image pixels per CSS pixel of the
+	// screenshot, after the device scale factor and any resize.
+	ScaleX float64 `json:"scaleX"`
+	ScaleY float64 `json:"scaleY"`
+
+	// Elements are the visible elements in document order.
+	Elements []Element `json:"elements"`
+
+	// Truncated is set when the page had more visible elements than the
+	// snapshot records. Elements then holds the first ones in document
+	// order, and Total is the number of visible elements on the page.
+	Truncated bool `json:"truncated,omitempty"`
+	Total     int  `json:"total,omitempty"`
+}
+
+// Element is a visible element of the page. Positions and sizes are in CSS
+// pixels relative to the top-left corner of the document.
+type Element struct {
+	// Path locates the element from the body by tag and position among
+	// siblings of the same tag, or from the closest ancestor with an id. It
+	// identifies the element across captures.
+	Path string `json:"path"`
+
+	// Parent is the path of the closest ancestor in the snapshot, or empty
+	// for a top-level element.
+	Parent string `json:"parent,omitempty"`
+
+	// Label is a short, readable name of the element ("span.price").
+	Label string `json:"label"`
+
+	Tag    string  `json:"tag"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+
+	// Computed styles
+	Display         string `json:"display"`
+	FontFamily      string `json:"fontFamily"`
+	FontSize        string `json:"fontSize"`
+	FontWeight      string `json:"fontWeight"`
+	Color           string `json:"color"`
+	BackgroundColor string `json:"backgroundColor"`
+
+	// Text is the element's own text, without that of its child elements.
+	Text string `json:"text,omitempty"`
+}
+
+// SidecarPath returns the layout snapshot path for an image path
+// ("shots/home.png" -> "shots/home.layout.json").
+func SidecarPath(imagePath string) string {
+	return strings.TrimSuffix(imagePath, filepath.Ext(imagePath)) + ".layout.json"
+}
+
+// Write saves the snapshot as JSON to path.
+func Write(fs ports.FileSystem, path string, s *Snapshot) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal layout: %w", err)
+	}
+	return fs.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Read loads a snapshot written by Write.
+func Read(fs ports.FileSystem, path string) (*Snapshot, error) {
+	data, err := fs.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parse layout %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// Load returns the layout snapshot next to the image at imagePath, or nil if
+// there is none.
+func Load(fs ports.FileSystem, imagePath string) (*Snapshot, error) {
+	path := SidecarPath(imagePath)
+	if !fs.Exists(path) {
+		return nil, nil
+	}
+	return Read(fs, path)
+}
+
+// Index maps the element paths of the snapshot to their elements.
+func (s *Snapshot) Index() map[string]*Element {
+	index := make(map[string]*Element, len(s.Elements))
+	for i := range s.Elements {
+		index[s.Elements[i].Path] = &s.Elements[i]
+	}
+	return index
+}
+
+// Scaled returns a copy of the snapshot for a screenshot stretched by the
+// given factors.
+func (s *Snapshot) Scaled(scaleX, scaleY float64) *Snapshot {
+	scaled := *s
+	scaled.ScaleX, scaled.ScaleY = s.scale()
+	scaled.ScaleX *= scaleX
+	scaled.ScaleY *= scaleY
+	return &scaled
+}
+
+// scale returns the image pixels per CSS pixel, 1 when not recorded.
+func (s *Snapshot) scale() (float64, float64) {
+	scaleX, scaleY := s.ScaleX, s.ScaleY
+	if scaleX <= 0 {
+		scaleX = 1
+	}
+	if scaleY <= 0 {
+		scaleY = 1
+	}
+	return scaleX, scaleY
+}
+
+// ElementsIn returns the elements overlapping an area of the screenshot,
+// given in image pixels, in document order.
+func (s *Snapshot) ElementsIn(area image.Rectangle) []*Element {
+	scaleX, scaleY := s.scale()
+
+	var elements []*Element
+	for i := range s.Elements {
+		el := &s.Elements[i]
+		r := image.Rect(
+			int(math.Floor(el.X*scaleX)),
+			int(math.Floor(el.Y*scaleY)),
+			int(math.Ceil((el.X+el.Width)*scaleX)),
+			int(math.Ceil((el.Y+el.Height)*scaleY)),
+		)
+		if r.Overlaps(area) {
+			elements = append(elements, el)
+		}
+	}
+	return elements
+}
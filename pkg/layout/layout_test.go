@@ -0,0 +1,217 @@
+package layout
+
+import (
+	"image"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ideamans/static-webshot/pkg/adapters/osfilesystem"
+)
+
+func TestSidecarPath(t *testing.T) {
+	if got := SidecarPath(filepath.Join("shots", "home.png")); got != filepath.Join("shots", "home.layout.json") {
+		t.Errorf("SidecarPath() = %q", got)
+	}
+}
+
+func TestWriteLoad(t *testing.T) {
+	fs := osfilesystem.New()
+	imagePath := filepath.Join(t.TempDir(), "home.png")
+
+	got, err := Load(fs, imagePath)
+	if err != nil || got != nil {
+		t.Fatalf("Load() without snapshot = %v, %v, want nil, nil", got, err)
+	}
+
+	want := &Snapshot{
+		URL:    "https://example.com/",
+		ScaleX: 2,
+		ScaleY: 2,
+		Elements: []Element{
+			{Path: "body > h1", Label: "h1", Tag: "h1", X: 8, Y: 8, Width: 200, Height: 40, Color: "rgb(0, 0, 0)", Text: "Hello"},
+		},
+	}
+	if err := Write(fs, SidecarPath(imagePath), want); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	got, err = Load(fs, imagePath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Load() = %+v, want %+v", got, want)
+	}
+}
+
+func TestElementsIn(t *testing.T) {
+	s := &Snapshot{
+		ScaleX: 2,
+		ScaleY: 2,
+		Elements: []Element{
+			{Path: "a", X: 0, Y: 0, Width: 10, Height: 10},
+			{Path: "b", X: 20, Y: 0, Width: 10, Height: 10},
+		},
+	}
+
+	var paths []string
+	for _, el := range s.ElementsIn(image.Rect(30, 0, 50, 10)) {
+		paths = append(paths, el.Path)
+	}
+	if want := []string{"b"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("ElementsIn() = %v, want %v", paths, want)
+	}
+}
+
+func TestCompareElements(t *testing.T) {
+	base := Element{Path: "body > span", Label: "span.price", X: 10, Y: 20, Width: 50, Height: 16, Color: "rgb(0, 0, 0)", Text: "$10"}
+	tol := Tolerance{Position: 1, Size: 1}
+
+	tests := []struct {
+		name      string
+		baseline  *Element
+		current   func(Element) *Element
+		wantKinds []string
+		wantDesc  string
+	}{
+		{
+			name:     "unchanged within tolerance",
+			baseline: &base,
+			current:  func(e Element) *Element { e.X += 0.5; e.Width += 1; return &e },
+		},
+		{
+			name:      "moved and restyled",
+			baseline:  &base,
+			current:   func(e Element) *Element { e.Y += 12; e.Color = "rgb(255, 0, 0)"; return &e },
+			wantKinds: []string{KindMoved, KindRestyled},
+			wantDesc:  "span.price moved 12px down and changed color from rgb(0, 0, 0) to rgb(255, 0, 0)",
+		},
+		{
+			name:      "resized and text",
+			baseline:  &base,
+			current:   func(e Element) *Element { e.Width = 60; e.X -= 3; e.Text = "$12"; return &e },
+			wantKinds: []string{KindMoved, KindResized, KindText},
+			wantDesc:  `span.price moved 3px left, resized from 50x16 to 60x16 and changed text from "$10" to "$12"`,
+		},
+		{
+			name:      "added",
+			current:   func(e Element) *Element { return &e },
+			wantKinds: []string{KindAdded},
+			wantDesc:  "span.price was added",
+		},
+		{
+			name:      "removed",
+			baseline:  &base,
+			current:   func(Element) *Element { return nil },
+			wantKinds: []string{KindRemoved},
+			wantDesc:  "span.price was removed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			change := CompareElements(tt.baseline, tt.current(base), tol)
+			if tt.wantKinds == nil {
+				if change != nil {
+					t.Fatalf("CompareElements() = %+v, want nil", change)
+				}
+				return
+			}
+			if change == nil {
+				t.Fatal("CompareElements() = nil")
+			}
+			if !reflect.DeepEqual(change.Kinds, tt.wantKinds) {
+				t.Errorf("Kinds = %v, want %v", change.Kinds, tt.wantKinds)
+			}
+			if change.Description != tt.wantDesc {
+				t.Errorf("Description = %q, want %q", change.Description, tt.wantDesc)
+			}
+		})
+	}
+}
+
+func TestAttribute(t *testing.T) {
+	baseline := &Snapshot{ScaleX: 1, ScaleY: 1, Elements: []Element{
+		{Path: "body > header", Label: "header", X: 0, Y: 0, Width: 100, Height: 20},
+		{Path: "body > main", Label: "main", X: 0, Y: 20, Width: 100, Height: 50},
+		{Path: "body > main > h1", Label: "h1", X: 0, Y: 20, Width: 100, Height: 20},
+		{Path: "body > main > p", Label: "p", X: 0, Y: 40, Width: 100, Height: 20, Text: "old"},
+	}}
+	// The header grew and pushed main and its children down; the paragraph
+	// also changed its text
+	current := &Snapshot{ScaleX: 1, ScaleY: 1, Elements: []Element{
+		{Path: "body > header", Label: "header", X: 0, Y: 0, Width: 100, Height: 30},
+		{Path: "body > main", Label: "main", X: 0, Y: 30, Width: 100, Height: 50},
+		{Path: "body > main > h1", Label: "h1", X: 0, Y: 30, Width: 100, Height: 20},
+		{Path: "body > main > p", Label: "p", X: 0, Y: 50, Width: 100, Height: 20, Text: "new"},
+	}}
+
+	attributions := Attribute([]image.Rectangle{image.Rect(0, 15, 100, 75)}, baseline, current, Tolerance{})
+	if len(attributions) != 1 {
+		t.Fatalf("len(Attribute()) = %d, want 1", len(attributions))
+	}
+	if want := (Region{X: 0, Y: 15, Width: 100, Height: 60}); attributions[0].Region != want {
+		t.Errorf("Region = %+v, want %+v", attributions[0].Region, want)
+	}
+
+	var paths []string
+	for _, change := range Changes(attributions) {
+		paths = append(paths, change.Path)
+	}
+	want := []string{"body > header", "body > main", "body > main > p"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("changed paths = %v, want %v", paths, want)
+	}
+}
+
+func TestDiff_Truncated(t *testing.T) {
+	baseline := &Snapshot{Elements: []Element{
+		{Path: "body > h1", Label: "h1"},
+		{Path: "body > p", Label: "p"},
+	}}
+	current := &Snapshot{Elements: []Element{
+		{Path: "body > h1", Label: "h1"},
+		{Path: "body > footer", Label: "footer"},
+	}}
+
+	paths := func(changes []Change) []string {
+		var paths []string
+		for _, change := range changes {
+			paths = append(paths, change.Path)
+		}
+		return paths
+	}
+
+	if got, want := paths(Diff(baseline, current, Tolerance{})), []string{"body > p", "body > footer"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() = %v, want %v", got, want)
+	}
+
+	// Past the cap of a truncated snapshot, a missing element proves nothing
+	current.Truncated = true
+	if got, want := paths(Diff(baseline, current, Tolerance{})), []string{"body > footer"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() with truncated current = %v, want %v", got, want)
+	}
+	baseline.Truncated = true
+	if got := Diff(baseline, current, Tolerance{}); len(got) != 0 {
+		t.Errorf("Diff() with both truncated = %v, want none", paths(got))
+	}
+}
+
+func TestDiff_CollapsesByParent(t *testing.T) {
+	// The #main section lives inside a wrapper div; its descendants' paths
+	// start at the id, so only the parent links tie them to the wrapper
+	baseline := &Snapshot{Elements: []Element{
+		{Path: "body > h1", Label: "h1", X: 0, Y: 0, Width: 100, Height: 20},
+	}}
+	current := &Snapshot{Elements: []Element{
+		{Path: "body > h1", Label: "h1", X: 0, Y: 0, Width: 100, Height: 20},
+		{Path: "body > div", Label: "div.wrapper", X: 0, Y: 20, Width: 100, Height: 40},
+		{Path: "#main", Parent: "body > div", Label: "#main", X: 0, Y: 20, Width: 100, Height: 40},
+		{Path: "#main > p", Parent: "#main", Label: "p", X: 0, Y: 20, Width: 100, Height: 20},
+	}}
+
+	changes := Diff(baseline, current, DefaultTolerance)
+	if len(changes) != 1 || changes[0].Path != "body > div" {
+		t.Errorf("Diff() = %+v, want only body > div added", changes)
+	}
+}
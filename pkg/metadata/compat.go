@@ -0,0 +1,71 @@
+package metadata
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Incompatibilities describes the settings that differ between the
+// baseline and current captures in ways that make their pixel diff
+// meaningless, such as a different viewport or determinism profile.
+func Incompatibilities(baseline, current *Metadata) []string {
+	var problems []string
+	differ := func(setting, a, b string) {
+		if a != b {
+			problems = append(problems, fmt.Sprintf("%s: %s vs %s", setting, orNone(a), orNone(b)))
+		}
+	}
+
+	differ("viewport",
+		fmt.Sprintf("%dx%d", baseline.ViewportWidth, baseline.ViewportHeight),
+		fmt.Sprintf("%dx%d", current.ViewportWidth, current.ViewportHeight))
+	differ("device scale factor",
+		fmt.Sprintf("%g", baseline.DeviceScaleFactor),
+		fmt.Sprintf("%g", current.DeviceScaleFactor))
+	differ("user agent", baseline.UserAgent, current.UserAgent)
+	differ("color scheme", baseline.ColorScheme, current.ColorScheme)
+	differ("media type", baseline.MediaType, current.MediaType)
+	differ("full page", fmt.Sprint(baseline.FullPage), fmt.Sprint(current.FullPage))
+	differ("timezone", baseline.Timezone, current.Timezone)
+	differ("locale", baseline.Locale, current.Locale)
+	differ("accept language", baseline.AcceptLanguage, current.AcceptLanguage)
+	differ("geolocation", formatGeolocation(baseline.Geolocation), formatGeolocation(current.Geolocation))
+	differ("mock time", baseline.MockTime, current.MockTime)
+	differ("determinism profile", baseline.DeterminismProfile, current.DeterminismProfile)
+	differ("deterministic scripts",
+		strings.Join(baseline.DeterministicScripts, ","),
+		strings.Join(current.DeterministicScripts, ","))
+	differ("Chrome major version", majorVersion(baseline.ChromeVersion), majorVersion(current.ChromeVersion))
+
+	return problems
+}
+
+// majorVersion returns the product and major version of a browser version
+// ("HeadlessChrome/131.0.6778.85" -> "HeadlessChrome/131"). Unknown
+// versions are returned as is.
+func majorVersion(version string) string {
+	if product, number, ok := strings.Cut(version, "/"); ok {
+		major, _, _ := strings.Cut(number, ".")
+		return product + "/" + major
+	}
+	return version
+}
+
+// formatGeolocation formats a position as LAT,LNG[,ACCURACY] like the
+// --geolocation flag, or "" for none.
+func formatGeolocation(g *Geolocation) string {
+	if g == nil {
+		return ""
+	}
+	if g.Accuracy != 0 {
+		return fmt.Sprintf("%g,%g,%g", g.Latitude, g.Longitude, g.Accuracy)
+	}
+	return fmt.Sprintf("%g,%g", g.Latitude, g.Longitude)
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
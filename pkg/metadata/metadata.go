@@ -0,0 +1,181 @@
+// Package metadata describes how a screenshot was captured.
+//
+// The metadata is written as a JSON sidecar next to the screenshot, and
+// optionally into the PNG itself, so that a capture can be reproduced and
+// later steps can tell how it was made.
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
+// Metadata records the settings a screenshot was captured with.
+type Metadata struct {
+	// Version is the static-webshot version that made the capture.
+	Version string `json:"version,omitempty"`
+
+	// CapturedAt is when the screenshot was taken.
+	CapturedAt time.Time `json:"capturedAt"`
+
+	// URL is the captured URL.
+	URL string `json:"url"`
+
+	// FinalURL is the URL of the captured page after redirects.
+	FinalURL string `json:"finalUrl,omitempty"`
+
+	// Preset is the device preset name.
+	Preset string `json:"preset"`
+
+	// ViewportWidth and ViewportHeight are the effective viewport size in
+	// CSS pixels.
+	ViewportWidth  int `json:"viewportWidth"`
+	ViewportHeight int `json:"viewportHeight"`
+
+	// DeviceScaleFactor is the number of image pixels per CSS pixel before
+	// any resize.
+	DeviceScaleFactor float64 `json:"deviceScaleFactor"`
+
+	// UserAgent is the effective User-Agent.
+	UserAgent string `json:"userAgent,omitempty"`
+
+	// ColorScheme and MediaType are the emulated media features.
+	ColorScheme string `json:"colorScheme,omitempty"`
+	MediaType   string `json:"mediaType,omitempty"`
+
+	// FullPage is set for captures of the whole page.
+	FullPage bool `json:"fullPage,omitempty"`
+
+	// Timezone, Locale and AcceptLanguage are the emulated timezone, locale
+	// and Accept-Language header ("" = host default).
+	Timezone       string `json:"timezone,omitempty"`
+	Locale         string `json:"locale,omitempty"`
+	AcceptLanguage string `json:"acceptLanguage,omitempty"`
+
+	// Geolocation is the emulated position, nil if none.
+	Geolocation *Geolocation `json:"geolocation,omitempty"`
+
+	// MockTime is the start time of the virtual clock.
+	MockTime string `json:"mockTime,omitempty"`
+
+	// ChromeVersion is the browser product and version.
+	ChromeVersion string `json:"chromeVersion,omitempty"`
+
+	// DeterminismProfile is the determinism profile ("default" or "strict").
+	DeterminismProfile string `json:"determinismProfile,omitempty"`
+
+	// DeterministicScripts lists the deterministic scripts injected into the
+	// page, in injection order.
+	DeterministicScripts []string `json:"deterministicScripts"`
+
+	// FixedElements lists the fixed and sticky elements adjusted by
+	// --fixed-elements.
+	FixedElements []FixedElement `json:"fixedElements,omitempty"`
+
+	// Masks lists the masked areas of the screenshot in image pixels.
+	Masks []MaskRegion `json:"masks,omitempty"`
+}
+
+// Geolocation is an emulated position.
+type Geolocation struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Accuracy  float64 `json:"accuracy,omitempty"` // Accuracy in meters
+}
+
+// MaskRegion is the area of a masked element in image pixels.
+type MaskRegion struct {
+	// Selector is the mask's CSS selector.
+	Selector string `json:"selector"`
+
+	// Style is the mask style ("hide", "blackout", "blur" or "box").
+	Style string `json:"style"`
+
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// IgnoreRegions returns the masked areas as regions to exclude from
+// comparison.
+func (m *Metadata) IgnoreRegions() []ports.IgnoreRegion {
+	regions := make([]ports.IgnoreRegion, 0, len(m.Masks))
+	for _, mask := range m.Masks {
+		regions = append(regions, ports.IgnoreRegion{X: mask.X, Y: mask.Y, Width: mask.Width, Height: mask.Height})
+	}
+	return regions
+}
+
+// FixedElement is a fixed or sticky element adjusted before the capture.
+type FixedElement struct {
+	// Selector is a short CSS path of the element.
+	Selector string `json:"selector"`
+
+	// Position is the original CSS position ("fixed" or "sticky").
+	Position string `json:"position"`
+
+	// Action is what was done: "absolute", "static" or "hidden".
+	Action string `json:"action"`
+}
+
+// SidecarPath returns the metadata path for an image path
+// ("shots/home.png" -> "shots/home.meta.json").
+func SidecarPath(imagePath string) string {
+	return strings.TrimSuffix(imagePath, filepath.Ext(imagePath)) + ".meta.json"
+}
+
+// DiagnosticsPath returns the page diagnostics path for an image path
+// ("shots/home.png" -> "shots/home.diagnostics.json").
+func DiagnosticsPath(imagePath string) string {
+	return strings.TrimSuffix(imagePath, filepath.Ext(imagePath)) + ".diagnostics.json"
+}
+
+// ToJSON converts the metadata to indented JSON.
+func (m *Metadata) ToJSON() ([]byte, error) {
+	return json.MarshalIndent(m, "", "  ")
+}
+
+// Write saves the metadata as JSON to path.
+func Write(fs ports.FileSystem, path string, m *Metadata) error {
+	data, err := m.ToJSON()
+	if err != nil {
+		return fmt.Errorf("marshal metadata: %w", err)
+	}
+	return fs.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Read loads metadata written by Write.
+func Read(fs ports.FileSystem, path string) (*Metadata, error) {
+	data, err := fs.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Metadata
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parse metadata %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// Load returns the metadata of the image at imagePath: its sidecar if there
+// is one, otherwise the metadata embedded in a PNG. It returns nil if the
+// image has no metadata.
+func Load(fs ports.FileSystem, imagePath string) (*Metadata, error) {
+	if path := SidecarPath(imagePath); fs.Exists(path) {
+		return Read(fs, path)
+	}
+	if !strings.EqualFold(filepath.Ext(imagePath), ".png") {
+		return nil, nil
+	}
+	data, err := fs.ReadFile(imagePath)
+	if err != nil {
+		return nil, err
+	}
+	return ReadPNG(data)
+}
@@ -0,0 +1,122 @@
+package metadata
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ideamans/static-webshot/pkg/adapters/osfilesystem"
+)
+
+func TestSidecarPath(t *testing.T) {
+	tests := []struct {
+		imagePath string
+		want      string
+	}{
+		{"capture.png", "capture.meta.json"},
+		{"shots/home.png", "shots/home.meta.json"},
+		{"shots/v1.2/home", "shots/v1.2/home.meta.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.imagePath, func(t *testing.T) {
+			if got := SidecarPath(tt.imagePath); got != tt.want {
+				t.Errorf("SidecarPath(%q) = %q, want %q", tt.imagePath, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	fs := osfilesystem.New()
+	path := filepath.Join(t.TempDir(), "capture.meta.json")
+	want := &Metadata{
+		URL:                  "https://example.com",
+		Preset:               "mobile",
+		DeterministicScripts: []string{"autoplay", "carousel"},
+	}
+
+	if err := Write(fs, path, want); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	got, err := Read(fs, path)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Read() = %+v, want %+v", got, want)
+	}
+}
+
+func TestEmbedReadPNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 3))); err != nil {
+		t.Fatal(err)
+	}
+	want := &Metadata{
+		Version:              "v1.0.0",
+		CapturedAt:           time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		URL:                  "https://example.com/日本語",
+		Preset:               "desktop",
+		ViewportWidth:        1920,
+		ViewportHeight:       1080,
+		DeviceScaleFactor:    1,
+		DeterministicScripts: []string{"clock"},
+		Masks:                []MaskRegion{{Selector: ".ad", Style: "blur", X: 1, Y: 2, Width: 3, Height: 1}},
+	}
+
+	data, err := EmbedPNG(buf.Bytes(), want)
+	if err != nil {
+		t.Fatalf("EmbedPNG() error = %v", err)
+	}
+	if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatalf("embedded PNG does not decode: %v", err)
+	}
+
+	got, err := ReadPNG(data)
+	if err != nil {
+		t.Fatalf("ReadPNG() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadPNG() = %+v, want %+v", got, want)
+	}
+
+	if got, err := ReadPNG(buf.Bytes()); err != nil || got != nil {
+		t.Errorf("ReadPNG() without metadata = %+v, %v, want nil", got, err)
+	}
+}
+
+func TestIncompatibilities(t *testing.T) {
+	baseline := &Metadata{
+		ViewportWidth:        1920,
+		ViewportHeight:       1080,
+		DeviceScaleFactor:    1,
+		ChromeVersion:        "HeadlessChrome/131.0.6778.85",
+		DeterministicScripts: []string{"clock", "random"},
+	}
+	current := *baseline
+	current.ChromeVersion = "HeadlessChrome/131.0.6778.200"
+	if problems := Incompatibilities(baseline, &current); len(problems) != 0 {
+		t.Errorf("Incompatibilities() = %v, want none for a Chrome patch update", problems)
+	}
+
+	current.ViewportWidth = 390
+	current.MockTime = "2026-01-01T00:00:00Z"
+	current.Timezone = "Asia/Tokyo"
+	current.Locale = "ja-JP"
+	current.Geolocation = &Geolocation{Latitude: 35.68, Longitude: 139.76}
+	want := []string{
+		"viewport: 1920x1080 vs 390x1080",
+		"timezone: (none) vs Asia/Tokyo",
+		"locale: (none) vs ja-JP",
+		"geolocation: (none) vs 35.68,139.76",
+		"mock time: (none) vs 2026-01-01T00:00:00Z",
+	}
+	if problems := Incompatibilities(baseline, &current); !reflect.DeepEqual(problems, want) {
+		t.Errorf("Incompatibilities() = %v, want %v", problems, want)
+	}
+}
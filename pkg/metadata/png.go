@@ -0,0 +1,120 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// PNGKeyword is the keyword of the tEXt chunk that holds the metadata.
+const PNGKeyword = "static-webshot"
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// EmbedPNG returns the PNG data with the metadata added as tEXt chunks right
+// after the IHDR chunk: the standard "Software" and "Creation Time" keywords,
+// and PNGKeyword holding the metadata as JSON.
+func EmbedPNG(data []byte, m *Metadata) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, errors.New("not a PNG image")
+	}
+	// IHDR is always the first chunk and 13 bytes long
+	ihdrEnd := len(pngSignature) + 8 + 13 + 4
+	if len(data) < ihdrEnd || string(data[len(pngSignature)+4:len(pngSignature)+8]) != "IHDR" {
+		return nil, errors.New("PNG image without IHDR chunk")
+	}
+
+	metaJSON, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("marshal metadata: %w", err)
+	}
+
+	var chunks bytes.Buffer
+	if m.Version != "" {
+		writeTextChunk(&chunks, "Software", "static-webshot "+m.Version)
+	}
+	if !m.CapturedAt.IsZero() {
+		// PNG recommends the RFC 1123 format for the creation time
+		writeTextChunk(&chunks, "Creation Time", m.CapturedAt.UTC().Format("Mon, 02 Jan 2006 15:04:05 GMT"))
+	}
+	writeTextChunk(&chunks, PNGKeyword, asciiJSON(metaJSON))
+
+	out := make([]byte, 0, len(data)+chunks.Len())
+	out = append(out, data[:ihdrEnd]...)
+	out = append(out, chunks.Bytes()...)
+	out = append(out, data[ihdrEnd:]...)
+	return out, nil
+}
+
+// ReadPNG returns the metadata embedded by EmbedPNG, or nil if there is none.
+func ReadPNG(data []byte) (*Metadata, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, errors.New("not a PNG image")
+	}
+	for pos := len(pngSignature); pos+8 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		chunkType := string(data[pos+4 : pos+8])
+		end := pos + 8 + length + 4
+		if length < 0 || end > len(data) {
+			return nil, errors.New("truncated PNG chunk")
+		}
+		if chunkType == "IDAT" || chunkType == "IEND" {
+			break
+		}
+		if chunkType == "tEXt" {
+			keyword, text, ok := bytes.Cut(data[pos+8:pos+8+length], []byte{0})
+			if ok && string(keyword) == PNGKeyword {
+				var m Metadata
+				if err := json.Unmarshal(text, &m); err != nil {
+					return nil, fmt.Errorf("parse embedded metadata: %w", err)
+				}
+				return &m, nil
+			}
+		}
+		pos = end
+	}
+	return nil, nil
+}
+
+// writeTextChunk writes a tEXt chunk. text must be Latin-1.
+func writeTextChunk(buf *bytes.Buffer, keyword, text string) {
+	payload := append(append([]byte(keyword), 0), text...)
+
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(payload)))
+	copy(header[4:], "tEXt")
+	buf.Write(header[:])
+	buf.Write(payload)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(payload)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	buf.Write(sum[:])
+}
+
+// asciiJSON escapes the non-ASCII characters of JSON data, which tEXt
+// chunks cannot hold as UTF-8.
+func asciiJSON(data []byte) string {
+	var b strings.Builder
+	for _, r := range string(data) {
+		switch {
+		case r < utf8.RuneSelf:
+			b.WriteRune(r)
+		case r > 0xFFFF:
+			r -= 0x10000
+			b.WriteString(`\u` + strconv.FormatInt(int64(0xD800+(r>>10)), 16))
+			b.WriteString(`\u` + strconv.FormatInt(int64(0xDC00+(r&0x3FF)), 16))
+		default:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		}
+	}
+	return b.String()
+}
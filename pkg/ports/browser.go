@@ -14,8 +14,117 @@ type BrowserOptions struct {
 	ViewportWidth     int               // Viewport width in CSS pixels
 	ViewportHeight    int               // Viewport height in CSS pixels
 	IsMobile          bool              // Enable mobile emulation
+	HasTouch          bool              // Enable touch event emulation
+	DeviceScaleFactor float64           // Device pixels per CSS pixel (0 = 1)
 	IgnoreHTTPSErrors bool              // Ignore HTTPS certificate errors
 	ProxyServer       string            // HTTP proxy server URL
+	ColorScheme       string            // Emulated prefers-color-scheme: "light", "dark" or "" (browser default)
+	ReducedMotion     bool              // Emulate prefers-reduced-motion: reduce
+	ForcedColors      bool              // Emulate forced-colors: active
+	MediaType         string            // Emulated CSS media type: "print", "screen" or "" (browser default)
+	Timezone          string            // IANA timezone ID, e.g. "UTC" (empty = host timezone)
+	Locale            string            // BCP 47 locale, e.g. "en-US" (empty = host locale)
+	AcceptLanguage    string            // Accept-Language header and navigator.languages (empty = derived from Locale)
+	Geolocation       *Geolocation      // Emulated position reported to the Geolocation API (nil = none)
+	StrictRendering   bool              // Trade GPU and text rendering for output that matches between machines
+}
+
+// Geolocation is an emulated geographic position.
+type Geolocation struct {
+	Latitude  float64
+	Longitude float64
+	Accuracy  float64 // Accuracy in meters
+}
+
+// Mask styles.
+const (
+	MaskHide     = "hide"     // Hide the element, leaving its space empty
+	MaskBlackout = "blackout" // Cover the element with a black box
+	MaskBlur     = "blur"     // Blur the element
+	MaskBox      = "box"      // Cover the element with a box in Mask.Color
+)
+
+// Mask covers the elements matching a CSS selector in screenshots.
+type Mask struct {
+	Selector string
+	Style    string // One of the mask styles ("" = MaskHide)
+	Color    string // CSS color of a MaskBox box
+}
+
+// MaskedRect is the area of a masked element in CSS pixels, relative to the
+// top-left corner of the document.
+type MaskedRect struct {
+	Selector string
+	Style    string
+	X        float64
+	Y        float64
+	Width    float64
+	Height   float64
+}
+
+// PageDiagnostics are the problems a page reported while it was loaded and
+// prepared for the capture.
+type PageDiagnostics struct {
+	// DocumentURL and DocumentStatus describe the main document response,
+	// after redirects (status 0 = no response).
+	DocumentURL    string `json:"documentUrl,omitempty"`
+	DocumentStatus int    `json:"documentStatus"`
+
+	ConsoleMessages []ConsoleMessage `json:"consoleMessages"`
+	Exceptions      []PageException  `json:"exceptions"`
+	FailedRequests  []FailedRequest  `json:"failedRequests"`
+	MixedContent    []MixedContent   `json:"mixedContent"`
+}
+
+// ConsoleMessage is a message logged by the page or by the browser about it.
+type ConsoleMessage struct {
+	Source string `json:"source"` // "console" for the console API, otherwise the browser log source
+	Level  string `json:"level"`  // "error", "warning", "info", "log", "debug", ...
+	Text   string `json:"text"`
+	URL    string `json:"url,omitempty"`
+	Line   int    `json:"line,omitempty"` // 1-based
+}
+
+// PageException is an uncaught JavaScript exception.
+type PageException struct {
+	Message string `json:"message"`
+	URL     string `json:"url,omitempty"`
+	Line    int    `json:"line,omitempty"` // 1-based
+}
+
+// FailedRequest is a request that failed or got an HTTP error status.
+type FailedRequest struct {
+	URL          string `json:"url"`
+	Method       string `json:"method,omitempty"`
+	ResourceType string `json:"resourceType,omitempty"`
+	Status       int    `json:"status,omitempty"` // HTTP status, 0 if there was no response
+	Error        string `json:"error,omitempty"`  // Network error or block reason
+}
+
+// MixedContent is an insecure resource requested by a secure page.
+type MixedContent struct {
+	URL          string `json:"url"`
+	ResourceType string `json:"resourceType,omitempty"`
+	Type         string `json:"type"` // "blockable" or "optionally-blockable"
+	Blocked      bool   `json:"blocked"`
+}
+
+// AXNode is a node of the page's accessibility tree.
+type AXNode struct {
+	ID       string
+	ParentID string
+	ChildIDs []string
+
+	// Ignored nodes are not exposed to assistive technology; their children
+	// may be.
+	Ignored bool
+
+	Role string
+	Name string
+
+	// Properties are the node's states and other properties ("level",
+	// "checked", "disabled", ...), as strings.
+	Properties map[string]string
 }
 
 // Browser abstracts browser automation for page screenshot capture.
@@ -23,6 +132,10 @@ type Browser interface {
 	// Launch starts the browser with the given options.
 	Launch(ctx context.Context, opts BrowserOptions) error
 
+	// Version returns the browser product and version
+	// (e.g. "HeadlessChrome/131.0.6778.85").
+	Version(ctx context.Context) (string, error)
+
 	// Navigate loads the specified URL and waits for the load event.
 	Navigate(ctx context.Context, url string) error
 
@@ -41,12 +154,28 @@ type Browser interface {
 	// WaitForImages waits for all images to be loaded.
 	WaitForImages(ctx context.Context) error
 
-	// ApplyMasks hides elements matching the given CSS selectors.
-	ApplyMasks(ctx context.Context, selectors []string) error
+	// Diagnostics returns the problems reported by the page since the last
+	// Navigate.
+	Diagnostics() PageDiagnostics
+
+	// Evaluate runs a JavaScript expression in the page, waits for a returned
+	// promise to settle and decodes the result into result (nil = discard).
+	Evaluate(ctx context.Context, expression string, result any) error
 
-	// Screenshot captures a full-page screenshot.
+	// AccessibilityTree returns the nodes of the page's accessibility tree,
+	// the root first.
+	AccessibilityTree(ctx context.Context) ([]AXNode, error)
+
+	// ApplyMasks covers the elements matching the masks and returns their
+	// areas.
+	ApplyMasks(ctx context.Context, masks []Mask) ([]MaskedRect, error)
+
+	// Screenshot captures a viewport screenshot.
 	Screenshot(ctx context.Context) ([]byte, error)
 
+	// FullPageScreenshot captures the whole scrollable page.
+	FullPageScreenshot(ctx context.Context) ([]byte, error)
+
 	// Close shuts down the browser.
 	Close() error
 }
@@ -14,6 +14,9 @@ type FileSystem interface {
 	// Exists checks if a file or directory exists at the given path.
 	Exists(path string) bool
 
+	// Remove removes the file at the given path.
+	Remove(path string) error
+
 	// MkdirAll creates a directory along with any necessary parents.
 	MkdirAll(path string, perm os.FileMode) error
 }
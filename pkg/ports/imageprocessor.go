@@ -2,6 +2,7 @@
 package ports
 
 import (
+	"fmt"
 	"image"
 )
 
@@ -20,8 +21,21 @@ type CompareOptions struct {
 	// MaxHeight limits comparison to the top N pixels (0 = no limit)
 	MaxHeight int
 
-	// DiffOverlay overlays diff markers on the current image instead of creating a separate diff image
-	DiffOverlay bool
+	// SizeMismatch is how images of different sizes are compared, one of
+	// the SizeMismatch strategies ("" = SizeMismatchPad)
+	SizeMismatch string
+
+	// DiffLayout is how the diff image is laid out, one of the DiffLayout
+	// constants ("" = DiffLayoutHorizontal)
+	DiffLayout string
+
+	// Identical tells that the caller already found the images
+	// pixel-identical, so the pixel comparison is skipped
+	Identical bool
+
+	// CropToChanges renders only the regions of differing pixels, with
+	// some context around them, stacked as a gallery
+	CropToChanges bool
 
 	// LabelFontPath is the path to a TrueType font file for labels (optional)
 	// If not specified, a basic built-in font will be used
@@ -40,6 +54,40 @@ type CompareOptions struct {
 	CurrentLabel string
 }
 
+// Diff image layouts.
+const (
+	DiffLayoutHorizontal    = "horizontal"     // baseline | diff | current, side by side
+	DiffLayoutVertical      = "vertical"       // baseline, diff and current stacked
+	DiffLayoutDiffOnly      = "diff-only"      // The diff panel alone
+	DiffLayoutPair          = "pair"           // baseline | current, side by side
+	DiffLayoutRawPixelmatch = "raw-pixelmatch" // pixelmatch's diff mask: differing pixels red on white
+)
+
+// Strategies for comparing images of different sizes.
+const (
+	SizeMismatchPad   = "pad"   // Pad both to the larger size with magenta
+	SizeMismatchCrop  = "crop"  // Compare the area both images have
+	SizeMismatchFail  = "fail"  // Fail the comparison
+	SizeMismatchScale = "scale" // Scale current to the size of baseline
+)
+
+// Validate rejects unknown diff image layouts and size mismatch strategies.
+func (o CompareOptions) Validate() error {
+	switch o.DiffLayout {
+	case "", DiffLayoutHorizontal, DiffLayoutVertical, DiffLayoutDiffOnly, DiffLayoutPair, DiffLayoutRawPixelmatch:
+	default:
+		return fmt.Errorf("invalid diff layout %q (want horizontal, vertical, diff-only, pair or raw-pixelmatch)", o.DiffLayout)
+	}
+
+	switch o.SizeMismatch {
+	case "", SizeMismatchPad, SizeMismatchCrop, SizeMismatchFail, SizeMismatchScale:
+	default:
+		return fmt.Errorf("invalid size mismatch strategy %q (want pad, crop, fail or scale)", o.SizeMismatch)
+	}
+
+	return nil
+}
+
 // IgnoreRegion defines a rectangular area to exclude from comparison.
 type IgnoreRegion struct {
 	X      int
@@ -61,6 +109,38 @@ type CompareResult struct {
 
 	// DiffImage is the generated difference visualization image
 	DiffImage image.Image
+
+	// DiffRegions are the bounding boxes of clusters of differing pixels,
+	// top to bottom
+	DiffRegions []image.Rectangle
+}
+
+// Image formats for EncodeOptions.
+const (
+	FormatPNG  = "png"
+	FormatJPEG = "jpeg"
+	FormatWebP = "webp" // Lossless
+)
+
+// PNG compression levels for EncodeOptions.
+const (
+	CompressionDefault = "default"
+	CompressionSpeed   = "speed"
+	CompressionBest    = "best"
+	CompressionNone    = "none"
+)
+
+// EncodeOptions configures how an image is written.
+type EncodeOptions struct {
+	// Format is FormatPNG, FormatJPEG or FormatWebP ("" = from the file
+	// extension, PNG if it is not known).
+	Format string
+
+	// Quality is the JPEG quality, 1-100 (0 = 90).
+	Quality int
+
+	// Compression is the PNG compression level ("" = CompressionDefault).
+	Compression string
 }
 
 // ImageProcessor handles image loading, comparison, and diff generation.
@@ -68,8 +148,12 @@ type ImageProcessor interface {
 	// LoadImage loads an image from the given file path.
 	LoadImage(path string) (image.Image, error)
 
-	// SaveImage saves an image to the given file path.
-	SaveImage(path string, img image.Image) error
+	// DecodeImage decodes an image from encoded bytes held in memory.
+	DecodeImage(data []byte) (image.Image, error)
+
+	// SaveImage saves an image to the given file path, encoded as opts
+	// says.
+	SaveImage(path string, img image.Image, opts EncodeOptions) error
 
 	// Compare compares two images and returns the comparison result.
 	Compare(baseline, current image.Image, opts CompareOptions) (*CompareResult, error)
@@ -0,0 +1,12 @@
+// Package ports defines interfaces for external dependencies.
+package ports
+
+// StaticServer serves a directory of static files over HTTP.
+type StaticServer interface {
+	// Serve starts serving dir and returns the base URL (without a trailing
+	// slash). Any directory served before is replaced.
+	Serve(dir string) (string, error)
+
+	// Close stops serving.
+	Close() error
+}
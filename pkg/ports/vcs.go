@@ -0,0 +1,14 @@
+// Package ports defines interfaces for external dependencies.
+package ports
+
+import "context"
+
+// VersionControl abstracts checking out revisions of a source repository.
+type VersionControl interface {
+	// CreateWorktree checks rev out into a new temporary directory and
+	// returns its path.
+	CreateWorktree(ctx context.Context, repoDir, rev string) (string, error)
+
+	// RemoveWorktree deletes a directory created by CreateWorktree.
+	RemoveWorktree(ctx context.Context, repoDir, dir string) error
+}
@@ -1,6 +1,11 @@
 // Package record provides the record command logic.
 package record
 
+import (
+	"github.com/ideamans/static-webshot/pkg/adapters/chromebrowser"
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
 // Config holds configuration for the record command.
 type Config struct {
 	// URL is the target URL to capture.
@@ -9,16 +14,29 @@ type Config struct {
 	// OutputPath is the path where the screenshot will be saved.
 	OutputPath string
 
-	// Preset is the device preset to use (desktop, mobile).
+	// Encoding is how the screenshot is written; its format defaults to the
+	// one of the OutputPath extension. Metadata can only be embedded in PNG.
+	Encoding ports.EncodeOptions
+
+	// Preset is the device preset to use (see Presets).
 	Preset string
 
+	// PresetsFile is a JSON or YAML file with custom presets (optional).
+	PresetsFile string
+
 	// ViewportWidth is the viewport width in CSS pixels.
 	ViewportWidth int
 
 	// ViewportHeight is the viewport height in CSS pixels.
 	ViewportHeight int
 
-	// ResizeWidth is the output image width (0 = no resize).
+	// DeviceScaleFactor is the device pixel ratio (0 = use preset).
+	// The screenshot is ViewportWidth*DeviceScaleFactor pixels wide.
+	DeviceScaleFactor float64
+
+	// ResizeWidth is the output image width in image pixels (0 = no resize).
+	// It applies after DeviceScaleFactor, so a 2x capture of a 390px viewport
+	// resized to 390 is downsampled back to CSS pixel size.
 	ResizeWidth int
 
 	// ResizeHeight is the output image height (0 = use aspect ratio).
@@ -36,8 +54,42 @@ type Config struct {
 	// IgnoreHTTPSErrors ignores SSL certificate errors.
 	IgnoreHTTPSErrors bool
 
-	// Masks are CSS selectors for elements to hide.
-	Masks []string
+	// Masks cover elements in the screenshot.
+	Masks []ports.Mask
+
+	// EmbedMetadata also writes the capture metadata into the PNG as tEXt
+	// chunks.
+	EmbedMetadata bool
+
+	// Version is the static-webshot version recorded in the metadata.
+	Version string
+
+	// FailOnConsoleError fails the capture when the page logs a console
+	// error or throws an uncaught exception.
+	FailOnConsoleError bool
+
+	// FailOnHTTPError fails the capture when the main document responds
+	// with an HTTP error status.
+	FailOnHTTPError bool
+
+	// FullPage captures the whole scrollable page instead of the viewport.
+	FullPage bool
+
+	// FixedElements is what to do with fixed and sticky elements before the
+	// capture: "keep", "static" or "hide-all".
+	FixedElements string
+
+	// PreloadScroll scrolls through the page before the capture so that
+	// scroll-triggered lazy content is loaded.
+	PreloadScroll bool
+
+	// LayoutSnapshot records the visible elements of the page next to the
+	// screenshot, so that compare can explain differences by element.
+	LayoutSnapshot bool
+
+	// AccessibilitySnapshot records the accessibility tree of the page next
+	// to the screenshot, for compare-a11y.
+	AccessibilitySnapshot bool
 
 	// WaitSelectors are CSS selectors to wait for before capture.
 	WaitSelectors []string
@@ -48,6 +100,37 @@ type Config struct {
 	// MockTime is the fixed time to use for Date and related APIs.
 	MockTime string
 
+	// ClockStep is the number of milliseconds the virtual clock started at
+	// MockTime advances on every read (0 = frozen between animation frames).
+	ClockStep float64
+
+	// RandomSeed seeds Math.random and crypto random values. It applies with
+	// MockTime (nil = seed 0), or on its own when set.
+	RandomSeed *int64
+
+	// CarouselHooks freeze carousels the built-in handling does not know.
+	CarouselHooks []CarouselHook
+
+	// VideoFrame is what videos show: "" (first frame), "poster" or a time
+	// in seconds.
+	VideoFrame string
+
+	// DeterminismProfile is "default" or "strict". The strict profile also
+	// pins canvas, GPU and text rendering, at some cost in speed and fidelity
+	// to what users see.
+	DeterminismProfile string
+
+	// DisableDeterminism lists deterministic scripts to leave out, or "all".
+	DisableDeterminism []string
+
+	// EnableDeterminism lists deterministic scripts to keep even if disabled.
+	EnableDeterminism []string
+
+	// DeterminismScripts are JavaScript files injected after the
+	// deterministic scripts, each on its own. A script that fails to parse
+	// or throws fails the capture.
+	DeterminismScripts []string
+
 	// ChromePath is the path to the Chrome executable.
 	ChromePath string
 
@@ -56,15 +139,65 @@ type Config struct {
 
 	// UserAgent is a custom User-Agent string (overrides preset).
 	UserAgent string
+
+	// ColorScheme is the emulated prefers-color-scheme, "light" or "dark" (overrides preset).
+	ColorScheme string
+
+	// ReducedMotion emulates prefers-reduced-motion: reduce.
+	ReducedMotion bool
+
+	// ForcedColors emulates forced-colors: active.
+	ForcedColors bool
+
+	// MediaType is the emulated CSS media type, "print" or "screen" (overrides preset).
+	MediaType string
+
+	// Timezone is the IANA timezone the page runs in (empty = host timezone).
+	Timezone string
+
+	// Locale is the BCP 47 locale the page runs in (empty = host locale).
+	Locale string
+
+	// AcceptLanguage overrides the Accept-Language header derived from Locale.
+	AcceptLanguage string
+
+	// Geolocation is the position reported to the Geolocation API (nil = none).
+	Geolocation *ports.Geolocation
+
+	// ServeCommand is a shell command that starts a local server before the
+	// capture and is torn down afterwards (optional).
+	ServeCommand string
+
+	// ServeURL is polled until it answers before capturing (required with ServeCommand).
+	ServeURL string
+
+	// ServeTimeout is the maximum time in seconds to wait for ServeURL to answer.
+	ServeTimeout int
+}
+
+// CarouselHook runs the JavaScript in ScriptPath for every element matching
+// Selector, with the element available as "element".
+type CarouselHook struct {
+	Selector   string
+	ScriptPath string
 }
 
 // DefaultConfig returns a Config with default values.
 func DefaultConfig() Config {
 	return Config{
-		OutputPath: "./capture.png",
-		Preset:     "desktop",
-		WaitAfter:  0,
-		Headless:   true,
-		Timeout:    30,
+		OutputPath:   "./capture.png",
+		Preset:       "desktop",
+		WaitAfter:    0,
+		Headless:     true,
+		Timeout:      30,
+		ServeTimeout: 60,
+		ClockStep:    chromebrowser.DefaultClockStep,
+
+		DeterminismProfile: chromebrowser.ProfileDefault,
+		FixedElements:      chromebrowser.FixedElementsKeep,
+
+		// Pin the region so captures match between laptops and CI
+		Timezone: "UTC",
+		Locale:   "en-US",
 	}
 }
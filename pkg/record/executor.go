@@ -4,14 +4,23 @@ package record
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"image"
 	"image/png"
+	"math"
+	"slices"
+	"strings"
 	"time"
 
 	"golang.org/x/image/draw"
 
+	"github.com/ideamans/static-webshot/pkg/a11y"
 	"github.com/ideamans/static-webshot/pkg/adapters/chromebrowser"
+	"github.com/ideamans/static-webshot/pkg/devserver"
+	"github.com/ideamans/static-webshot/pkg/imageformat"
+	"github.com/ideamans/static-webshot/pkg/layout"
+	"github.com/ideamans/static-webshot/pkg/metadata"
 	"github.com/ideamans/static-webshot/pkg/ports"
 )
 
@@ -33,8 +42,201 @@ func NewExecutor(browser ports.Browser, filesystem ports.FileSystem, logger port
 
 // Execute runs the record command with the given configuration.
 func (e *Executor) Execute(ctx context.Context, cfg Config) error {
+	encoding, err := imageformat.Resolve(cfg.Encoding, cfg.OutputPath)
+	if err != nil {
+		return err
+	}
+
+	// Start the managed dev server first so it is torn down even if the
+	// capture fails
+	server, err := e.startDevServer(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if server != nil {
+		defer server.Stop()
+	}
+
+	meta, err := e.launch(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer e.browser.Close()
+
+	page, err := e.capturePage(ctx, cfg, meta.DeviceScaleFactor, cfg.URL)
+	if err != nil {
+		return err
+	}
+	screenshot := page.screenshot
+
+	meta.Version = cfg.Version
+	meta.CapturedAt = time.Now().UTC()
+	meta.URL = cfg.URL
+	meta.FinalURL = page.finalURL
+	meta.FixedElements = page.fixedElements
+	meta.Masks = page.masks
+
+	// Chrome captures PNG; other formats and compression levels are
+	// re-encoded from it
+	screenshot, err = imageformat.Convert(screenshot, encoding)
+	if err != nil {
+		return fmt.Errorf("encode screenshot: %w", err)
+	}
+
+	if cfg.EmbedMetadata {
+		if encoding.Format == ports.FormatPNG {
+			screenshot, err = metadata.EmbedPNG(screenshot, meta)
+			if err != nil {
+				return fmt.Errorf("embed metadata: %w", err)
+			}
+		} else {
+			e.logger.Warn("Metadata can only be embedded in PNG, not %s; see %s", encoding.Format, metadata.SidecarPath(cfg.OutputPath))
+		}
+	}
+
+	if cfg.ResizeWidth > 0 {
+		if cfg.ResizeHeight > 0 {
+			e.logger.Info("Saving to %s (%dx%d)...", cfg.OutputPath, cfg.ResizeWidth, cfg.ResizeHeight)
+		} else {
+			e.logger.Info("Saving to %s (width=%d)...", cfg.OutputPath, cfg.ResizeWidth)
+		}
+	} else {
+		e.logger.Info("Saving to %s...", cfg.OutputPath)
+	}
+
+	// Save screenshot
+	if err := e.filesystem.WriteFile(cfg.OutputPath, screenshot, 0644); err != nil {
+		return fmt.Errorf("save screenshot: %w", err)
+	}
+
+	metaPath := metadata.SidecarPath(cfg.OutputPath)
+	e.logger.Debug("Saving metadata to %s...", metaPath)
+	if err := metadata.Write(e.filesystem, metaPath, meta); err != nil {
+		return fmt.Errorf("save metadata: %w", err)
+	}
+
+	diagPath := metadata.DiagnosticsPath(cfg.OutputPath)
+	e.logger.Debug("Saving diagnostics to %s...", diagPath)
+	if err := e.writeDiagnostics(diagPath, page.diagnostics); err != nil {
+		return fmt.Errorf("save diagnostics: %w", err)
+	}
+
+	if page.layout != nil {
+		layoutPath := layout.SidecarPath(cfg.OutputPath)
+		e.logger.Debug("Saving layout snapshot to %s...", layoutPath)
+		if err := layout.Write(e.filesystem, layoutPath, page.layout); err != nil {
+			return fmt.Errorf("save layout snapshot: %w", err)
+		}
+	}
+
+	if page.accessibility != nil {
+		a11yPath := a11y.SidecarPath(cfg.OutputPath)
+		e.logger.Debug("Saving accessibility snapshot to %s...", a11yPath)
+		if err := a11y.Write(e.filesystem, a11yPath, page.accessibility); err != nil {
+			return fmt.Errorf("save accessibility snapshot: %w", err)
+		}
+	}
+
+	// The screenshot and diagnostics are kept for a failed page, to show
+	// what went wrong
+	if err := checkDiagnostics(cfg, page.diagnostics); err != nil {
+		return fmt.Errorf("%w (see %s)", err, diagPath)
+	}
+
+	e.logger.Info("Done! Screenshot saved to %s", cfg.OutputPath)
+	return nil
+}
+
+// Capture is a screenshot taken by CaptureURLs.
+type Capture struct {
+	// Screenshot is the PNG screenshot.
+	Screenshot []byte
+
+	// Metadata describes the capture, masked areas included.
+	Metadata *metadata.Metadata
+
+	// Diagnostics are what the page reported while it loaded.
+	Diagnostics ports.PageDiagnostics
+}
+
+// CaptureURLs captures each URL with the same settings in one browser session
+// and returns the captures in order. cfg.URL and cfg.OutputPath are ignored
+// and nothing is written to disk.
+func (e *Executor) CaptureURLs(ctx context.Context, cfg Config, urls []string) ([]Capture, error) {
+	server, err := e.startDevServer(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if server != nil {
+		defer server.Stop()
+	}
+
+	meta, err := e.launch(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer e.browser.Close()
+
+	captures := make([]Capture, 0, len(urls))
+	for _, url := range urls {
+		page, err := e.capturePage(ctx, cfg, meta.DeviceScaleFactor, url)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", url, err)
+		}
+		if err := checkDiagnostics(cfg, page.diagnostics); err != nil {
+			return nil, fmt.Errorf("%s: %w", url, err)
+		}
+		// No diagnostics file is written for these captures, so problems
+		// are reported here
+		e.warnDiagnostics(url, page.diagnostics)
+
+		pageMeta := *meta
+		pageMeta.Version = cfg.Version
+		pageMeta.CapturedAt = time.Now().UTC()
+		pageMeta.URL = url
+		pageMeta.FinalURL = page.finalURL
+		pageMeta.FixedElements = page.fixedElements
+		pageMeta.Masks = page.masks
+		captures = append(captures, Capture{Screenshot: page.screenshot, Metadata: &pageMeta, Diagnostics: page.diagnostics})
+	}
+
+	return captures, nil
+}
+
+// startDevServer starts the managed dev server of cfg and waits until it
+// answers. It returns nil when there is no ServeCommand.
+func (e *Executor) startDevServer(ctx context.Context, cfg Config) (*devserver.Server, error) {
+	if cfg.ServeCommand == "" {
+		return nil, nil
+	}
+	server, err := devserver.Start(ctx, devserver.Options{
+		Command: cfg.ServeCommand,
+		URL:     cfg.ServeURL,
+		Timeout: time.Duration(cfg.ServeTimeout) * time.Second,
+	}, e.logger)
+	if err != nil {
+		return nil, fmt.Errorf("start dev server: %w", err)
+	}
+	return server, nil
+}
+
+// launch starts the browser for cfg and injects the deterministic scripts,
+// which then apply to every page navigated to afterwards. It returns the
+// capture metadata known at launch time.
+func (e *Executor) launch(ctx context.Context, cfg Config) (*metadata.Metadata, error) {
 	// Apply preset if specified
-	preset := GetPreset(cfg.Preset)
+	presets := Presets
+	if cfg.PresetsFile != "" {
+		var err error
+		presets, err = LoadPresets(e.filesystem, cfg.PresetsFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	preset, err := LookupPreset(presets, cfg.Preset)
+	if err != nil {
+		return nil, err
+	}
 
 	// Override with explicit values if provided
 	viewportWidth := cfg.ViewportWidth
@@ -46,7 +248,19 @@ func (e *Executor) Execute(ctx context.Context, cfg Config) error {
 		viewportHeight = preset.ViewportHeight
 	}
 
+	scaleFactor := cfg.DeviceScaleFactor
+	if scaleFactor == 0 {
+		scaleFactor = preset.DeviceScaleFactor
+	}
+	if scaleFactor < 0 {
+		return nil, fmt.Errorf("invalid device scale factor %g", scaleFactor)
+	}
+
 	e.logger.Info("Launching browser...")
+	if scaleFactor > 1 {
+		e.logger.Debug("Device scale factor %gx: screenshot will be %dx%d pixels before resize",
+			scaleFactor, int(float64(viewportWidth)*scaleFactor), int(float64(viewportHeight)*scaleFactor))
+	}
 
 	// Determine User-Agent (config overrides preset)
 	userAgent := preset.UserAgent
@@ -54,6 +268,22 @@ func (e *Executor) Execute(ctx context.Context, cfg Config) error {
 		userAgent = cfg.UserAgent
 	}
 
+	// Determine media emulation (config overrides preset)
+	colorScheme := preset.ColorScheme
+	if cfg.ColorScheme != "" {
+		colorScheme = cfg.ColorScheme
+	}
+	mediaType := preset.MediaType
+	if cfg.MediaType != "" {
+		mediaType = cfg.MediaType
+	}
+	if err := validateMediaEmulation(colorScheme, mediaType); err != nil {
+		return nil, err
+	}
+	if _, err := chromebrowser.GenerateFixedElementsScript(cfg.FixedElements); err != nil {
+		return nil, err
+	}
+
 	// Launch browser
 	launchOpts := ports.BrowserOptions{
 		Headless:          cfg.Headless,
@@ -61,25 +291,242 @@ func (e *Executor) Execute(ctx context.Context, cfg Config) error {
 		UserAgent:         userAgent,
 		ViewportWidth:     viewportWidth,
 		ViewportHeight:    viewportHeight,
+		DeviceScaleFactor: scaleFactor,
 		IsMobile:          preset.IsMobile,
+		HasTouch:          preset.HasTouch,
 		IgnoreHTTPSErrors: cfg.IgnoreHTTPSErrors,
 		ProxyServer:       cfg.ProxyServer,
+		ColorScheme:       colorScheme,
+		ReducedMotion:     preset.ReducedMotion || cfg.ReducedMotion,
+		ForcedColors:      preset.ForcedColors || cfg.ForcedColors,
+		MediaType:         mediaType,
+		Timezone:          cfg.Timezone,
+		Locale:            cfg.Locale,
+		AcceptLanguage:    cfg.AcceptLanguage,
+		Geolocation:       cfg.Geolocation,
+		StrictRendering:   cfg.DeterminismProfile == chromebrowser.ProfileStrict,
+	}
+
+	// Resolve the deterministic scripts before launching so that a typo in a
+	// script name or path fails fast
+	userScripts, err := e.loadDeterminismScripts(cfg.DeterminismScripts)
+	if err != nil {
+		return nil, err
+	}
+	carouselHooks, err := e.loadCarouselHooks(cfg.CarouselHooks)
+	if err != nil {
+		return nil, err
+	}
+	deterministicScripts, applied, err := chromebrowser.BuildDeterministicScripts(chromebrowser.DeterminismOptions{
+		Profile:       cfg.DeterminismProfile,
+		MockTime:      cfg.MockTime,
+		ClockStep:     cfg.ClockStep,
+		RandomSeed:    cfg.RandomSeed,
+		VideoFrame:    cfg.VideoFrame,
+		CarouselHooks: carouselHooks,
+		Disable:       cfg.DisableDeterminism,
+		Enable:        cfg.EnableDeterminism,
+		UserScripts:   userScripts,
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	if err := e.browser.Launch(ctx, launchOpts); err != nil {
-		return fmt.Errorf("launch browser: %w", err)
+		return nil, fmt.Errorf("launch browser: %w", err)
 	}
-	defer e.browser.Close()
 
 	// Inject deterministic scripts before navigation
 	e.logger.Info("Injecting deterministic scripts...")
-	deterministicScripts := chromebrowser.GetAllDeterministicScripts(cfg.MockTime)
-	if err := e.browser.InjectScript(ctx, deterministicScripts); err != nil {
-		return fmt.Errorf("inject deterministic scripts: %w", err)
+	e.logger.Debug("Deterministic scripts: %s", strings.Join(applied, ", "))
+	for _, script := range deterministicScripts {
+		if err := e.browser.InjectScript(ctx, script); err != nil {
+			e.browser.Close()
+			return nil, fmt.Errorf("inject deterministic scripts: %w", err)
+		}
 	}
 
+	chromeVersion, err := e.browser.Version(ctx)
+	if err != nil {
+		e.logger.Debug("Failed to read browser version: %v", err)
+	}
+
+	if scaleFactor == 0 {
+		scaleFactor = 1
+	}
+	return &metadata.Metadata{
+		Preset:               cfg.Preset,
+		ViewportWidth:        viewportWidth,
+		ViewportHeight:       viewportHeight,
+		DeviceScaleFactor:    scaleFactor,
+		UserAgent:            userAgent,
+		ColorScheme:          colorScheme,
+		MediaType:            mediaType,
+		FullPage:             cfg.FullPage,
+		Timezone:             cfg.Timezone,
+		Locale:               cfg.Locale,
+		AcceptLanguage:       cfg.AcceptLanguage,
+		Geolocation:          geolocationMetadata(cfg.Geolocation),
+		MockTime:             cfg.MockTime,
+		ChromeVersion:        chromeVersion,
+		DeterminismProfile:   cfg.DeterminismProfile,
+		DeterministicScripts: applied,
+	}, nil
+}
+
+// geolocationMetadata converts the emulated position for the metadata.
+func geolocationMetadata(g *ports.Geolocation) *metadata.Geolocation {
+	if g == nil {
+		return nil
+	}
+	return &metadata.Geolocation{Latitude: g.Latitude, Longitude: g.Longitude, Accuracy: g.Accuracy}
+}
+
+// loadDeterminismScripts reads user script files injected after the
+// deterministic bundle. Each script is named after its path.
+func (e *Executor) loadDeterminismScripts(paths []string) ([]chromebrowser.UserScript, error) {
+	scripts := make([]chromebrowser.UserScript, 0, len(paths))
+	for _, path := range paths {
+		data, err := e.filesystem.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read determinism script: %w", err)
+		}
+		scripts = append(scripts, chromebrowser.UserScript{Name: path, Source: string(data)})
+	}
+	return scripts, nil
+}
+
+// checkDeterminismScripts fails when a user script did not run to completion
+// in the loaded page, naming the first one that failed.
+func (e *Executor) checkDeterminismScripts(ctx context.Context, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	var ran []string
+	if err := e.browser.Evaluate(ctx, chromebrowser.UserScriptsRanExpression, &ran); err != nil {
+		e.logger.Debug("Failed to check determinism scripts: %v", err)
+		return nil
+	}
+	for _, name := range names {
+		if slices.Contains(ran, name) {
+			continue
+		}
+		// The script is injected with its name as the sourceURL, so its
+		// exception is reported under that name
+		for _, exception := range e.browser.Diagnostics().Exceptions {
+			if exception.URL == name {
+				return fmt.Errorf("determinism script %s:%d: %s", name, exception.Line, exception.Message)
+			}
+		}
+		return fmt.Errorf("determinism script %s failed to parse or threw", name)
+	}
+	return nil
+}
+
+// loadCarouselHooks reads the scripts of the carousel hooks.
+func (e *Executor) loadCarouselHooks(hooks []CarouselHook) ([]chromebrowser.CarouselHook, error) {
+	loaded := make([]chromebrowser.CarouselHook, 0, len(hooks))
+	for _, hook := range hooks {
+		data, err := e.filesystem.ReadFile(hook.ScriptPath)
+		if err != nil {
+			return nil, fmt.Errorf("read carousel hook: %w", err)
+		}
+		loaded = append(loaded, chromebrowser.CarouselHook{Selector: hook.Selector, Script: string(data)})
+	}
+	return loaded, nil
+}
+
+// carouselReport is a freeze attempt reported by the carousel script.
+type carouselReport struct {
+	Library  string `json:"library"`
+	Selector string `json:"selector"`
+	Result   string `json:"result"`
+}
+
+// settleFrames runs animation frames on the virtual clock, so script-driven
+// animations reach the same point in every capture.
+func (e *Executor) settleFrames(ctx context.Context) {
+	var frames int
+	if err := e.browser.Evaluate(ctx, chromebrowser.SettleFramesExpression, &frames); err != nil {
+		e.logger.Debug("Failed to settle animation frames: %v", err)
+		return
+	}
+	if frames > 0 {
+		e.logger.Debug("Settled %d animation frames", frames)
+	}
+}
+
+// reportCarousels logs the carousels the carousel script found.
+func (e *Executor) reportCarousels(ctx context.Context) {
+	var reports []carouselReport
+	if err := e.browser.Evaluate(ctx, chromebrowser.CarouselReportExpression, &reports); err != nil {
+		e.logger.Debug("Failed to read carousel report: %v", err)
+		return
+	}
+	if len(reports) == 0 {
+		e.logger.Debug("No carousels found")
+		return
+	}
+	for _, report := range reports {
+		if strings.HasPrefix(report.Result, "failed") {
+			e.logger.Warn("Carousel %s (%s): %s", report.Selector, report.Library, report.Result)
+		} else {
+			e.logger.Debug("Carousel %s (%s): %s", report.Selector, report.Library, report.Result)
+		}
+	}
+}
+
+// preloadScroll steps through the page to trigger scroll-driven lazy loading.
+func (e *Executor) preloadScroll(ctx context.Context) {
+	e.logger.Debug("Scrolling through the page to preload content...")
+	var result struct {
+		Steps  int     `json:"steps"`
+		Height float64 `json:"height"`
+	}
+	if err := e.browser.Evaluate(ctx, chromebrowser.PreloadScrollScript, &result); err != nil {
+		e.logger.Warn("Failed to preload by scrolling: %v", err)
+		return
+	}
+	e.logger.Debug("Scrolled %d steps, page is %.0fpx high", result.Steps, result.Height)
+}
+
+// adjustFixedElements applies the fixed elements mode to the page and logs
+// the adjusted elements.
+func (e *Executor) adjustFixedElements(ctx context.Context, mode string) []metadata.FixedElement {
+	script, err := chromebrowser.GenerateFixedElementsScript(mode)
+	if err != nil || script == "" {
+		return nil
+	}
+
+	var adjusted []metadata.FixedElement
+	if err := e.browser.Evaluate(ctx, script, &adjusted); err != nil {
+		e.logger.Warn("Failed to adjust fixed elements: %v", err)
+		return nil
+	}
+	e.logger.Info("Adjusted %d fixed and sticky elements", len(adjusted))
+	for _, el := range adjusted {
+		e.logger.Debug("Fixed element %s (%s): %s", el.Selector, el.Position, el.Action)
+	}
+	return adjusted
+}
+
+// pageCapture is the result of capturing one page.
+type pageCapture struct {
+	screenshot    []byte
+	finalURL      string
+	diagnostics   ports.PageDiagnostics
+	fixedElements []metadata.FixedElement
+	masks         []metadata.MaskRegion
+	layout        *layout.Snapshot // nil unless Config.LayoutSnapshot is set and it succeeded
+	accessibility *a11y.Snapshot   // nil unless Config.AccessibilitySnapshot is set and it succeeded
+}
+
+// capturePage navigates the launched browser to url, prepares the page and
+// returns the (optionally resized) PNG screenshot. scaleFactor is the device
+// scale factor the browser was launched with.
+func (e *Executor) capturePage(ctx context.Context, cfg Config, scaleFactor float64, url string) (*pageCapture, error) {
 	// Navigate to URL
-	e.logger.Info("Navigating to %s...", cfg.URL)
+	e.logger.Info("Navigating to %s...", url)
 	navCtx := ctx
 	if cfg.Timeout > 0 {
 		var cancel context.CancelFunc
@@ -87,8 +534,19 @@ func (e *Executor) Execute(ctx context.Context, cfg Config) error {
 		defer cancel()
 	}
 
-	if err := e.browser.Navigate(navCtx, cfg.URL); err != nil {
-		return fmt.Errorf("navigate: %w", err)
+	if err := e.browser.Navigate(navCtx, url); err != nil {
+		return nil, fmt.Errorf("navigate: %w", err)
+	}
+
+	var finalURL string
+	if err := e.browser.Evaluate(ctx, "window.location.href", &finalURL); err != nil {
+		e.logger.Debug("Failed to read final URL: %v", err)
+	} else if finalURL != url {
+		e.logger.Debug("Redirected to %s", finalURL)
+	}
+
+	if err := e.checkDeterminismScripts(ctx, cfg.DeterminismScripts); err != nil {
+		return nil, err
 	}
 
 	// Wait after load if specified
@@ -111,6 +569,11 @@ func (e *Executor) Execute(ctx context.Context, cfg Config) error {
 		}
 	}
 
+	// Scroll through the page so that scroll-triggered content loads
+	if cfg.PreloadScroll {
+		e.preloadScroll(ctx)
+	}
+
 	// Wait for selectors if specified
 	for _, selector := range cfg.WaitSelectors {
 		e.logger.Debug("Waiting for selector: %s", selector)
@@ -126,49 +589,237 @@ func (e *Executor) Execute(ctx context.Context, cfg Config) error {
 	}
 
 	e.logger.Debug("Waiting for images...")
+	e.settleFrames(ctx)
+
 	if err := e.browser.WaitForImages(ctx); err != nil {
 		e.logger.Warn("Failed to wait for images: %v", err)
 	}
 
+	e.reportCarousels(ctx)
+
+	fixedElements := e.adjustFixedElements(ctx, cfg.FixedElements)
+
 	// Apply masks if specified
+	var maskedRects []ports.MaskedRect
 	if len(cfg.Masks) > 0 {
 		e.logger.Debug("Applying masks...")
-		if err := e.browser.ApplyMasks(ctx, cfg.Masks); err != nil {
+		rects, err := e.browser.ApplyMasks(ctx, cfg.Masks)
+		if err != nil {
 			e.logger.Warn("Failed to apply masks: %v", err)
 		}
+		e.logger.Debug("Masked %d elements", len(rects))
+		maskedRects = rects
+	}
+
+	var layoutSnapshot *layout.Snapshot
+	if cfg.LayoutSnapshot {
+		layoutSnapshot = e.snapshotLayout(ctx, finalURL)
+	}
+
+	var accessibility *a11y.Snapshot
+	if cfg.AccessibilitySnapshot {
+		accessibility = e.snapshotAccessibility(ctx, finalURL)
 	}
 
 	// Small delay to ensure everything is rendered
 	time.Sleep(100 * time.Millisecond)
 
 	// Take screenshot
-	e.logger.Info("Taking screenshot...")
-	screenshot, err := e.browser.Screenshot(ctx)
+	var screenshot []byte
+	var err error
+	if cfg.FullPage {
+		e.logger.Info("Taking full-page screenshot...")
+		screenshot, err = e.browser.FullPageScreenshot(ctx)
+	} else {
+		e.logger.Info("Taking screenshot...")
+		screenshot, err = e.browser.Screenshot(ctx)
+	}
 	if err != nil {
-		return fmt.Errorf("take screenshot: %w", err)
+		return nil, fmt.Errorf("take screenshot: %w", err)
 	}
 
+	diagnostics := e.browser.Diagnostics()
+	e.logDiagnostics(diagnostics)
+
+	// Map the masked areas from CSS pixels to image pixels: first the device
+	// scale factor, then the resize, clipped to the image
+	imgConfig, err := png.DecodeConfig(bytes.NewReader(screenshot))
+	if err != nil {
+		return nil, fmt.Errorf("decode screenshot: %w", err)
+	}
+	scaleX, scaleY := scaleFactor, scaleFactor
+	bounds := image.Rect(0, 0, imgConfig.Width, imgConfig.Height)
+
 	// Resize if specified
 	if cfg.ResizeWidth > 0 {
 		screenshot, err = resizeScreenshot(screenshot, cfg.ResizeWidth, cfg.ResizeHeight)
 		if err != nil {
-			return fmt.Errorf("resize screenshot: %w", err)
+			return nil, fmt.Errorf("resize screenshot: %w", err)
 		}
-		if cfg.ResizeHeight > 0 {
-			e.logger.Info("Saving to %s (%dx%d)...", cfg.OutputPath, cfg.ResizeWidth, cfg.ResizeHeight)
-		} else {
-			e.logger.Info("Saving to %s (width=%d)...", cfg.OutputPath, cfg.ResizeWidth)
+		resized, err := png.DecodeConfig(bytes.NewReader(screenshot))
+		if err != nil {
+			return nil, fmt.Errorf("decode screenshot: %w", err)
 		}
-	} else {
-		e.logger.Info("Saving to %s...", cfg.OutputPath)
+		scaleX *= float64(resized.Width) / float64(imgConfig.Width)
+		scaleY *= float64(resized.Height) / float64(imgConfig.Height)
+		bounds = image.Rect(0, 0, resized.Width, resized.Height)
 	}
 
-	// Save screenshot
-	if err := e.filesystem.WriteFile(cfg.OutputPath, screenshot, 0644); err != nil {
-		return fmt.Errorf("save screenshot: %w", err)
+	page := &pageCapture{
+		screenshot:    screenshot,
+		finalURL:      finalURL,
+		diagnostics:   diagnostics,
+		fixedElements: fixedElements,
+		masks:         maskRegions(maskedRects, scaleX, scaleY, bounds),
+		accessibility: accessibility,
+	}
+	if layoutSnapshot != nil {
+		layoutSnapshot.ScaleX, layoutSnapshot.ScaleY = scaleX, scaleY
+		page.layout = layoutSnapshot
+	}
+	return page, nil
+}
+
+// snapshotLayout records the visible elements of the page. A failure is
+// logged and returns nil, so that no sidecar is written.
+func (e *Executor) snapshotLayout(ctx context.Context, url string) *layout.Snapshot {
+	e.logger.Debug("Recording layout snapshot...")
+	var result struct {
+		Elements []layout.Element `json:"elements"`
+		Total    int              `json:"total"`
+	}
+	if err := e.browser.Evaluate(ctx, chromebrowser.LayoutSnapshotScript, &result); err != nil {
+		e.logger.Warn("Failed to record layout snapshot: %v", err)
+		return nil
+	}
+	snapshot := &layout.Snapshot{URL: url, Elements: result.Elements}
+	if result.Total > len(result.Elements) {
+		e.logger.Warn("Layout snapshot truncated to %d of %d elements", len(result.Elements), result.Total)
+		snapshot.Truncated = true
+		snapshot.Total = result.Total
+	}
+	e.logger.Debug("Recorded %d elements", len(result.Elements))
+	return snapshot
+}
+
+// snapshotAccessibility records the accessibility tree of the page. A
+// failure is logged and returns nil, so that no sidecar is written.
+func (e *Executor) snapshotAccessibility(ctx context.Context, url string) *a11y.Snapshot {
+	e.logger.Debug("Recording accessibility snapshot...")
+	nodes, err := e.browser.AccessibilityTree(ctx)
+	if err != nil {
+		e.logger.Warn("Failed to record accessibility snapshot: %v", err)
+		return nil
+	}
+	snapshot := a11y.Build(url, nodes)
+	e.logger.Debug("Recorded %d accessibility nodes", len(snapshot.Nodes))
+	return snapshot
+}
+
+// maskRegions converts masked areas in CSS pixels to image pixels, rounding
+// outwards so that the region covers the whole mask. Areas outside bounds
+// are dropped.
+func maskRegions(rects []ports.MaskedRect, scaleX, scaleY float64, bounds image.Rectangle) []metadata.MaskRegion {
+	var regions []metadata.MaskRegion
+	for _, rect := range rects {
+		r := image.Rect(
+			int(math.Floor(rect.X*scaleX)),
+			int(math.Floor(rect.Y*scaleY)),
+			int(math.Ceil((rect.X+rect.Width)*scaleX)),
+			int(math.Ceil((rect.Y+rect.Height)*scaleY)),
+		).Intersect(bounds)
+		if r.Empty() {
+			continue
+		}
+		regions = append(regions, metadata.MaskRegion{
+			Selector: rect.Selector,
+			Style:    rect.Style,
+			X:        r.Min.X,
+			Y:        r.Min.Y,
+			Width:    r.Dx(),
+			Height:   r.Dy(),
+		})
+	}
+	return regions
+}
+
+// logDiagnostics summarizes the page diagnostics.
+func (e *Executor) logDiagnostics(diag ports.PageDiagnostics) {
+	if diag.DocumentStatus >= 400 {
+		e.logger.Warn("Page responded with HTTP %d: %s", diag.DocumentStatus, diag.DocumentURL)
+	}
+	for _, exception := range diag.Exceptions {
+		e.logger.Debug("Uncaught exception: %s", exception.Message)
+	}
+	for _, message := range diag.ConsoleMessages {
+		if message.Level == "error" {
+			e.logger.Debug("Console error: %s", message.Text)
+		}
+	}
+	for _, request := range diag.FailedRequests {
+		e.logger.Debug("Failed request: %s (%d %s)", request.URL, request.Status, request.Error)
+	}
+	e.logger.Debug("Diagnostics: %d console messages, %d exceptions, %d failed requests, %d mixed content",
+		len(diag.ConsoleMessages), len(diag.Exceptions), len(diag.FailedRequests), len(diag.MixedContent))
+}
+
+// warnDiagnostics warns about the uncaught exceptions, console errors and
+// failed requests of the page at url.
+func (e *Executor) warnDiagnostics(url string, diag ports.PageDiagnostics) {
+	consoleErrors := 0
+	for _, message := range diag.ConsoleMessages {
+		if message.Level == "error" {
+			consoleErrors++
+		}
+	}
+	if len(diag.Exceptions) > 0 || consoleErrors > 0 || len(diag.FailedRequests) > 0 {
+		e.logger.Warn("%s: %d uncaught exceptions, %d console errors, %d failed requests",
+			url, len(diag.Exceptions), consoleErrors, len(diag.FailedRequests))
+	}
+}
+
+// writeDiagnostics saves the page diagnostics as JSON to path.
+func (e *Executor) writeDiagnostics(path string, diag ports.PageDiagnostics) error {
+	data, err := json.MarshalIndent(diag, "", "  ")
+	if err != nil {
+		return err
+	}
+	return e.filesystem.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// checkDiagnostics fails a page according to the fail-on settings of cfg.
+func checkDiagnostics(cfg Config, diag ports.PageDiagnostics) error {
+	if cfg.FailOnHTTPError && diag.DocumentStatus >= 400 {
+		return fmt.Errorf("page responded with HTTP %d", diag.DocumentStatus)
+	}
+	if cfg.FailOnConsoleError {
+		count := len(diag.Exceptions)
+		for _, message := range diag.ConsoleMessages {
+			if message.Level == "error" {
+				count++
+			}
+		}
+		if count > 0 {
+			return fmt.Errorf("page reported %d console errors and uncaught exceptions", count)
+		}
+	}
+	return nil
+}
+
+// validateMediaEmulation rejects unknown color schemes and media types.
+func validateMediaEmulation(colorScheme, mediaType string) error {
+	switch colorScheme {
+	case "", "light", "dark":
+	default:
+		return fmt.Errorf("invalid color scheme %q (want light or dark)", colorScheme)
+	}
+
+	switch mediaType {
+	case "", "print", "screen":
+	default:
+		return fmt.Errorf("invalid media type %q (want print or screen)", mediaType)
 	}
 
-	e.logger.Info("Done! Screenshot saved to %s", cfg.OutputPath)
 	return nil
 }
 
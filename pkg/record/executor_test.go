@@ -0,0 +1,135 @@
+package record
+
+import (
+	"context"
+	"image"
+	"reflect"
+	"testing"
+
+	"github.com/ideamans/static-webshot/internal/portstest"
+	"github.com/ideamans/static-webshot/pkg/adapters/logger"
+	"github.com/ideamans/static-webshot/pkg/adapters/osfilesystem"
+	"github.com/ideamans/static-webshot/pkg/metadata"
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
+func TestValidateMediaEmulation(t *testing.T) {
+	tests := []struct {
+		name        string
+		colorScheme string
+		mediaType   string
+		wantErr     bool
+	}{
+		{name: "defaults", colorScheme: "", mediaType: ""},
+		{name: "dark screen", colorScheme: "dark", mediaType: "screen"},
+		{name: "light print", colorScheme: "light", mediaType: "print"},
+		{name: "unknown color scheme", colorScheme: "sepia", wantErr: true},
+		{name: "unknown media type", mediaType: "tv", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateMediaEmulation(tt.colorScheme, tt.mediaType)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateMediaEmulation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMaskRegions(t *testing.T) {
+	rects := []ports.MaskedRect{
+		{Selector: ".ad", Style: "blackout", X: 10.5, Y: 20, Width: 100, Height: 50},
+		{Selector: ".footer", Style: "hide", X: 0, Y: 1000, Width: 300, Height: 40},
+		{Selector: ".edge", Style: "blur", X: 300, Y: 150, Width: 100, Height: 100},
+	}
+
+	// DPR 2, then resized to half: image pixels equal CSS pixels
+	got := maskRegions(rects, 1, 1, image.Rect(0, 0, 360, 200))
+	want := []metadata.MaskRegion{
+		{Selector: ".ad", Style: "blackout", X: 10, Y: 20, Width: 101, Height: 50},
+		{Selector: ".edge", Style: "blur", X: 300, Y: 150, Width: 60, Height: 50},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("maskRegions() = %+v, want %+v", got, want)
+	}
+
+	got = maskRegions(rects[:1], 2, 2, image.Rect(0, 0, 720, 400))
+	if len(got) != 1 || got[0].X != 21 || got[0].Width != 200 || got[0].Height != 100 {
+		t.Errorf("maskRegions() at DPR 2 = %+v", got)
+	}
+}
+
+func TestCheckDiagnostics(t *testing.T) {
+	serverError := ports.PageDiagnostics{DocumentStatus: 500}
+	consoleError := ports.PageDiagnostics{
+		DocumentStatus:  200,
+		ConsoleMessages: []ports.ConsoleMessage{{Source: "console", Level: "error", Text: "boom"}},
+	}
+	exception := ports.PageDiagnostics{
+		DocumentStatus: 200,
+		Exceptions:     []ports.PageException{{Message: "TypeError: x is undefined"}},
+	}
+	warning := ports.PageDiagnostics{
+		DocumentStatus:  404,
+		ConsoleMessages: []ports.ConsoleMessage{{Source: "console", Level: "warning", Text: "deprecated"}},
+	}
+
+	tests := []struct {
+		name        string
+		failConsole bool
+		failHTTP    bool
+		diag        ports.PageDiagnostics
+		wantErr     bool
+	}{
+		{name: "no fail options", diag: serverError},
+		{name: "http error", failHTTP: true, diag: serverError, wantErr: true},
+		{name: "http error ignored for console", failConsole: true, diag: serverError},
+		{name: "console error", failConsole: true, diag: consoleError, wantErr: true},
+		{name: "exception", failConsole: true, diag: exception, wantErr: true},
+		{name: "warning only", failConsole: true, diag: warning},
+		{name: "not found", failHTTP: true, diag: warning, wantErr: true},
+		{name: "no response", failHTTP: true, diag: ports.PageDiagnostics{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.FailOnConsoleError = tt.failConsole
+			cfg.FailOnHTTPError = tt.failHTTP
+			if err := checkDiagnostics(cfg, tt.diag); (err != nil) != tt.wantErr {
+				t.Errorf("checkDiagnostics() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExecutor_CaptureURLs(t *testing.T) {
+	browser := &portstest.Browser{PageDiagnostics: map[string]ports.PageDiagnostics{
+		"https://staging.example.com": {DocumentStatus: 500},
+	}}
+	urls := []string{"https://prod.example.com", "https://staging.example.com"}
+
+	cfg := DefaultConfig()
+	cfg.WaitAfter = 0
+
+	executor := NewExecutor(browser, osfilesystem.New(), logger.New())
+	captures, err := executor.CaptureURLs(context.Background(), cfg, urls)
+	if err != nil {
+		t.Fatalf("CaptureURLs() error = %v", err)
+	}
+	if len(captures) != len(urls) {
+		t.Fatalf("CaptureURLs() returned %d captures, want %d", len(captures), len(urls))
+	}
+	for i, capture := range captures {
+		if capture.Metadata.URL != urls[i] {
+			t.Errorf("captures[%d].Metadata.URL = %q, want %q", i, capture.Metadata.URL, urls[i])
+		}
+		if capture.Metadata.CapturedAt.IsZero() {
+			t.Errorf("captures[%d].Metadata.CapturedAt is not set", i)
+		}
+	}
+	if got := captures[1].Diagnostics.DocumentStatus; got != 500 {
+		t.Errorf("captures[1].Diagnostics.DocumentStatus = %d, want 500", got)
+	}
+}
@@ -1,34 +1,259 @@
 // Package record provides device presets for the record command.
 package record
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/ideamans/static-webshot/pkg/ports"
+)
+
 // Preset defines viewport and device settings.
 type Preset struct {
-	ViewportWidth  int
-	ViewportHeight int
-	IsMobile       bool
-	UserAgent      string
+	Description       string  `json:"description,omitempty" yaml:"description,omitempty"`
+	ViewportWidth     int     `json:"viewportWidth" yaml:"viewportWidth"`
+	ViewportHeight    int     `json:"viewportHeight" yaml:"viewportHeight"`
+	DeviceScaleFactor float64 `json:"deviceScaleFactor,omitempty" yaml:"deviceScaleFactor,omitempty"` // Device pixels per CSS pixel (0 = 1)
+	IsMobile          bool    `json:"isMobile,omitempty" yaml:"isMobile,omitempty"`
+	HasTouch          bool    `json:"hasTouch,omitempty" yaml:"hasTouch,omitempty"`
+	UserAgent         string  `json:"userAgent,omitempty" yaml:"userAgent,omitempty"`
+	ColorScheme       string  `json:"colorScheme,omitempty" yaml:"colorScheme,omitempty"` // "light", "dark" or "" (browser default)
+	ReducedMotion     bool    `json:"reducedMotion,omitempty" yaml:"reducedMotion,omitempty"`
+	ForcedColors      bool    `json:"forcedColors,omitempty" yaml:"forcedColors,omitempty"`
+	MediaType         string  `json:"mediaType,omitempty" yaml:"mediaType,omitempty"` // "print", "screen" or "" (browser default)
 }
 
-// Presets defines available device presets.
+// User agents shared by the built-in presets.
+const (
+	userAgentWindowsChrome = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	userAgentMacChrome     = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	userAgentIPhone        = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1"
+	userAgentIPad          = "Mozilla/5.0 (iPad; CPU OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1"
+	userAgentPixel         = "Mozilla/5.0 (Linux; Android 14; Pixel 7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36"
+	userAgentGalaxy        = "Mozilla/5.0 (Linux; Android 14; SM-S911B) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36"
+)
+
+// Presets defines the built-in device presets.
 var Presets = map[string]Preset{
+	// Generic form factors
 	"desktop": {
-		ViewportWidth:  1920,
-		ViewportHeight: 1080,
-		IsMobile:       false,
-		UserAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+		Description:       "Full HD desktop",
+		ViewportWidth:     1920,
+		ViewportHeight:    1080,
+		DeviceScaleFactor: 1,
+		IsMobile:          false,
+		UserAgent:         userAgentWindowsChrome,
+	},
+	"desktop-large": {
+		Description:       "QHD desktop",
+		ViewportWidth:     2560,
+		ViewportHeight:    1440,
+		DeviceScaleFactor: 1,
+		UserAgent:         userAgentWindowsChrome,
 	},
+	"laptop": {
+		Description:       "Common laptop",
+		ViewportWidth:     1366,
+		ViewportHeight:    768,
+		DeviceScaleFactor: 1,
+		UserAgent:         userAgentWindowsChrome,
+	},
+	"laptop-hidpi": {
+		Description:       "13-inch MacBook with Retina display",
+		ViewportWidth:     1440,
+		ViewportHeight:    900,
+		DeviceScaleFactor: 2,
+		UserAgent:         userAgentMacChrome,
+	},
+	"tablet": {
+		Description:       "iPad Air, portrait",
+		ViewportWidth:     820,
+		ViewportHeight:    1180,
+		DeviceScaleFactor: 2,
+		IsMobile:          true,
+		HasTouch:          true,
+		UserAgent:         userAgentIPad,
+	},
+	"tablet-landscape": {
+		Description:       "iPad Air, landscape",
+		ViewportWidth:     1180,
+		ViewportHeight:    820,
+		DeviceScaleFactor: 2,
+		IsMobile:          true,
+		HasTouch:          true,
+		UserAgent:         userAgentIPad,
+	},
+	// mobile keeps the settings it had before device scale factors and
+	// touch emulation were added, so existing baselines stay valid.
+	// iphone-15 is the same viewport at the device's real ratio.
 	"mobile": {
-		ViewportWidth:  390,
-		ViewportHeight: 844,
-		IsMobile:       true,
-		UserAgent:      "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1",
+		Description:       "Phone viewport at 1x without touch",
+		ViewportWidth:     390,
+		ViewportHeight:    844,
+		DeviceScaleFactor: 1,
+		IsMobile:          true,
+		UserAgent:         userAgentIPhone,
+	},
+	"mobile-small": {
+		Description:       "iPhone SE",
+		ViewportWidth:     375,
+		ViewportHeight:    667,
+		DeviceScaleFactor: 2,
+		IsMobile:          true,
+		HasTouch:          true,
+		UserAgent:         userAgentIPhone,
+	},
+	"mobile-large": {
+		Description:       "iPhone 15 Pro Max",
+		ViewportWidth:     430,
+		ViewportHeight:    932,
+		DeviceScaleFactor: 3,
+		IsMobile:          true,
+		HasTouch:          true,
+		UserAgent:         userAgentIPhone,
+	},
+
+	// Specific devices
+	"iphone-se": {
+		Description:       "iPhone SE (3rd generation)",
+		ViewportWidth:     375,
+		ViewportHeight:    667,
+		DeviceScaleFactor: 2,
+		IsMobile:          true,
+		HasTouch:          true,
+		UserAgent:         userAgentIPhone,
+	},
+	"iphone-15": {
+		Description:       "iPhone 15",
+		ViewportWidth:     390,
+		ViewportHeight:    844,
+		DeviceScaleFactor: 3,
+		IsMobile:          true,
+		HasTouch:          true,
+		UserAgent:         userAgentIPhone,
+	},
+	"iphone-15-pro-max": {
+		Description:       "iPhone 15 Pro Max",
+		ViewportWidth:     430,
+		ViewportHeight:    932,
+		DeviceScaleFactor: 3,
+		IsMobile:          true,
+		HasTouch:          true,
+		UserAgent:         userAgentIPhone,
+	},
+	"pixel-7": {
+		Description:       "Google Pixel 7",
+		ViewportWidth:     412,
+		ViewportHeight:    915,
+		DeviceScaleFactor: 2.625,
+		IsMobile:          true,
+		HasTouch:          true,
+		UserAgent:         userAgentPixel,
+	},
+	"galaxy-s23": {
+		Description:       "Samsung Galaxy S23",
+		ViewportWidth:     360,
+		ViewportHeight:    780,
+		DeviceScaleFactor: 3,
+		IsMobile:          true,
+		HasTouch:          true,
+		UserAgent:         userAgentGalaxy,
+	},
+	"ipad-mini": {
+		Description:       "iPad mini, portrait",
+		ViewportWidth:     744,
+		ViewportHeight:    1133,
+		DeviceScaleFactor: 2,
+		IsMobile:          true,
+		HasTouch:          true,
+		UserAgent:         userAgentIPad,
+	},
+	"ipad-pro-12": {
+		Description:       "iPad Pro 12.9-inch, portrait",
+		ViewportWidth:     1024,
+		ViewportHeight:    1366,
+		DeviceScaleFactor: 2,
+		IsMobile:          true,
+		HasTouch:          true,
+		UserAgent:         userAgentIPad,
 	},
 }
 
-// GetPreset returns the preset by name, defaulting to desktop.
-func GetPreset(name string) Preset {
-	if preset, ok := Presets[name]; ok {
-		return preset
+// GetPreset returns the built-in preset by name.
+// An unknown name is an error rather than a silent fallback.
+func GetPreset(name string) (Preset, error) {
+	return LookupPreset(Presets, name)
+}
+
+// LookupPreset returns the named preset from presets.
+func LookupPreset(presets map[string]Preset, name string) (Preset, error) {
+	if preset, ok := presets[name]; ok {
+		return preset, nil
+	}
+	return Preset{}, fmt.Errorf("unknown preset %q (available: %s)", name, strings.Join(PresetNames(presets), ", "))
+}
+
+// PresetNames returns the names of presets in sorted order.
+func PresetNames(presets map[string]Preset) []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadPresets reads custom presets from a JSON or YAML file and returns them
+// merged over the built-in presets. The file maps preset names to presets;
+// a custom preset with a built-in name replaces it. Files ending in .json
+// are parsed as JSON, anything else as YAML.
+func LoadPresets(fs ports.FileSystem, path string) (map[string]Preset, error) {
+	data, err := fs.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read presets file: %w", err)
+	}
+
+	var custom map[string]Preset
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&custom)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&custom)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse presets file %s: %w", path, err)
+	}
+
+	presets := make(map[string]Preset, len(Presets)+len(custom))
+	for name, preset := range Presets {
+		presets[name] = preset
+	}
+	for name, preset := range custom {
+		if err := validatePreset(preset); err != nil {
+			return nil, fmt.Errorf("preset %q in %s: %w", name, path, err)
+		}
+		presets[name] = preset
+	}
+
+	return presets, nil
+}
+
+// validatePreset rejects presets that cannot be launched.
+func validatePreset(preset Preset) error {
+	if preset.ViewportWidth <= 0 || preset.ViewportHeight <= 0 {
+		return fmt.Errorf("viewportWidth and viewportHeight must be positive")
+	}
+	if preset.DeviceScaleFactor < 0 {
+		return fmt.Errorf("deviceScaleFactor must not be negative")
 	}
-	return Presets["desktop"]
+	return validateMediaEmulation(preset.ColorScheme, preset.MediaType)
 }
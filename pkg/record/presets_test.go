@@ -1,6 +1,13 @@
 package record
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ideamans/static-webshot/pkg/adapters/osfilesystem"
+)
 
 func TestGetPreset(t *testing.T) {
 	tests := []struct {
@@ -9,6 +16,8 @@ func TestGetPreset(t *testing.T) {
 		wantWidth  int
 		wantHeight int
 		wantMobile bool
+		wantDPR    float64
+		wantErr    bool
 	}{
 		{
 			name:       "desktop preset",
@@ -16,6 +25,7 @@ func TestGetPreset(t *testing.T) {
 			wantWidth:  1920,
 			wantHeight: 1080,
 			wantMobile: false,
+			wantDPR:    1,
 		},
 		{
 			name:       "mobile preset",
@@ -23,19 +33,35 @@ func TestGetPreset(t *testing.T) {
 			wantWidth:  390,
 			wantHeight: 844,
 			wantMobile: true,
+			wantDPR:    1,
 		},
 		{
-			name:       "unknown preset falls back to desktop",
-			preset:     "unknown",
-			wantWidth:  1920,
-			wantHeight: 1080,
-			wantMobile: false,
+			name:       "device preset",
+			preset:     "pixel-7",
+			wantWidth:  412,
+			wantHeight: 915,
+			wantMobile: true,
+			wantDPR:    2.625,
+		},
+		{
+			name:    "unknown preset is an error",
+			preset:  "unknown",
+			wantErr: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			preset := GetPreset(tt.preset)
+			preset, err := GetPreset(tt.preset)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("GetPreset(%q) error = nil, want error", tt.preset)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetPreset(%q) error = %v", tt.preset, err)
+			}
 
 			if preset.ViewportWidth != tt.wantWidth {
 				t.Errorf("ViewportWidth = %d, want %d", preset.ViewportWidth, tt.wantWidth)
@@ -43,9 +69,109 @@ func TestGetPreset(t *testing.T) {
 			if preset.ViewportHeight != tt.wantHeight {
 				t.Errorf("ViewportHeight = %d, want %d", preset.ViewportHeight, tt.wantHeight)
 			}
+			if preset.DeviceScaleFactor != tt.wantDPR {
+				t.Errorf("DeviceScaleFactor = %v, want %v", preset.DeviceScaleFactor, tt.wantDPR)
+			}
 			if preset.IsMobile != tt.wantMobile {
 				t.Errorf("IsMobile = %v, want %v", preset.IsMobile, tt.wantMobile)
 			}
 		})
 	}
 }
+
+func TestGetPreset_UnknownListsAvailable(t *testing.T) {
+	_, err := GetPreset("desktp")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(err.Error(), "desktop") {
+		t.Errorf("error %q does not list the available presets", err)
+	}
+}
+
+func TestLoadPresets(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		wantErr  bool
+		checkKey string
+		want     Preset
+	}{
+		{
+			name: "yaml",
+			file: "presets.yaml",
+			content: `kiosk:
+  description: Portrait kiosk
+  viewportWidth: 1080
+  viewportHeight: 1920
+  hasTouch: true
+  colorScheme: dark
+`,
+			checkKey: "kiosk",
+			want: Preset{
+				Description:    "Portrait kiosk",
+				ViewportWidth:  1080,
+				ViewportHeight: 1920,
+				HasTouch:       true,
+				ColorScheme:    "dark",
+			},
+		},
+		{
+			name:     "json overrides built-in",
+			file:     "presets.json",
+			content:  `{"desktop": {"viewportWidth": 1280, "viewportHeight": 800, "deviceScaleFactor": 2}}`,
+			checkKey: "desktop",
+			want: Preset{
+				ViewportWidth:     1280,
+				ViewportHeight:    800,
+				DeviceScaleFactor: 2,
+			},
+		},
+		{
+			name:    "unknown field",
+			file:    "presets.yaml",
+			content: "kiosk:\n  viewportWidth: 1080\n  viewportHeight: 1920\n  dpr: 2\n",
+			wantErr: true,
+		},
+		{
+			name:    "missing viewport",
+			file:    "presets.json",
+			content: `{"kiosk": {"viewportWidth": 1080}}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid color scheme",
+			file:    "presets.yaml",
+			content: "kiosk:\n  viewportWidth: 1080\n  viewportHeight: 1920\n  colorScheme: sepia\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			presets, err := LoadPresets(osfilesystem.New(), path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("LoadPresets() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadPresets() error = %v", err)
+			}
+
+			if got := presets[tt.checkKey]; got != tt.want {
+				t.Errorf("presets[%q] = %+v, want %+v", tt.checkKey, got, tt.want)
+			}
+			if _, ok := presets["mobile"]; !ok {
+				t.Error("built-in presets were not kept")
+			}
+		})
+	}
+}